@@ -3,97 +3,117 @@
  <grid orientation="orthogonal" width="1" height="1"/>
  <tile id="0">
   <properties>
+   <property name="type" value="water"/>
    <property name="passable" type="bool" value="false"/>
   </properties>
   <image source="map_tiles/tile_000.png" width="128" height="128"/>
  </tile>
  <tile id="1">
   <properties>
+   <property name="type" value="grass"/>
    <property name="passable" type="bool" value="true"/>
   </properties>
   <image source="map_tiles/tile_001.png" width="128" height="128"/>
  </tile>
  <tile id="2">
   <properties>
+   <property name="type" value="shore"/>
    <property name="passable" type="bool" value="false"/>
   </properties>
   <image source="map_tiles/tile_002.png" width="128" height="128"/>
  </tile>
  <tile id="3">
   <properties>
+   <property name="type" value="shore"/>
    <property name="passable" type="bool" value="false"/>
   </properties>
   <image source="map_tiles/tile_006.png" width="128" height="128"/>
  </tile>
  <tile id="4">
   <properties>
+   <property name="type" value="shore"/>
    <property name="passable" type="bool" value="false"/>
   </properties>
   <image source="map_tiles/tile_010.png" width="128" height="128"/>
  </tile>
  <tile id="5">
   <properties>
+   <property name="type" value="bush"/>
    <property name="passable" type="bool" value="false"/>
   </properties>
   <image source="map_tiles/tile_014.png" width="128" height="128"/>
  </tile>
  <tile id="6">
   <properties>
+   <property name="type" value="tree"/>
    <property name="passable" type="bool" value="false"/>
+   <property name="resource" value="wood"/>
+   <property name="resource_amount" type="int" value="500"/>
   </properties>
   <image source="map_tiles/tile_019.png" width="128" height="128"/>
  </tile>
  <tile id="7">
   <properties>
-   <property name="passable" type="bool" value="false"/>
+   <property name="type" value="sapling"/>
+   <property name="passable" type="bool" value="true"/>
   </properties>
   <image source="map_tiles/tile_024.png" width="128" height="128"/>
  </tile>
  <tile id="8">
   <properties>
-   <property name="passable" type="bool" value="false"/>
+   <property name="type" value="rocks"/>
+   <property name="passable" type="bool" value="true"/>
   </properties>
   <image source="map_tiles/tile_029.png" width="128" height="128"/>
  </tile>
  <tile id="9">
   <properties>
+   <property name="type" value="stump"/>
    <property name="passable" type="bool" value="true"/>
   </properties>
   <image source="map_tiles/tile_034.png" width="128" height="128"/>
  </tile>
  <tile id="10">
   <properties>
+   <property name="type" value="grass"/>
    <property name="passable" type="bool" value="true"/>
   </properties>
   <image source="map_tiles/tile_035.png" width="128" height="128"/>
  </tile>
  <tile id="11">
   <properties>
+   <property name="type" value="grass"/>
    <property name="passable" type="bool" value="true"/>
   </properties>
   <image source="map_tiles/tile_036.png" width="128" height="128"/>
  </tile>
  <tile id="12">
   <properties>
+   <property name="type" value="grass"/>
    <property name="passable" type="bool" value="true"/>
   </properties>
   <image source="map_tiles/tile_039.png" width="128" height="128"/>
  </tile>
  <tile id="13">
   <properties>
+   <property name="type" value="mushrooms"/>
    <property name="passable" type="bool" value="true"/>
   </properties>
   <image source="map_tiles/tile_040.png" width="128" height="128"/>
  </tile>
  <tile id="14" type="passable">
   <properties>
+   <property name="type" value="bones"/>
    <property name="passable" type="bool" value="true"/>
   </properties>
   <image source="map_tiles/tile_043.png" width="128" height="128"/>
  </tile>
  <tile id="15">
   <properties>
-   <property name="resources" type="int" value="1000"/>
+   <property name="type" value="crystal"/>
+   <property name="passable" type="bool" value="false"/>
+   <property name="resource" value="sucrose"/>
+   <property name="resource_amount" type="int" value="1000"/>
   </properties>
   <image source="map_tiles/crystal.png" width="128" height="128"/>
  </tile>
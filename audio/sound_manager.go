@@ -131,10 +131,10 @@ func (sm *SoundManager) PlayRandom(prefix string, count int) {
 	sm.Play(fmt.Sprintf("%s_%d", prefix, n))
 }
 
-func (sm *SoundManager) PlayIssueActionSFX(event eventing.Event) {
+func (sm *SoundManager) PlayIssueActionSFX(eventing.PlayIssueActionSFXEvent) {
 	sm.PlayRandom("sfx_command", 5) //format is 'sfx_command1'
 }
-func (sm *SoundManager) PlaySelectHiveSFX(event eventing.Event) {
+func (sm *SoundManager) PlaySelectHiveSFX(eventing.PlaySelectHiveSFXEvent) {
 	sm.PlayRandom("sfx_hive", 4)
 }
 
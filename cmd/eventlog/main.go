@@ -0,0 +1,120 @@
+// Command eventlog prints an event log written by an eventing.Recorder, e.g.
+// from simrun -record-events or the game's recordEvents setting, to follow
+// what a cutscene or tutorial was told and when.
+//
+//	go run ./cmd/eventlog events.jsonl
+//	go run ./cmd/eventlog -type TriggerEnteredEvent,UnitDiedEvent -from 30s -to 2m events.jsonl
+//
+// Times are counted from the first event in the log. -ticks limits by sim
+// tick instead, and -json prints the matching lines as they are so they can
+// be fed to simrun -inject.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gamejam/eventing"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	types := flag.String("type", "", "comma separated event types to show, all when empty")
+	from := flag.Duration("from", 0, "skip events earlier than this after the first one")
+	to := flag.Duration("to", 0, "skip events later than this after the first one, 0 for no limit")
+	ticks := flag.String("ticks", "", "tick range to show, e.g. 600-1200 or 600-")
+	asJSON := flag.Bool("json", false, "print matching events as JSON lines")
+	count := flag.Bool("count", false, "only print how many events of each type matched")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "eventlog: expected one event log file")
+		flag.Usage()
+		os.Exit(2)
+	}
+	minTick, maxTick, err := parseTicks(*ticks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eventlog: -ticks: %v\n", err)
+		os.Exit(2)
+	}
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eventlog: %v\n", err)
+		os.Exit(1)
+	}
+	records, err := eventing.ReadLog(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eventlog: %v: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+
+	var wanted []string
+	if *types != "" {
+		wanted = strings.Split(*types, ",")
+	}
+	var start time.Time
+	if len(records) > 0 {
+		start = records[0].Time
+	}
+	counts := make(map[string]int)
+	enc := json.NewEncoder(os.Stdout)
+	for _, rec := range records {
+		at := rec.Time.Sub(start)
+		switch {
+		case wanted != nil && !slices.Contains(wanted, rec.Type),
+			at < *from, *to > 0 && at > *to,
+			rec.Tick < minTick, rec.Tick > maxTick:
+			continue
+		}
+		counts[rec.Type]++
+		switch {
+		case *count:
+		case *asJSON:
+			if err := enc.Encode(rec); err != nil {
+				fmt.Fprintf(os.Stderr, "eventlog: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Printf("%10.3fs  tick %-7d %-38s handlers %d  %s\n", at.Seconds(), rec.Tick, rec.Type, rec.Handlers, rec.Event)
+		}
+	}
+	if *count {
+		names := make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Printf("%-38s %d\n", name, counts[name])
+		}
+	}
+}
+
+// parseTicks reads "min-max" where either end can be left out.
+func parseTicks(s string) (uint64, uint64, error) {
+	minTick, maxTick := uint64(0), uint64(1<<64-1)
+	if s == "" {
+		return minTick, maxTick, nil
+	}
+	lo, hi, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q isn't a range like 600-1200", s)
+	}
+	var err error
+	if lo != "" {
+		if minTick, err = strconv.ParseUint(lo, 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	if hi != "" {
+		if maxTick, err = strconv.ParseUint(hi, 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	return minTick, maxTick, nil
+}
@@ -0,0 +1,395 @@
+// Command mapcheck loads the game's Tiled maps and reports anything that would
+// break a level, e.g. a missing object group, a bridge spot the construction
+// cursor can never match, or a completion area the level's units can't reach
+// even after bridging every chasm.
+//
+//	go run ./cmd/mapcheck
+//	go run ./cmd/mapcheck tilemap/map2.tmx
+//
+// Maps are read from the embedded assets like the game does, spawn points and
+// objectives from data/levels.json. Errors make it exit with status 1,
+// warnings are only printed.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gamejam/assets"
+	"gamejam/data"
+	"gamejam/sim"
+	"gamejam/tilemap"
+	"image"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// RequiredGroups are the object groups every map needs.
+var RequiredGroups = []string{"collision", "completion-area"}
+
+// level is the part of a data/levels.json level mapcheck cares about.
+type level struct {
+	Number int         `json:"number"`
+	Map    string      `json:"map"`
+	Spawns []spawn     `json:"spawns"`
+	Win    []objective `json:"win"`
+	Lose   []objective `json:"lose"`
+}
+
+type spawn struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Tile [2]int `json:"tile"`
+}
+
+type objective struct {
+	Type  string   `json:"type"`
+	Units []string `json:"units"`
+	Area  int      `json:"area"`
+}
+
+type report struct {
+	errors   []string
+	warnings []string
+}
+
+func (r *report) errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *report) warnf(format string, args ...any) {
+	r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+}
+
+func main() {
+	levelsPath := flag.String("levels", "levels.json", "levels file inside the embedded data, for spawn points and objectives")
+	flag.Parse()
+
+	// tileset warnings are part of the report, don't print them twice
+	log.SetOutput(io.Discard)
+
+	levels, err := loadLevels(*levelsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mapcheck: %v\n", err)
+		os.Exit(2)
+	}
+	maps := flag.Args()
+	if len(maps) == 0 {
+		maps, _ = fs.Glob(assets.Files, "tilemap/*.tmx") // pattern is fine, can't fail
+	}
+
+	failed := 0
+	for _, path := range maps {
+		var used []level
+		for _, l := range levels {
+			if l.Map == path {
+				used = append(used, l)
+			}
+		}
+		r := checkMap(path, used)
+		switch {
+		case len(r.errors) > 0:
+			failed++
+			fmt.Printf("%v: %v, %v\n", path, count(len(r.errors), "error"), count(len(r.warnings), "warning"))
+		case len(r.warnings) > 0:
+			fmt.Printf("%v: ok, %v\n", path, count(len(r.warnings), "warning"))
+		default:
+			fmt.Printf("%v: ok\n", path)
+		}
+		for _, e := range r.errors {
+			fmt.Printf("  error: %v\n", e)
+		}
+		for _, w := range r.warnings {
+			fmt.Printf("  warning: %v\n", w)
+		}
+	}
+	fmt.Printf("%d maps checked, %d with errors\n", len(maps), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func count(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %v", noun)
+	}
+	return fmt.Sprintf("%d %vs", n, noun)
+}
+
+func loadLevels(path string) ([]level, error) {
+	raw, err := data.Files.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Levels []level `json:"levels"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return file.Levels, nil
+}
+
+func checkMap(path string, levels []level) *report {
+	r := &report{}
+	tm, err := tilemap.LoadTilemap(path)
+	if err != nil {
+		r.errorf("%v", err)
+		return r
+	}
+	for _, warning := range tm.Warnings {
+		r.warnf("%v", warning)
+	}
+	checkTilesets(tm, r)
+	checkGroups(tm, r)
+	checkCollision(tm, r)
+	checkResources(tm, r)
+	checkTriggers(tm, r)
+	if len(levels) == 0 {
+		r.warnf("not used by any level")
+	}
+	for _, l := range levels {
+		checkReachable(tm, l, r)
+	}
+	return r
+}
+
+// checkTilesets makes sure every tile placed on the map has an image the
+// renderer can load.
+func checkTilesets(tm *tilemap.Tilemap, r *report) {
+	raw := tm.GetMap()
+	opened := make(map[string]bool)
+	exists := func(path string) bool {
+		if ok, checked := opened[path]; checked {
+			return ok
+		}
+		f, err := assets.Files.Open(filepath.ToSlash(path))
+		if err == nil {
+			f.Close()
+		}
+		opened[path] = err == nil
+		return err == nil
+	}
+
+	reported := make(map[string]bool)
+	for _, layer := range raw.Layers {
+		for i, t := range layer.Tiles {
+			if t == nil || t.IsNil() {
+				continue
+			}
+			x, y := i%raw.Width, i/raw.Width
+			if t.Tileset == nil {
+				r.errorf("layer %q: tile at %d,%d isn't in any tileset", layer.Name, x, y)
+				continue
+			}
+			source := ""
+			if t.Tileset.Image != nil {
+				source = t.Tileset.Image.Source
+			} else {
+				for _, tile := range t.Tileset.Tiles {
+					if tile.ID == t.ID && tile.Image != nil {
+						source = tile.Image.Source
+					}
+				}
+			}
+			problem := ""
+			if source == "" {
+				problem = fmt.Sprintf("tile %d has no image in tileset %q", t.ID, t.Tileset.Name)
+			} else if !exists(t.Tileset.GetFileFullPath(source)) {
+				problem = fmt.Sprintf("tileset %q image %q is missing", t.Tileset.Name, source)
+			}
+			if problem != "" && !reported[layer.Name+problem] {
+				reported[layer.Name+problem] = true
+				r.errorf("layer %q: %v, first used at %d,%d", layer.Name, problem, x, y)
+			}
+		}
+	}
+}
+
+func checkGroups(tm *tilemap.Tilemap, r *report) {
+	found := make(map[string]bool)
+	for _, group := range tm.GetMap().ObjectGroups {
+		found[group.Name] = true
+	}
+	for _, name := range RequiredGroups {
+		if !found[name] {
+			r.errorf("missing the %q object group", name)
+		}
+	}
+	if found["completion-area"] && len(tm.MapCompletionObjects) == 0 {
+		r.warnf("the completion-area group has no areas")
+	}
+}
+
+// checkCollision looks at the buildable flags: they have to be real bools,
+// and a buildable spot has to be exactly one tile on the grid, since that's
+// the only rect the construction cursor snaps to.
+func checkCollision(tm *tilemap.Tilemap, r *report) {
+	bounds := image.Rect(0, 0, tm.Width*tm.TileSize, tm.Height*tm.TileSize)
+	for _, group := range tm.GetMap().ObjectGroups {
+		if group.Name != "collision" {
+			continue
+		}
+		for _, object := range group.Objects {
+			rect := image.Rect(int(object.X), int(object.Y), int(object.X+object.Width), int(object.Y+object.Height))
+			if !rect.In(bounds) {
+				r.warnf("collision object %d at %v sticks out of the map", object.ID, rect)
+			}
+			for _, property := range object.Properties {
+				switch {
+				case property.Name != "buildable":
+					r.warnf("collision object %d: unknown property %q", object.ID, property.Name)
+				case property.Type != "bool":
+					r.errorf("collision object %d: buildable should be a bool, it's %q", object.ID, property.Value)
+				}
+			}
+			if !object.Properties.GetBool("buildable") {
+				continue
+			}
+			if rect.Min.X%tm.TileSize != 0 || rect.Min.Y%tm.TileSize != 0 || rect.Dx() != tm.TileSize || rect.Dy() != tm.TileSize {
+				r.errorf("collision object %d: buildable area %v isn't a single tile on the grid, bridges can't be placed on it", object.ID, rect)
+			}
+		}
+	}
+}
+
+func checkResources(tm *tilemap.Tilemap, r *report) {
+	found := make(map[string]int)
+	for _, column := range tm.Tiles {
+		for _, tile := range column {
+			if tile.Resource != "" {
+				found[tile.Resource]++
+			}
+		}
+	}
+	if len(found) == 0 {
+		r.errorf("no resources on the map")
+		return
+	}
+	for _, resource := range []string{tilemap.ResourceSucrose, tilemap.ResourceWood} {
+		if found[resource] == 0 {
+			r.warnf("no %v on the map", resource)
+		}
+	}
+}
+
+// checkTriggers makes sure what a trigger's script points at exists. Bad
+// on_enter values and such are already in the map's warnings.
+func checkTriggers(tm *tilemap.Tilemap, r *report) {
+	for _, trigger := range tm.MapTriggers {
+		switch trigger.OnEnter {
+		case "cutscene":
+			if _, err := fs.Stat(data.Files, trigger.Script); err != nil {
+				r.errorf("trigger %d: cutscene %q isn't in the data files", trigger.ID, trigger.Script)
+			}
+		case "spawn":
+			if _, err := sim.ParseTriggerSpawn(trigger.Script); err != nil {
+				r.errorf("trigger %d: %v", trigger.ID, err)
+			}
+		case "tutorial":
+			if _, err := fs.Stat(assets.Files, trigger.Script); err != nil {
+				r.errorf("trigger %d: tutorial image %q isn't in the assets", trigger.ID, trigger.Script)
+			}
+		case "reveal":
+			if !tm.HasLayer(trigger.Script) {
+				r.errorf("trigger %d: no tile layer %q to reveal", trigger.ID, trigger.Script)
+			}
+		}
+	}
+}
+
+// checkReachable makes sure the units a level's units_in_area objectives
+// name can walk from their spawn to the area, with every buildable spot
+// bridged.
+func checkReachable(tm *tilemap.Tilemap, l level, r *report) {
+	spawns := make(map[string]image.Point)
+	for _, sp := range l.Spawns {
+		if sp.Name != "" {
+			spawns[sp.Name] = image.Pt(sp.Tile[0], sp.Tile[1])
+		}
+	}
+	walkable := walkableGrid(tm)
+	for _, o := range slices.Concat(l.Win, l.Lose) {
+		if o.Type != "units_in_area" {
+			continue
+		}
+		if o.Area < 0 || o.Area >= len(tm.MapCompletionObjects) {
+			r.errorf("level %d: completion area %d isn't on the map, it has %d", l.Number, o.Area, len(tm.MapCompletionObjects))
+			continue
+		}
+		area := *tm.MapCompletionObjects[o.Area].Rect
+		for _, name := range o.Units {
+			from, ok := spawns[name]
+			if !ok {
+				r.errorf("level %d: %q isn't spawned", l.Number, name)
+				continue
+			}
+			if !reachable(tm, walkable, from, area) {
+				r.errorf("level %d: %q can't get from tile %v to completion area %d, even with bridges", l.Number, name, from, o.Area)
+			}
+		}
+	}
+}
+
+// walkableGrid is the map with every bridge built: impassable tiles and
+// collision block, buildable spots don't.
+func walkableGrid(tm *tilemap.Tilemap) [][]bool {
+	walkable := make([][]bool, tm.Width)
+	for x := range walkable {
+		walkable[x] = make([]bool, tm.Height)
+		for y := range walkable[x] {
+			walkable[x][y] = tm.Tiles[x][y].Walkable
+		}
+	}
+	mark := func(rect *image.Rectangle, value bool) {
+		for x := max(rect.Min.X/tm.TileSize, 0); x <= min((rect.Max.X-1)/tm.TileSize, tm.Width-1); x++ {
+			for y := max(rect.Min.Y/tm.TileSize, 0); y <= min((rect.Max.Y-1)/tm.TileSize, tm.Height-1); y++ {
+				walkable[x][y] = value
+			}
+		}
+	}
+	for _, mo := range tm.MapObjects {
+		if !mo.IsBuildable {
+			mark(mo.Rect, false)
+		}
+	}
+	for _, mo := range tm.MapObjects {
+		if mo.IsBuildable {
+			mark(mo.Rect, true)
+		}
+	}
+	return walkable
+}
+
+// reachable floods out from the start tile until it touches area. Diagonal
+// steps need both sides open in the sim, so straight steps are enough here.
+func reachable(tm *tilemap.Tilemap, walkable [][]bool, from image.Point, area image.Rectangle) bool {
+	inMap := func(p image.Point) bool {
+		return p.X >= 0 && p.Y >= 0 && p.X < tm.Width && p.Y < tm.Height
+	}
+	if !inMap(from) {
+		return false
+	}
+	seen := map[image.Point]bool{from: true}
+	queue := []image.Point{from}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		tile := image.Rect(p.X*tm.TileSize, p.Y*tm.TileSize, (p.X+1)*tm.TileSize, (p.Y+1)*tm.TileSize)
+		if tile.Overlaps(area) {
+			return true
+		}
+		for _, d := range []image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			next := p.Add(d)
+			if inMap(next) && !seen[next] && walkable[next.X][next.Y] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+// Command simrun runs the simulation without a window and prints a JSON
+// summary, e.g. for checking balance or catching regressions in scripts.
+//
+//	go run ./cmd/simrun -level 1 -ticks 36000
+//
+// Levels come from data/levels.json, same as the game. A level counts as
+// completed once its units_in_area win objectives are met, the other kinds
+// need the game to tell.
+//
+// With -record the run's sim.Recording is written out, and -replay plays a
+// recording back on the same level and fails if the sim state diverges.
+// -record-events writes everything published on the event bus as JSON lines,
+// and -inject publishes such a log again at the ticks it was recorded at.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gamejam/eventing"
+	"gamejam/level"
+	"gamejam/log"
+	"gamejam/sim"
+	"gamejam/tilemap"
+	"image"
+	"io"
+	"os"
+)
+
+type summary struct {
+	Level           int              `json:"level"`
+	Map             string           `json:"map"`
+	Ticks           int              `json:"ticks"`
+	Completed       bool             `json:"completed"`
+	CompletedAtTick int              `json:"completed_at_tick,omitempty"`
+	Checksum        string           `json:"checksum"`
+	Factions        []factionSummary `json:"factions"`
+}
+
+type factionSummary struct {
+	Faction     uint           `json:"faction"`
+	Sucrose     uint16         `json:"sucrose"`
+	Wood        uint16         `json:"wood"`
+	Units       int            `json:"units"`
+	UnitsByKind map[string]int `json:"units_by_kind"`
+	Buildings   int            `json:"buildings"`
+	Supply      [2]uint16      `json:"supply"` // used, available
+}
+
+func main() {
+	levelNumber := flag.Int("level", 0, "number of the level in data/levels.json to run")
+	ticks := flag.Int("ticks", 60*60, "number of sim ticks to run, 60 per second")
+	untilComplete := flag.Bool("until-complete", false, "stop early once the completion condition is met")
+	seed := flag.Uint64("seed", sim.DefaultSeed, "seed for the sim's randomness")
+	recordPath := flag.String("record", "", "write the run's recording to this file")
+	replayPath := flag.String("replay", "", "replay a recording instead of running -ticks, fails on desync")
+	eventsPath := flag.String("record-events", "", "write every published event to this file as JSON lines")
+	injectPath := flag.String("inject", "", "publish the events in this event log at their ticks, see cmd/eventlog")
+	flag.Parse()
+
+	log.Output = os.Stderr // stdout is for the summary
+
+	opts := runOptions{ticks: *ticks, untilComplete: *untilComplete, seed: *seed, recordPath: *recordPath, eventsPath: *eventsPath}
+	if *replayPath != "" {
+		data, err := os.ReadFile(*replayPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "simrun: %v\n", err)
+			os.Exit(1)
+		}
+		opts.replay = &sim.Recording{}
+		if err := json.Unmarshal(data, opts.replay); err != nil {
+			fmt.Fprintf(os.Stderr, "simrun: %v: %v\n", *replayPath, err)
+			os.Exit(1)
+		}
+		opts.seed = opts.replay.Seed
+	}
+	if *injectPath != "" {
+		f, err := os.Open(*injectPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "simrun: %v\n", err)
+			os.Exit(1)
+		}
+		opts.inject, err = eventing.ReadLog(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "simrun: %v: %v\n", *injectPath, err)
+			os.Exit(1)
+		}
+	}
+	result, err := run(*levelNumber, opts)
+	if err == nil {
+		err = writeSummary(os.Stdout, result)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simrun: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func writeSummary(w io.Writer, result *summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+type runOptions struct {
+	ticks         int
+	untilComplete bool
+	seed          uint64
+	recordPath    string
+	replay        *sim.Recording
+	eventsPath    string
+	inject        []eventing.Recorded
+}
+
+func run(levelNumber int, opts runOptions) (*summary, error) {
+	levels, err := level.Read(nil)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := levels.Levels[levelNumber]
+	if !ok {
+		return nil, fmt.Errorf("no level %d in %v", levelNumber, level.File)
+	}
+
+	tm, err := tilemap.LoadTilemap(l.TileMapPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading map %v: %w", l.TileMapPath, err)
+	}
+	s := sim.NewSeeded(60, tm, opts.seed)
+	if opts.eventsPath != "" {
+		f, err := os.Create(opts.eventsPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		recorder := eventing.NewRecorder(f, s.GetTick)
+		s.EventBus.SetRecorder(recorder)
+		defer func() {
+			if err := recorder.Err(); err != nil {
+				fmt.Fprintf(os.Stderr, "simrun: recording events: %v\n", err)
+			}
+		}()
+	}
+	named := l.Place(s)
+	for _, c := range l.Controllers {
+		s.AddController(c.NewController())
+	}
+
+	var completion []completionCheck
+	for _, objective := range l.Win {
+		if objective.Type == "units_in_area" {
+			completion = append(completion, completionCheck{units: objective.Units, area: tm.MapCompletionObjects[objective.Area].Rect})
+		}
+	}
+
+	result := &summary{Level: levelNumber, Map: l.TileMapPath}
+	if opts.replay != nil {
+		if err := s.Replay(opts.replay); err != nil {
+			return nil, err
+		}
+		result.Ticks = int(s.GetTick())
+		result.Completed = isComplete(completion, named)
+	}
+	if opts.inject != nil {
+		if err := s.InjectEvents(opts.inject); err != nil {
+			return nil, err
+		}
+		result.Ticks = int(s.GetTick())
+	}
+	for opts.replay == nil && result.Ticks < opts.ticks {
+		s.Update()
+		result.Ticks++
+		if !result.Completed && isComplete(completion, named) {
+			result.Completed = true
+			result.CompletedAtTick = result.Ticks
+			if opts.untilComplete {
+				break
+			}
+		}
+	}
+	result.Checksum = fmt.Sprintf("%016x", s.Checksum())
+
+	if opts.recordPath != "" {
+		data, err := json.Marshal(s.GetRecording())
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(opts.recordPath, data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, faction := range []int{sim.PlayerFaction, sim.EnemyFaction, sim.NeutralFaction} {
+		fs := factionSummary{Faction: uint(faction), UnitsByKind: make(map[string]int)}
+		state := s.GetEnemyState() // every non-player faction shares one pool
+		if faction == sim.PlayerFaction {
+			state = s.GetPlayerState()
+		}
+		if faction != sim.NeutralFaction {
+			fs.Sucrose, fs.Wood = state.Sucrose, state.Wood
+		}
+		for _, u := range s.GetUnitsByFaction(uint(faction)) {
+			fs.Units++
+			fs.UnitsByKind[sim.UnitKind(u.Type)]++
+		}
+		fs.Buildings = len(s.GetBuildingsByFaction(uint(faction)))
+		fs.Supply[0], fs.Supply[1] = s.Supply(uint(faction))
+		result.Factions = append(result.Factions, fs)
+	}
+	return result, nil
+}
+
+// completionCheck is a units_in_area objective: it's met once every named
+// unit overlaps the area.
+type completionCheck struct {
+	units []string
+	area  *image.Rectangle
+}
+
+func isComplete(checks []completionCheck, named map[string]*sim.Unit) bool {
+	if len(checks) == 0 {
+		return false
+	}
+	for _, check := range checks {
+		for _, name := range check.units {
+			u := named[name]
+			if u.IsDead() || !check.area.Overlaps(*u.Rect) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -15,6 +15,9 @@ type T struct {
 	StartingLevel int    `json:"startingLevel"`
 	DebugDraw     bool   `json:"debugDraw"`
 	MuteAudio     bool   `json:"muteAudio"`
+	RecordReplays bool   `json:"recordReplays"` // write a replay of every finished level
+	DebugEvents   bool   `json:"debugEvents"`   // log everything that goes over the event bus
+	RecordEvents  bool   `json:"recordEvents"`  // write an event log of every finished level, see cmd/eventlog
 	Resolutions   struct {
 		Internal Resolution `json:"internal"`
 		External Resolution `json:"external"`
@@ -1,25 +1,41 @@
 package eventing
 
 import (
-	"fmt"
+	"gamejam/log"
 	"image"
-	"time"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
 )
 
 // based on https://medium.com/@souravchoudhary0306/implementation-of-event-driven-architecture-in-go-golang-28d9a1c01f91
 
-// Events
-// StartSimulation
-// StopSimulation
-type Event struct {
-	Type      string
-	Timestamp time.Time
-	Data      interface{}
+// Events are plain structs, the type is the topic. The empty ones are only
+// about something having happened.
+
+// MakeUnitButtonClickedEvent is sent by the hive buttons, UnitType is a
+// sim.UnitType.
+type MakeUnitButtonClickedEvent struct {
+	UnitType int
 }
 
+type CancelConstructionButtonClickedEvent struct{}
+
+// CancelQueuedUnitButtonClickedEvent is sent by the build queue icons, Slot
+// 0 is the unit being made.
+type CancelQueuedUnitButtonClickedEvent struct {
+	Slot int
+}
+
+type PlayIssueActionSFXEvent struct{}
+
+type PlaySelectHiveSFXEvent struct{}
+
 type NotEnoughResourcesEvent struct {
 	ResourceName     string
 	TargetBeingBuilt string
+	Reason           string // set when it's not only resources that are missing, e.g. a full build queue or supply
 }
 
 type SceneCompletionEvent struct {
@@ -29,50 +45,171 @@ type SceneCompletionEvent struct {
 
 type BuildClickedEvent struct {
 	TargetRect *image.Rectangle
-	// building type
+	Building   int // sim.BuildingType
+}
+
+// MakeBuildingButtonClickedEvent is sent by the build menu, Building is a
+// sim.BuildingType.
+type MakeBuildingButtonClickedEvent struct {
+	Building int
 }
 
 type ConstructUnitEvent struct {
-	HiveID string
+	HiveID   string
+	UnitType int // a sim.UnitType the hive makes
+}
+
+// CommandButtonClickedEvent is sent by the unit command buttons, Command is
+// one of "move", "attack", "stop" or "hold".
+type CommandButtonClickedEvent struct {
+	Command string
 }
 
 type ToggleRightSideHUDEvent struct {
 	Show bool
 }
 
+type UnitDiedEvent struct {
+	UnitID   string
+	KillerID string
+	Faction  uint
+	Position *image.Point // centered position the unit died at
+}
+
+// BuildingDestroyedEvent is sent when a building is knocked down, Type is a
+// sim.BuildingType.
+type BuildingDestroyedEvent struct {
+	BuildingID string
+	Type       int
+	Faction    uint
+	Position   *image.Point // centered position of where it stood
+}
+
+// ResourceNodeEvent is what ResourceDepletedEvent and ResourceRegrownEvent
+// carry, Tile is in tile coordinates.
+type ResourceNodeEvent struct {
+	Tile     image.Point
+	Resource string
+}
+
+type ResourceDepletedEvent ResourceNodeEvent
+
+type ResourceRegrownEvent ResourceNodeEvent
+
+// TriggerEnteredEvent is sent when a unit walks into a map trigger zone, see
+// tilemap.MapTrigger for what OnEnter and Script mean.
+type TriggerEnteredEvent struct {
+	TriggerID int
+	Name      string
+	OnEnter   string
+	Script    string
+	UnitID    string
+}
+
+// EventBus passes events between the sim, scenes and UI, see Subscribe and
+// Publish. It's safe to use from several goroutines, handlers run on the one
+// that publishes.
 type EventBus struct {
-	subscribers map[string][]func(event Event)
+	mu          sync.RWMutex
+	subscribers map[reflect.Type][]*Subscription
+	nextID      uint64
+
+	deferredMu sync.Mutex
+	deferred   []func()
+
+	log      *slog.Logger // nil unless SetDebug is on
+	recorder *Recorder    // nil unless SetRecorder was given one
+}
+
+// Subscription is one handler on the bus, keep it around to Unsubscribe.
+type Subscription struct {
+	bus     *EventBus
+	topic   reflect.Type
+	id      uint64
+	handler any // func(T) for the topic T
 }
 
 func NewEventBus() *EventBus {
 	return &EventBus{
-		subscribers: make(map[string][]func(event Event)),
+		subscribers: make(map[reflect.Type][]*Subscription),
+	}
+}
+
+// SetDebug logs every subscription and published event when on.
+func (eb *EventBus) SetDebug(on bool) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.log = nil
+	if on {
+		eb.log = log.NewLogger().With("for", "EventBus")
 	}
 }
 
-func (eb *EventBus) Subscribe(eventType string, handler func(event Event)) {
-	fmt.Printf("EventBus: Event Subscribed To: %v\n", eventType)
-	eb.subscribers[eventType] = append(eb.subscribers[eventType], handler)
+// Subscribe calls handler with every T published on the bus until the
+// subscription is dropped.
+func Subscribe[T any](eb *EventBus, handler func(T)) *Subscription {
+	topic := reflect.TypeFor[T]()
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.nextID++
+	sub := &Subscription{bus: eb, topic: topic, id: eb.nextID, handler: handler}
+	eb.subscribers[topic] = append(eb.subscribers[topic], sub)
+	if eb.log != nil {
+		eb.log.Info("subscribed", "topic", topic.String(), "id", sub.id)
+	}
+	return sub
 }
 
-func (eb *EventBus) GetSubscribers(eventType string) []func(event Event) {
-	return eb.subscribers[eventType]
+// Unsubscribe drops only this handler, others on the same topic stay. It's
+// fine to call more than once.
+func (sub *Subscription) Unsubscribe() {
+	eb := sub.bus
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.subscribers[sub.topic] = slices.DeleteFunc(slices.Clone(eb.subscribers[sub.topic]), func(other *Subscription) bool {
+		return other == sub
+	})
+	if len(eb.subscribers[sub.topic]) == 0 {
+		delete(eb.subscribers, sub.topic)
+	}
 }
 
-func (eb *EventBus) Unsubscribe(eventType string) {
-	if _, exists := eb.subscribers[eventType]; exists {
-		delete(eb.subscribers, eventType)
-	} else {
-		fmt.Printf("EventBus: No subscribers found for event type: %s\n", eventType)
+// Publish calls every handler of T with event right away, in the order they
+// subscribed. Handlers can publish, subscribe and unsubscribe themselves,
+// changes show up from the next Publish on.
+func Publish[T any](eb *EventBus, event T) {
+	topic := reflect.TypeFor[T]()
+	eb.mu.RLock()
+	subs := eb.subscribers[topic] // never changed in place, see Unsubscribe
+	logger, recorder := eb.log, eb.recorder
+	eb.mu.RUnlock()
+	if logger != nil {
+		logger.Info("published", "topic", topic.String(), "handlers", len(subs), "event", event)
 	}
+	if recorder != nil {
+		recorder.record(topic, len(subs), event)
+	}
+	for _, sub := range subs {
+		sub.handler.(func(T))(event)
+	}
+}
+
+// PublishDeferred holds on to event until the next Drain, e.g. so handlers
+// don't run in the middle of a sim tick.
+func PublishDeferred[T any](eb *EventBus, event T) {
+	eb.deferredMu.Lock()
+	defer eb.deferredMu.Unlock()
+	eb.deferred = append(eb.deferred, func() { Publish(eb, event) })
 }
 
-// Publish sends an event to all subscribers of a given event type
-func (eb *EventBus) Publish(event Event) {
-	fmt.Printf("EventBus: Event Published: %v handlers: %v\n", event, len(eb.subscribers[event.Type]))
-	handlers := eb.subscribers[event.Type]
-	for _, handler := range handlers {
-		fmt.Printf("EventBus: Calling handler for event type: %s, length: %v\n", event.Type, len(handlers))
-		handler(event)
+// Drain publishes everything deferred since the last Drain, in order. Events
+// handlers defer while it runs wait for the next one.
+func (eb *EventBus) Drain() {
+	eb.deferredMu.Lock()
+	deferred := eb.deferred
+	eb.deferred = nil
+	eb.deferredMu.Unlock()
+	for _, publish := range deferred {
+		publish()
 	}
 }
@@ -0,0 +1,168 @@
+package eventing
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type ping struct{ N int }
+
+type pong struct{ N int }
+
+func TestSubscribeUnsubscribe(t *testing.T) {
+	eb := NewEventBus()
+	var got []int
+	first := Subscribe(eb, func(p ping) { got = append(got, p.N) })
+	second := Subscribe(eb, func(p ping) { got = append(got, -p.N) })
+	pongs := 0
+	Subscribe(eb, func(pong) { pongs++ })
+
+	Publish(eb, ping{1})
+	first.Unsubscribe()
+	first.Unsubscribe() // twice is fine
+	Publish(eb, ping{2})
+	second.Unsubscribe()
+	Publish(eb, ping{3})
+
+	if want := []int{1, -1, -2}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if pongs != 0 {
+		t.Errorf("pong handler called %d times for pings", pongs)
+	}
+}
+
+func TestHandlerUnsubscribesItself(t *testing.T) {
+	eb := NewEventBus()
+	calls, pongs := 0, 0
+	Subscribe(eb, func(pong) { pongs++ })
+	var self *Subscription
+	self = Subscribe(eb, func(p ping) {
+		calls++
+		self.Unsubscribe()
+		Publish(eb, pong{p.N})
+	})
+	Publish(eb, ping{1})
+	Publish(eb, ping{2})
+	if calls != 1 || pongs != 1 {
+		t.Errorf("got %d calls and %d pongs, want 1 and 1", calls, pongs)
+	}
+}
+
+func TestPublishDeferred(t *testing.T) {
+	eb := NewEventBus()
+	var got []int
+	Subscribe(eb, func(p ping) {
+		got = append(got, p.N)
+		if p.N < 10 {
+			PublishDeferred(eb, ping{p.N + 10}) // waits for the next Drain
+		}
+	})
+	PublishDeferred(eb, ping{1})
+	PublishDeferred(eb, ping{2})
+	PublishDeferred(eb, ping{3})
+	if len(got) != 0 {
+		t.Fatalf("handlers ran before Drain: %v", got)
+	}
+
+	eb.Drain()
+	if want := []int{1, 2, 3}; !slices.Equal(got, want) {
+		t.Fatalf("after first Drain got %v, want %v", got, want)
+	}
+	eb.Drain()
+	if want := []int{1, 2, 3, 11, 12, 13}; !slices.Equal(got, want) {
+		t.Fatalf("after second Drain got %v, want %v", got, want)
+	}
+	eb.Drain()
+	if len(got) != 6 {
+		t.Errorf("empty Drain published something: %v", got)
+	}
+}
+
+// Run with -race.
+func TestConcurrentPublish(t *testing.T) {
+	eb := NewEventBus()
+	var total atomic.Int64
+	Subscribe(eb, func(p pong) { total.Add(int64(p.N)) })
+
+	const workers, events = 8, 200
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range events {
+				// churn subscriptions on the same topic while publishing
+				sub := Subscribe(eb, func(pong) {})
+				Publish(eb, pong{1})
+				PublishDeferred(eb, pong{1})
+				sub.Unsubscribe()
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range events {
+			eb.Drain()
+		}
+	}()
+	wg.Wait()
+	<-done
+	eb.Drain()
+
+	if want := int64(2 * workers * events); total.Load() != want {
+		t.Errorf("handled %d events, want %d", total.Load(), want)
+	}
+}
+
+func TestRecorderRoundTrip(t *testing.T) {
+	eb := NewEventBus()
+	var buf bytes.Buffer
+	tick := uint64(5)
+	recorder := NewRecorder(&buf, func() uint64 { return tick })
+	eb.SetRecorder(recorder)
+	Subscribe(eb, func(UnitDiedEvent) {})
+	Publish(eb, UnitDiedEvent{UnitID: "a", KillerID: "b"})
+	PublishDeferred(eb, ConstructUnitEvent{HiveID: "h"})
+	tick = 6
+	eb.Drain()
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := ReadLog(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d records, want 2", len(recs))
+	}
+	if recs[0].Type != "UnitDiedEvent" || recs[0].Tick != 5 || recs[0].Handlers != 1 {
+		t.Errorf("first record is %+v", recs[0])
+	}
+	// deferred events are recorded when they're drained
+	if recs[1].Type != "ConstructUnitEvent" || recs[1].Tick != 6 || recs[1].Handlers != 0 {
+		t.Errorf("second record is %+v", recs[1])
+	}
+
+	replay := NewEventBus()
+	var died UnitDiedEvent
+	var built ConstructUnitEvent
+	Subscribe(replay, func(e UnitDiedEvent) { died = e })
+	Subscribe(replay, func(e ConstructUnitEvent) { built = e })
+	for _, rec := range recs {
+		if err := Republish(replay, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if died.UnitID != "a" || died.KillerID != "b" || built.HiveID != "h" {
+		t.Errorf("republished %+v and %+v", died, built)
+	}
+	if Republish(replay, Recorded{Type: "NoSuchEvent"}) == nil {
+		t.Error("republishing an unknown type didn't fail")
+	}
+}
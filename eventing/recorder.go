@@ -0,0 +1,132 @@
+package eventing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Recorded is one line of an event log, see Recorder.
+type Recorded struct {
+	Time     time.Time       `json:"time"`
+	Tick     uint64          `json:"tick"`
+	Type     string          `json:"type"` // e.g. "UnitDiedEvent"
+	Handlers int             `json:"handlers"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// Recorder writes every event published on a bus as a line of JSON, when it
+// went out to its handlers. Set it with EventBus.SetRecorder.
+type Recorder struct {
+	mu   sync.Mutex
+	enc  *json.Encoder
+	tick func() uint64
+	err  error
+}
+
+// NewRecorder writes to w, tick says what sim tick it is, e.g. sim.T.GetTick.
+func NewRecorder(w io.Writer, tick func() uint64) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w), tick: tick}
+}
+
+// Err is the first write that failed, the recorder stops after it.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(topic reflect.Type, handlers int, event any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	data, err := json.Marshal(event)
+	if err == nil {
+		err = r.enc.Encode(Recorded{
+			Time:     time.Now(),
+			Tick:     r.tick(),
+			Type:     topic.Name(),
+			Handlers: handlers,
+			Event:    data,
+		})
+	}
+	r.err = err
+}
+
+// SetRecorder starts recording what's published on the bus, nil stops it.
+func (eb *EventBus) SetRecorder(r *Recorder) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.recorder = r
+}
+
+// ReadLog reads an event log written by a Recorder.
+func ReadLog(r io.Reader) ([]Recorded, error) {
+	var records []Recorded
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Recorded
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// republishers decode a recorded event back into its type and publish it,
+// by type name. Every event in this package is here.
+var republishers = map[string]func(eb *EventBus, data json.RawMessage) error{}
+
+func republisher[T any]() {
+	republishers[reflect.TypeFor[T]().Name()] = func(eb *EventBus, data json.RawMessage) error {
+		var event T
+		if err := json.Unmarshal(data, &event); err != nil {
+			return err
+		}
+		Publish(eb, event)
+		return nil
+	}
+}
+
+func init() {
+	republisher[MakeUnitButtonClickedEvent]()
+	republisher[CancelConstructionButtonClickedEvent]()
+	republisher[CancelQueuedUnitButtonClickedEvent]()
+	republisher[PlayIssueActionSFXEvent]()
+	republisher[PlaySelectHiveSFXEvent]()
+	republisher[NotEnoughResourcesEvent]()
+	republisher[SceneCompletionEvent]()
+	republisher[BuildClickedEvent]()
+	republisher[MakeBuildingButtonClickedEvent]()
+	republisher[ConstructUnitEvent]()
+	republisher[CommandButtonClickedEvent]()
+	republisher[ToggleRightSideHUDEvent]()
+	republisher[UnitDiedEvent]()
+	republisher[BuildingDestroyedEvent]()
+	republisher[ResourceDepletedEvent]()
+	republisher[ResourceRegrownEvent]()
+	republisher[TriggerEnteredEvent]()
+}
+
+// Republish publishes a recorded event on the bus again, as its own type.
+func Republish(eb *EventBus, rec Recorded) error {
+	republish, ok := republishers[rec.Type]
+	if !ok {
+		return fmt.Errorf("unknown event type %q", rec.Type)
+	}
+	if err := republish(eb, rec.Event); err != nil {
+		return fmt.Errorf("%v: %w", rec.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Package level is the levels file, what's in it and how it's checked. The
+// game and cmd/simrun both load their levels through it.
+package level
+
+import "gamejam/sim"
+
+// BuildingKinds are the building kinds spawns can use, units are
+// sim.UnitKinds.
+var BuildingKinds = map[string]func() sim.BuildingInterface{
+	"hive":            sim.NewHive,
+	"roach_hive":      sim.NewRoachHive,
+	"storage_depot":   sim.NewStorageDepot,
+	"barracks":        sim.NewBarracks,
+	"defensive_mound": sim.NewDefensiveMound,
+	"nest":            sim.NewNest,
+}
+
+// Factions are the faction names levels use, no name is the player.
+var Factions = map[string]int{
+	"":        sim.PlayerFaction,
+	"player":  sim.PlayerFaction,
+	"enemy":   sim.EnemyFaction,
+	"neutral": sim.NeutralFaction,
+}
+
+// ControllerTypes are the AIs a level can hand a faction to.
+var ControllerTypes = map[string]func(faction uint) sim.Controller{
+	"roach_ai": func(faction uint) sim.Controller { return sim.NewRoachAI(faction) },
+}
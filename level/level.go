@@ -0,0 +1,392 @@
+package level
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gamejam/assets"
+	"gamejam/data"
+	"gamejam/sim"
+	"gamejam/tilemap"
+	"image"
+	"io/fs"
+	"maps"
+	"slices"
+)
+
+// File is where the levels live inside the embedded data files.
+var File = "levels.json"
+
+// Level is one level from File. Units and cutscenes refer to each other by
+// the names given in Spawns, so writers never deal with unit IDs.
+type Level struct {
+	LevelNumber        int            `json:"number"`
+	TileMapPath        string         `json:"map"`
+	LevelIntroText     string         `json:"intro"`
+	Camera             Camera         `json:"camera"`
+	Spawns             []Spawn        `json:"spawns"`
+	Controllers        []Controller   `json:"controllers"`    // AIs that play factions
+	Win                []Objective    `json:"win"`            // all must be met to win
+	Lose               []Objective    `json:"lose"`           // any one loses the level
+	IntroCutscene      string         `json:"intro_cutscene"` // script in the data files, see scene.ParseCutscene
+	IntroTutorial      []TutorialStep `json:"intro_tutorial"`
+	CompletionCutscene string         `json:"completion_cutscene"`
+	CompletionTutorial []TutorialStep `json:"completion_tutorial"`
+}
+
+// Camera is where the camera starts, in map pixels.
+type Camera struct {
+	X     int     `json:"x"`
+	Y     int     `json:"y"`
+	Zoom  float64 `json:"zoom"`  // defaults to ui.MinZoom
+	Faded bool    `json:"faded"` // start black, e.g. for a fade in cutscene
+}
+
+type Spawn struct {
+	Name    string `json:"name"` // optional, for cutscenes and the completion condition
+	Kind    string `json:"kind"` // see sim.UnitKinds and BuildingKinds
+	Tile    []int  `json:"tile"`
+	Faction string `json:"faction"` // see Factions, defaults to player
+}
+
+// Controller hands a faction to an AI, e.g. {"type": "roach_ai", "faction":
+// "enemy"}.
+type Controller struct {
+	Type    string `json:"type"`    // see ControllerTypes
+	Faction string `json:"faction"` // see Factions, enemy or neutral
+}
+
+// Objective is one win or lose condition, which fields matter depends on
+// Type:
+//
+//	units_in_area    units, area (index of the map's completion areas)
+//	unit_killed      unit
+//	hives_destroyed  faction
+//	timer            seconds
+//	resource         faction, resource (sucrose or wood), amount, below
+//
+// Text is what the defeat screen says when it's a lose condition.
+type Objective struct {
+	Type     string   `json:"type"`
+	Text     string   `json:"text"`
+	Units    []string `json:"units"`
+	Unit     string   `json:"unit"`
+	Area     int      `json:"area"`
+	Faction  string   `json:"faction"`
+	Seconds  float64  `json:"seconds"`
+	Resource string   `json:"resource"`
+	Amount   int      `json:"amount"`
+	Below    bool     `json:"below"`
+}
+
+// TutorialStep is a tutorial image shown in Rect (x0, y0, x1, y1 on screen)
+// once Trigger is met, until Complete is. A missing Trigger shows it right
+// away and a missing Complete waits for a click.
+type TutorialStep struct {
+	Image    string     `json:"image"`
+	Rect     []int      `json:"rect"`
+	Trigger  *Condition `json:"trigger"`
+	Complete *Condition `json:"complete"`
+}
+
+// Condition is something the game checks while playing, the names are up to
+// the game, see Check.
+type Condition struct {
+	Condition string `json:"condition"`
+	Amount    int    `json:"amount"`
+}
+
+type Collection struct {
+	Levels map[int]Level
+}
+
+// Check is what the game checks about a level on top of Parse, e.g. that
+// its cutscenes parse. tm is the level's map, nil if it didn't load.
+type Check func(l *Level, tm *tilemap.Tilemap) []error
+
+// Read parses File from the embedded data files, see Parse.
+func Read(check Check) (*Collection, error) {
+	raw, err := data.Files.ReadFile(File)
+	if err != nil {
+		return nil, fmt.Errorf("opening levels file: %w", err)
+	}
+	coll, err := Parse(raw, check)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", File, err)
+	}
+	return coll, nil
+}
+
+// Parse decodes a levels file and reports every problem found in it, not
+// just the first. check is run on every level too, nil skips it.
+func Parse(raw []byte, check Check) (*Collection, error) {
+	var file struct {
+		Levels []Level `json:"levels"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields() // catches misspelt keys
+	if err := dec.Decode(&file); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("line %d: %w", lineOf(raw, syntaxErr.Offset), err)
+		} else if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("line %d: %w", lineOf(raw, typeErr.Offset), err)
+		}
+		return nil, err
+	}
+
+	coll := &Collection{
+		Levels: make(map[int]Level),
+	}
+	var errs []error
+	for _, l := range file.Levels {
+		if _, ok := coll.Levels[l.LevelNumber]; ok {
+			errs = append(errs, fmt.Errorf("level %d: number used more than once", l.LevelNumber))
+			continue
+		}
+		for _, err := range l.validate(check) {
+			errs = append(errs, fmt.Errorf("level %d: %w", l.LevelNumber, err))
+		}
+		coll.Levels[l.LevelNumber] = l
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return coll, nil
+}
+
+// Names returns the names given to spawns.
+func (l *Level) Names() map[string]bool {
+	names := make(map[string]bool)
+	for _, sp := range l.Spawns {
+		if sp.Name != "" {
+			names[sp.Name] = true
+		}
+	}
+	return names
+}
+
+func (l *Level) validate(check Check) []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	completionAreas := -1 // unknown when the map doesn't load
+	var tm *tilemap.Tilemap
+	if l.TileMapPath == "" {
+		fail("map is missing")
+	} else if loaded, err := tilemap.LoadTilemap(l.TileMapPath); err != nil {
+		fail("map %q: %w", l.TileMapPath, err)
+	} else {
+		tm = loaded
+		completionAreas = len(tm.MapCompletionObjects)
+	}
+	if l.Camera.Zoom < 0 {
+		fail("camera: zoom %v is negative", l.Camera.Zoom)
+	}
+
+	names := make(map[string]bool)
+	for i, sp := range l.Spawns {
+		_, isUnit := sim.UnitKinds[sp.Kind]
+		_, isBuilding := BuildingKinds[sp.Kind]
+		if !isUnit && !isBuilding {
+			fail("spawn %d: unknown kind %q", i, sp.Kind)
+		}
+		if err := checkPair(sp.Tile); err != nil {
+			fail("spawn %d: tile %w", i, err)
+		}
+		if _, ok := Factions[sp.Faction]; !ok {
+			fail("spawn %d: unknown faction %q, expected player, enemy or neutral", i, sp.Faction)
+		}
+		if sp.Name == "" {
+			continue
+		}
+		if isBuilding {
+			fail("spawn %d: only units can be named, %q is a building", i, sp.Kind)
+		}
+		if names[sp.Name] {
+			fail("spawn %d: name %q used more than once", i, sp.Name)
+		}
+		names[sp.Name] = true
+	}
+
+	controlled := make(map[int]bool)
+	for i, c := range l.Controllers {
+		if _, ok := ControllerTypes[c.Type]; !ok {
+			fail("controller %d: unknown type %q, expected one of %v", i, c.Type, slices.Sorted(maps.Keys(ControllerTypes)))
+		}
+		faction, ok := Factions[c.Faction]
+		if !ok {
+			fail("controller %d: unknown faction %q, expected player, enemy or neutral", i, c.Faction)
+			continue
+		}
+		if faction == sim.PlayerFaction {
+			fail("controller %d: the player's faction is played by the player", i)
+			continue
+		}
+		if controlled[faction] {
+			fail("controller %d: faction %q already has a controller", i, c.Faction)
+		}
+		controlled[faction] = true
+	}
+
+	for _, objectives := range []struct {
+		field string
+		list  []Objective
+	}{{"win", l.Win}, {"lose", l.Lose}} {
+		for i, objective := range objectives.list {
+			if err := objective.validate(names, completionAreas); err != nil {
+				fail("%v %d (%v): %w", objectives.field, i, objective.Type, err)
+			}
+		}
+	}
+
+	if tm != nil {
+		for _, trigger := range tm.MapTriggers {
+			if err := validateTrigger(tm, trigger); err != nil {
+				fail("map %q trigger %d: %w", l.TileMapPath, trigger.ID, err)
+			}
+		}
+	}
+	for _, tutorial := range []struct {
+		field string
+		steps []TutorialStep
+	}{{"intro_tutorial", l.IntroTutorial}, {"completion_tutorial", l.CompletionTutorial}} {
+		for i, step := range tutorial.steps {
+			if err := step.validate(); err != nil {
+				fail("%v step %d: %w", tutorial.field, i, err)
+			}
+		}
+	}
+	if check != nil {
+		errs = append(errs, check(l, tm)...)
+	}
+	return errs
+}
+
+func (o *Objective) validate(names map[string]bool, completionAreas int) error {
+	switch o.Type {
+	case "units_in_area":
+		if len(o.Units) == 0 {
+			return errors.New("no units")
+		}
+		for _, name := range o.Units {
+			if !names[name] {
+				return fmt.Errorf("no spawn named %q", name)
+			}
+		}
+		if completionAreas >= 0 && (o.Area < 0 || o.Area >= completionAreas) {
+			return fmt.Errorf("area %d not in map, it has %d", o.Area, completionAreas)
+		}
+	case "unit_killed":
+		if !names[o.Unit] {
+			return fmt.Errorf("no spawn named %q", o.Unit)
+		}
+	case "hives_destroyed":
+		if _, ok := Factions[o.Faction]; !ok {
+			return fmt.Errorf("unknown faction %q", o.Faction)
+		}
+	case "timer":
+		if o.Seconds <= 0 {
+			return fmt.Errorf("seconds %v, expected more than 0", o.Seconds)
+		}
+	case "resource":
+		if _, ok := Factions[o.Faction]; !ok {
+			return fmt.Errorf("unknown faction %q", o.Faction)
+		}
+		if o.Resource != "sucrose" && o.Resource != "wood" {
+			return fmt.Errorf("resource %q, expected sucrose or wood", o.Resource)
+		}
+	default:
+		return errors.New("unknown type, expected units_in_area, unit_killed, hives_destroyed, timer or resource")
+	}
+	return nil
+}
+
+// validateTrigger checks that a map trigger's script makes sense. Cutscenes
+// only have to exist here, the game parses them.
+func validateTrigger(tm *tilemap.Tilemap, trigger *tilemap.MapTrigger) error {
+	switch trigger.OnEnter {
+	case "cutscene":
+		if trigger.Script == "" {
+			return errors.New("no cutscene")
+		}
+		if _, err := fs.Stat(data.Files, trigger.Script); err != nil {
+			return fmt.Errorf("cutscene %q not in data files", trigger.Script)
+		}
+	case "spawn":
+		if _, err := sim.ParseTriggerSpawn(trigger.Script); err != nil {
+			return err
+		}
+	case "tutorial":
+		if _, err := fs.Stat(assets.Files, trigger.Script); err != nil {
+			return fmt.Errorf("image %q not in assets", trigger.Script)
+		}
+	case "reveal":
+		if !tm.HasLayer(trigger.Script) {
+			return fmt.Errorf("no tile layer %q to reveal", trigger.Script)
+		}
+	default:
+		return fmt.Errorf("unknown on_enter %q, expected one of %v", trigger.OnEnter, tilemap.TriggerActions)
+	}
+	if !slices.Contains(tilemap.TriggerFactions, trigger.Faction) {
+		return fmt.Errorf("unknown faction %q, expected one of %v", trigger.Faction, tilemap.TriggerFactions)
+	}
+	return nil
+}
+
+func (step *TutorialStep) validate() error {
+	if _, err := fs.Stat(assets.Files, step.Image); err != nil {
+		return fmt.Errorf("image %q not in assets", step.Image)
+	}
+	if len(step.Rect) != 4 {
+		return fmt.Errorf("rect has %d numbers, expected x0, y0, x1, y1", len(step.Rect))
+	}
+	if rect := image.Rect(step.Rect[0], step.Rect[1], step.Rect[2], step.Rect[3]); rect.Empty() {
+		return fmt.Errorf("rect %v is empty", step.Rect)
+	}
+	return nil
+}
+
+func lineOf(raw []byte, offset int64) int {
+	return bytes.Count(raw[:min(offset, int64(len(raw)))], []byte("\n")) + 1
+}
+
+func checkPair(xy []int) error {
+	if len(xy) != 2 {
+		return fmt.Errorf("has %d numbers, expected x and y", len(xy))
+	}
+	return nil
+}
+
+// Place puts the level's units and buildings in s, and returns the named
+// units by name. Controllers are added separately, see Controller.NewController.
+func (l *Level) Place(s *sim.T) map[string]*sim.Unit {
+	named := make(map[string]*sim.Unit)
+	for _, sp := range l.Spawns {
+		faction := uint(Factions[sp.Faction])
+		if unitType, ok := sim.UnitKinds[sp.Kind]; ok {
+			u := sim.NewUnitOfType(unitType)
+			u.SetTilePosition(sp.Tile[0], sp.Tile[1])
+			u.Faction = faction
+			s.AddUnit(u)
+			if sp.Name != "" {
+				named[sp.Name] = u
+			}
+		} else {
+			b := BuildingKinds[sp.Kind]()
+			b.SetTilePosition(sp.Tile[0], sp.Tile[1])
+			b.SetFaction(faction)
+			s.AddBuilding(b)
+		}
+	}
+	return named
+}
+
+// NewController makes the AI c asks for.
+func (c *Controller) NewController() sim.Controller {
+	return ControllerTypes[c.Type](uint(Factions[c.Faction]))
+}
@@ -1,13 +1,18 @@
 package log
 
 import (
+	"io"
 	"log/slog"
 	"os"
 	"time"
 )
 
+// Output is where new loggers write, tools that print their results to
+// stdout point it at stderr.
+var Output io.Writer = os.Stdout
+
 func NewLogger() *slog.Logger {
-	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	handler := slog.NewJSONHandler(Output, &slog.HandlerOptions{
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			if a.Key == slog.TimeKey {
 				if t, ok := a.Value.Any().(time.Time); ok {
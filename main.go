@@ -4,6 +4,7 @@ import (
 	"gamejam/audio"
 	"gamejam/config"
 	"gamejam/game"
+	"gamejam/scene"
 	"log"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -35,6 +36,10 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	// check the levels up front so a bad edit fails here, not mid game
+	if _, err := scene.LoadLevelCollection(); err != nil {
+		log.Fatal(err)
+	}
 	game := game.New(cfg, Sound)
 
 	ebiten.SetWindowTitle(cfg.WindowTitle)
@@ -0,0 +1,133 @@
+package scene
+
+import (
+	"fmt"
+	"gamejam/ui"
+	"image"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// ControlGroupKeys bind the selection with Ctrl and select it again without,
+// ordered like the number row.
+var ControlGroupKeys = []ebiten.Key{
+	ebiten.KeyDigit1, ebiten.KeyDigit2, ebiten.KeyDigit3, ebiten.KeyDigit4, ebiten.KeyDigit5,
+	ebiten.KeyDigit6, ebiten.KeyDigit7, ebiten.KeyDigit8, ebiten.KeyDigit9, ebiten.KeyDigit0,
+}
+
+// CameraBookmarkKeys store the camera with Ctrl and jump back to it without.
+var CameraBookmarkKeys = []ebiten.Key{
+	ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4,
+	ebiten.KeyF5, ebiten.KeyF6, ebiten.KeyF7, ebiten.KeyF8,
+}
+
+// DoubleTapFrames is how quickly a group key has to be pressed again to
+// center the camera on the group.
+var DoubleTapFrames = uint64(20)
+
+type controlGroupTap struct {
+	key   ebiten.Key
+	frame uint64
+}
+
+func ctrlPressed() bool {
+	return ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+}
+
+// updateHotkeys handles control groups and camera bookmarks. It runs before
+// the selection is read back from the sprites so a recalled group takes
+// effect the same frame.
+func (s *PlayScene) updateHotkeys() {
+	if s.controlGroups == nil {
+		s.controlGroups = map[ebiten.Key][]string{}
+		s.cameraBookmarks = map[ebiten.Key]CameraState{}
+	}
+
+	for i, key := range ControlGroupKeys {
+		if !inpututil.IsKeyJustPressed(key) {
+			continue
+		}
+		if ctrlPressed() {
+			s.controlGroups[key] = slices.Clone(s.selectedUnitIDs)
+			s.CurrentNotification = ui.NewNotification(&s.fonts.Med, fmt.Sprintf("Group %d set", groupNumber(i)))
+			continue
+		}
+		s.selectControlGroup(key)
+		now := s.sim.GetTick()
+		if s.lastGroupTap.key == key && now-s.lastGroupTap.frame <= DoubleTapFrames {
+			s.centerOnControlGroup(key)
+		}
+		s.lastGroupTap = controlGroupTap{key: key, frame: now}
+	}
+
+	for i, key := range CameraBookmarkKeys {
+		if !inpututil.IsKeyJustPressed(key) {
+			continue
+		}
+		cam := s.Ui.Camera
+		if ctrlPressed() {
+			s.cameraBookmarks[key] = CameraState{ViewPortX: cam.ViewPortX, ViewPortY: cam.ViewPortY, ViewPortZoom: cam.ViewPortZoom}
+			s.CurrentNotification = ui.NewNotification(&s.fonts.Med, fmt.Sprintf("Camera F%d saved", i+1))
+		} else if bookmark, ok := s.cameraBookmarks[key]; ok {
+			cam.SetZoom(bookmark.ViewPortZoom)
+			cam.ViewPortX, cam.ViewPortY = bookmark.ViewPortX, bookmark.ViewPortY
+			cam.PanX(0) // clamp
+			cam.PanY(0)
+		}
+	}
+}
+
+// groupNumber is the digit on the key for group i.
+func groupNumber(i int) int {
+	return (i + 1) % 10
+}
+
+// selectControlGroup selects exactly the group's units and buildings.
+func (s *PlayScene) selectControlGroup(key ebiten.Key) {
+	s.pruneControlGroup(key)
+	if len(s.controlGroups[key]) == 0 {
+		return
+	}
+	for id, spr := range s.Sprites {
+		if spr.Type == ui.SpriteTypeStatic {
+			continue
+		}
+		spr.Selected = slices.Contains(s.controlGroups[key], id)
+	}
+}
+
+func (s *PlayScene) centerOnControlGroup(key ebiten.Key) {
+	var sum image.Point
+	count := 0
+	for _, id := range s.controlGroups[key] {
+		if unit, err := s.sim.GetUnitByID(id); err == nil {
+			sum = sum.Add(*unit.GetCenteredPosition())
+			count++
+		} else if building, err := s.sim.GetBuildingByID(id); err == nil {
+			rect := building.GetRect()
+			sum = sum.Add(rect.Min.Add(rect.Max).Div(2))
+			count++
+		}
+	}
+	if count > 0 {
+		center := sum.Div(count)
+		s.Ui.Camera.CenterOn(center.X, center.Y)
+	}
+}
+
+// pruneControlGroup drops anything that's no longer in the sim, e.g. units
+// removed by loading a save. Deaths are pruned as they happen.
+func (s *PlayScene) pruneControlGroup(key ebiten.Key) {
+	s.controlGroups[key] = slices.DeleteFunc(s.controlGroups[key], func(id string) bool {
+		return s.sim.DetermineUnitOrHiveById(id) == "neither"
+	})
+}
+
+// removeFromControlGroups is called when a unit dies.
+func (s *PlayScene) removeFromControlGroups(id string) {
+	for key, group := range s.controlGroups {
+		s.controlGroups[key] = slices.DeleteFunc(group, func(grouped string) bool { return grouped == id })
+	}
+}
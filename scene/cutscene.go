@@ -146,7 +146,7 @@ type IssueUnitCommandAction struct {
 func (a *IssueUnitCommandAction) Update(s *PlayScene, dt float64) bool {
 	a.targetTile.X = a.targetTile.X * 128
 	a.targetTile.Y = a.targetTile.Y * 128
-	s.sim.IssueAction(a.unitID, a.targetTile)
+	s.sim.IssueAction(a.unitID, sim.MoveOrder, a.targetTile)
 	return true
 }
 
@@ -223,3 +223,64 @@ func (a *DrawTemporarySpriteBetweenUnitsAction) Update(s *PlayScene, dt float64)
 
 	return false
 }
+
+// WaitForArrivalAction waits until the unit has stopped moving, or died, or
+// Timeout seconds have passed.
+type WaitForArrivalAction struct {
+	unitID  string
+	Timeout float64
+	Elapsed float64
+}
+
+func (a *WaitForArrivalAction) Update(s *PlayScene, dt float64) bool {
+	a.Elapsed += dt
+	unit, err := s.sim.GetUnitByID(a.unitID)
+	if err != nil || unit.IsDead() {
+		return true
+	}
+	return unit.Action == sim.IdleAction || (a.Timeout > 0 && a.Elapsed >= a.Timeout)
+}
+
+// ParallelAction runs every track at the same time, e.g. a camera pan while
+// units walk. It's finished once all tracks are.
+type ParallelAction struct {
+	Tracks [][]CutsceneAction
+}
+
+func (a *ParallelAction) Update(s *PlayScene, dt float64) bool {
+	done := true
+	for i := range a.Tracks {
+		if !runCutsceneActions(&a.Tracks[i], s, dt) {
+			done = false
+		}
+	}
+	return done
+}
+
+// BranchAction checks Condition the first time it runs and then plays Then
+// or Else.
+type BranchAction struct {
+	Condition func(*PlayScene) bool
+	Then      []CutsceneAction
+	Else      []CutsceneAction
+	chosen    *[]CutsceneAction
+}
+
+func (a *BranchAction) Update(s *PlayScene, dt float64) bool {
+	if a.chosen == nil {
+		a.chosen = &a.Else
+		if a.Condition(s) {
+			a.chosen = &a.Then
+		}
+	}
+	return runCutsceneActions(a.chosen, s, dt)
+}
+
+// runCutsceneActions updates the first action, dropping it once it finishes,
+// and reports whether none are left.
+func runCutsceneActions(actions *[]CutsceneAction, s *PlayScene, dt float64) bool {
+	if len(*actions) > 0 && (*actions)[0].Update(s, dt) {
+		*actions = (*actions)[1:]
+	}
+	return len(*actions) == 0
+}
@@ -0,0 +1,428 @@
+package scene
+
+import (
+	"errors"
+	"fmt"
+	"gamejam/level"
+	"gamejam/ui"
+	"image"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Cutscene scripts are plain text, one command per line. Units are referred
+// to by their spawn names from the level. Indentation is only for reading.
+//
+//	# the camera pans while antony walks, then they talk
+//	parallel
+//	    pan 27 10 300
+//	and
+//	    move antony 14 11
+//	    arrive antony
+//	end
+//	if sucrose_over 30
+//	    say royal_ant "Antony: We are rich!"
+//	else
+//	    say royal_ant "Antony: To work!"
+//	end
+//
+// Commands:
+//
+//	fade in|out <speed>
+//	say <portrait> "<text>"
+//	move <unit> <tile x> <tile y>
+//	arrive <unit> [timeout seconds]   waits until the unit stops moving
+//	pan <tile x> <tile y> <speed>
+//	zoom <zoom> <speed> <tile x> <tile y>
+//	wait <seconds>
+//	heart <map x> <map y> <frames>
+//
+// Blocks are parallel/and/end, where every track runs at once and the block
+// ends when all of them have, and if/else/end. An if checks one of
+// levelConditions when it's reached, "if not" flips it.
+
+// ArrivalTimeout is how long arrive waits when the script doesn't say, so a
+// stuck unit can't hold up a cutscene forever.
+var ArrivalTimeout = 10.0
+
+// cutsceneCommands are the arguments each command takes, see fill. A
+// trailing ? marks an optional one.
+var cutsceneCommands = map[string][]string{
+	"fade":   {"mode", "speed"},
+	"say":    {"portrait", "text"},
+	"move":   {"unit", "tileX", "tileY"},
+	"arrive": {"unit", "seconds?"},
+	"pan":    {"tileX", "tileY", "speed"},
+	"zoom":   {"zoom", "speed", "tileX", "tileY"},
+	"wait":   {"seconds"},
+	"heart":  {"posX", "posY", "frames"},
+}
+
+// CutsceneScript is a parsed script, ready to be compiled for a PlayScene.
+type CutsceneScript struct {
+	nodes []cutsceneNode
+}
+
+// cutsceneNode is either a single step, a parallel block or a branch.
+type cutsceneNode struct {
+	line   int
+	step   *cutsceneStep
+	tracks [][]cutsceneNode
+
+	condition       *level.Condition
+	negate          bool
+	then, otherwise []cutsceneNode
+}
+
+// cutsceneStep is one CutsceneAction. Which fields matter depends on Action.
+type cutsceneStep struct {
+	Action   string
+	Unit     string
+	Portrait string
+	Text     string
+	Mode     string
+	Tile     []int
+	Pos      []int // map pixels
+	Speed    float64
+	Zoom     float64
+	Seconds  float64
+	Frames   int
+}
+
+type cutsceneLine struct {
+	number int
+	tokens []string
+}
+
+type cutsceneParser struct {
+	file  string
+	lines []cutsceneLine
+	pos   int
+	names map[string]bool
+	errs  []cutsceneError
+}
+
+type cutsceneError struct {
+	line int
+	err  error
+}
+
+// ParseCutscene parses a script, checking unit names against names. Every
+// problem is reported as file:line, joined into one error.
+func ParseCutscene(file string, src []byte, names map[string]bool) (*CutsceneScript, error) {
+	p := &cutsceneParser{file: file, names: names}
+	for i, text := range strings.Split(string(src), "\n") {
+		tokens, err := tokenizeCutsceneLine(text)
+		if err != nil {
+			p.fail(i+1, "%v", err)
+			continue
+		}
+		if len(tokens) > 0 {
+			p.lines = append(p.lines, cutsceneLine{number: i + 1, tokens: tokens})
+		}
+	}
+
+	nodes, _ := p.parseBlock(0)
+	if len(p.errs) > 0 {
+		slices.SortStableFunc(p.errs, func(a, b cutsceneError) int { return a.line - b.line })
+		var errs []error
+		for _, e := range p.errs {
+			errs = append(errs, e.err)
+		}
+		return nil, errors.Join(errs...)
+	}
+	return &CutsceneScript{nodes: nodes}, nil
+}
+
+func (p *cutsceneParser) fail(line int, format string, args ...any) {
+	err := fmt.Errorf("%v:%d: %v", p.file, line, fmt.Sprintf(format, args...))
+	p.errs = append(p.errs, cutsceneError{line: line, err: err})
+}
+
+// parseBlock reads nodes until one of the terminators, which it consumes and
+// returns. opened is the line of the block's first keyword, 0 at the top.
+func (p *cutsceneParser) parseBlock(opened int, terminators ...string) ([]cutsceneNode, string) {
+	var nodes []cutsceneNode
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		p.pos++
+		keyword, args := line.tokens[0], line.tokens[1:]
+
+		switch keyword {
+		case "end", "and", "else":
+			for _, t := range terminators {
+				if keyword == t {
+					if len(args) > 0 {
+						p.fail(line.number, "%v takes no arguments", keyword)
+					}
+					return nodes, keyword
+				}
+			}
+			p.fail(line.number, "%v without a matching block", keyword)
+		case "parallel":
+			node := cutsceneNode{line: line.number}
+			for {
+				track, term := p.parseBlock(line.number, "and", "end")
+				node.tracks = append(node.tracks, track)
+				if term != "and" {
+					break
+				}
+			}
+			nodes = append(nodes, node)
+		case "if":
+			node := cutsceneNode{line: line.number}
+			if len(args) > 0 && args[0] == "not" {
+				node.negate = true
+				args = args[1:]
+			}
+			node.condition = p.parseCondition(line.number, args)
+			var term string
+			node.then, term = p.parseBlock(line.number, "else", "end")
+			if term == "else" {
+				node.otherwise, _ = p.parseBlock(line.number, "end")
+			}
+			nodes = append(nodes, node)
+		default:
+			if step := p.parseStep(line.number, keyword, args); step != nil {
+				nodes = append(nodes, cutsceneNode{line: line.number, step: step})
+			}
+		}
+	}
+	if opened > 0 {
+		p.fail(opened, "block is missing its end")
+	}
+	return nodes, ""
+}
+
+func (p *cutsceneParser) parseCondition(line int, args []string) *level.Condition {
+	if len(args) == 0 || len(args) > 2 {
+		p.fail(line, "expected if [not] <condition> [amount]")
+		return nil
+	}
+	cond := &level.Condition{Condition: args[0]}
+	if _, ok := levelConditions[cond.Condition]; !ok {
+		p.fail(line, "unknown condition %q", cond.Condition)
+	}
+	if len(args) == 2 {
+		amount, err := strconv.Atoi(args[1])
+		if err != nil {
+			p.fail(line, "amount %q is not a whole number", args[1])
+		}
+		cond.Amount = amount
+	}
+	return cond
+}
+
+func (p *cutsceneParser) parseStep(line int, command string, args []string) *cutsceneStep {
+	params, ok := cutsceneCommands[command]
+	if !ok {
+		p.fail(line, "unknown command %q", command)
+		return nil
+	}
+	required := 0
+	for _, param := range params {
+		if !strings.HasSuffix(param, "?") {
+			required++
+		}
+	}
+	if len(args) < required || len(args) > len(params) {
+		p.fail(line, "%v takes %v", command, strings.Join(params, " "))
+		return nil
+	}
+
+	step := &cutsceneStep{Action: command}
+	if command == "arrive" {
+		step.Seconds = ArrivalTimeout
+	}
+	for i, arg := range args {
+		if err := step.fill(strings.TrimSuffix(params[i], "?"), arg); err != nil {
+			p.fail(line, "%v: %v", params[i], err)
+			return nil
+		}
+	}
+	if err := step.validate(p.names); err != nil {
+		p.fail(line, "%v: %v", command, err)
+		return nil
+	}
+	return step
+}
+
+// fill sets the field named by param from arg.
+func (step *cutsceneStep) fill(param, arg string) error {
+	switch param {
+	case "mode":
+		step.Mode = arg
+	case "portrait":
+		step.Portrait = arg
+	case "text":
+		step.Text = arg
+	case "unit":
+		step.Unit = arg
+	case "tileX", "tileY", "posX", "posY", "frames":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", arg)
+		}
+		switch param {
+		case "tileX", "tileY":
+			step.Tile = append(step.Tile, n)
+		case "posX", "posY":
+			step.Pos = append(step.Pos, n)
+		case "frames":
+			step.Frames = n
+		}
+	case "speed", "zoom", "seconds":
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", arg)
+		}
+		switch param {
+		case "speed":
+			step.Speed = f
+		case "zoom":
+			step.Zoom = f
+		case "seconds":
+			step.Seconds = f
+		}
+	}
+	return nil
+}
+
+func (step *cutsceneStep) validate(names map[string]bool) error {
+	switch step.Action {
+	case "fade":
+		if step.Mode != "in" && step.Mode != "out" {
+			return fmt.Errorf("mode %q, expected in or out", step.Mode)
+		}
+		if step.Speed < 1 || step.Speed > 255 {
+			return fmt.Errorf("speed %v, expected 1 to 255", step.Speed)
+		}
+	case "say":
+		if _, ok := levelPortraits[step.Portrait]; !ok {
+			return fmt.Errorf("unknown portrait %q", step.Portrait)
+		}
+		if step.Text == "" {
+			return errors.New("text is missing")
+		}
+	case "move", "arrive":
+		if !names[step.Unit] {
+			return fmt.Errorf("no spawn named %q", step.Unit)
+		}
+		if step.Seconds < 0 {
+			return fmt.Errorf("timeout %v is negative", step.Seconds)
+		}
+	case "pan", "zoom":
+		if step.Speed <= 0 {
+			return fmt.Errorf("speed %v, expected more than 0", step.Speed)
+		}
+		if step.Action == "zoom" && step.Zoom <= 0 {
+			return fmt.Errorf("zoom %v, expected more than 0", step.Zoom)
+		}
+	case "wait":
+		if step.Seconds <= 0 {
+			return fmt.Errorf("seconds %v, expected more than 0", step.Seconds)
+		}
+	case "heart":
+		if step.Frames <= 0 {
+			return fmt.Errorf("frames %v, expected more than 0", step.Frames)
+		}
+	}
+	return nil
+}
+
+// tokenizeCutsceneLine splits on spaces, keeping "quoted text" together and
+// dropping # comments.
+func tokenizeCutsceneLine(text string) ([]string, error) {
+	var tokens []string
+	for {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		if text == "" || text[0] == '#' {
+			return tokens, nil
+		}
+		if text[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(text)
+			if err != nil {
+				return nil, errors.New("unterminated quote")
+			}
+			unquoted, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, unquoted)
+			text = text[len(quoted):]
+			continue
+		}
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			end = len(text)
+		}
+		tokens = append(tokens, text[:end])
+		text = text[end:]
+	}
+}
+
+// Compile turns the script into fresh actions for s, with unit names
+// resolved through s.NamedUnits. A nil script compiles to nothing.
+func (cs *CutsceneScript) Compile(s *PlayScene) []CutsceneAction {
+	if cs == nil {
+		return nil
+	}
+	return compileCutsceneNodes(s, cs.nodes)
+}
+
+func compileCutsceneNodes(s *PlayScene, nodes []cutsceneNode) []CutsceneAction {
+	var actions []CutsceneAction
+	for _, node := range nodes {
+		switch {
+		case node.step != nil:
+			actions = append(actions, node.step.action(s))
+		case node.tracks != nil:
+			parallel := &ParallelAction{}
+			for _, track := range node.tracks {
+				parallel.Tracks = append(parallel.Tracks, compileCutsceneNodes(s, track))
+			}
+			actions = append(actions, parallel)
+		case node.condition != nil:
+			check, negate := conditionCheck(node.condition), node.negate
+			actions = append(actions, &BranchAction{
+				Condition: func(ps *PlayScene) bool { return check(ps) != negate },
+				Then:      compileCutsceneNodes(s, node.then),
+				Else:      compileCutsceneNodes(s, node.otherwise),
+			})
+		}
+	}
+	return actions
+}
+
+func (step *cutsceneStep) action(s *PlayScene) CutsceneAction {
+	switch step.Action {
+	case "fade":
+		return &FadeCameraAction{Mode: step.Mode, Speed: uint8(step.Speed)}
+	case "say":
+		return &ShowPortraitTextAreaAction{
+			portraitTextArea: ui.NewPortraitTextArea(s.fonts, step.Text, levelPortraits[step.Portrait]),
+		}
+	case "move":
+		return &IssueUnitCommandAction{
+			unitID:     s.NamedUnits[step.Unit],
+			targetTile: &image.Point{X: step.Tile[0], Y: step.Tile[1]},
+		}
+	case "arrive":
+		return &WaitForArrivalAction{unitID: s.NamedUnits[step.Unit], Timeout: step.Seconds}
+	case "pan":
+		return &PanCameraAction{TargetX: float64(step.Tile[0]), TargetY: float64(step.Tile[1]), Speed: step.Speed}
+	case "zoom":
+		return &ZoomCameraAction{
+			TargetZoom: step.Zoom,
+			Speed:      step.Speed,
+			FocusX:     float64(step.Tile[0] * s.tileMap.TileSize),
+			FocusY:     float64(step.Tile[1] * s.tileMap.TileSize),
+		}
+	case "wait":
+		return &WaitAction{Duration: step.Seconds}
+	case "heart":
+		return NewDrawTemporarySpriteAction(ui.NewHeartSprite(uuid.New()), &image.Point{X: step.Pos[0], Y: step.Pos[1]}, step.Frames)
+	}
+	panic(fmt.Sprintf("unknown cutscene action %q", step.Action)) // the parser rejects these
+}
@@ -0,0 +1,220 @@
+package scene
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var testSpawnNames = map[string]bool{"antony": true, "cleopatroach": true}
+
+// outline writes nodes as a one line summary, steps by their action,
+// parallel blocks as par(track|track) and branches as
+// if cond amount{then}else{otherwise}.
+func outline(nodes []cutsceneNode) string {
+	var parts []string
+	for _, n := range nodes {
+		switch {
+		case n.step != nil:
+			parts = append(parts, n.step.Action)
+		case n.tracks != nil:
+			var tracks []string
+			for _, track := range n.tracks {
+				tracks = append(tracks, outline(track))
+			}
+			parts = append(parts, "par("+strings.Join(tracks, "|")+")")
+		default:
+			cond := "if "
+			if n.negate {
+				cond += "not "
+			}
+			if n.condition != nil {
+				cond += fmt.Sprintf("%v %v", n.condition.Condition, n.condition.Amount)
+			}
+			cond += "{" + outline(n.then) + "}"
+			if n.otherwise != nil {
+				cond += "else{" + outline(n.otherwise) + "}"
+			}
+			parts = append(parts, cond)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestParseCutscene(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		want string   // outline of the parsed script
+		errs []string // every one has to be in the error, in order
+	}{
+		{
+			name: "steps and comments",
+			src: `# intro
+fade in 4
+say royal_ant "Antony: # not a comment"   # but this is
+wait 1.5
+heart 100 200 60`,
+			want: "fade,say,wait,heart",
+		},
+		{
+			name: "parallel tracks",
+			src: `parallel
+    pan 27 10 300
+and
+    move antony 14 11
+    arrive antony
+and
+    zoom 2 0.5 3 4
+end
+wait 1`,
+			want: "par(pan|move,arrive|zoom),wait",
+		},
+		{
+			name: "nested blocks",
+			src: `if not camera_moved
+    parallel
+        pan 1 2 3
+    and
+        if sucrose_over 30
+            wait 1
+        end
+    end
+end`,
+			want: "if not camera_moved 0{par(pan|if sucrose_over 30{wait})}",
+		},
+		{
+			name: "if else",
+			src: `if sucrose_over 30
+    say royal_ant "Antony: We are rich!"
+else
+    say royal_ant "Antony: To work!"
+    wait 2
+end`,
+			want: "if sucrose_over 30{say}else{say,wait}",
+		},
+		{
+			name: "empty branch",
+			src: `if wood_over 5
+else
+    wait 1
+end`,
+			want: "if wood_over 5{}else{wait}",
+		},
+		{
+			name: "unknown command and condition",
+			src: `wait 1
+dance antony
+if rich
+end`,
+			errs: []string{`s.txt:2: unknown command "dance"`, `s.txt:3: unknown condition "rich"`},
+		},
+		{
+			name: "bad arguments",
+			src: `fade sideways 4
+pan 1 2
+move antony x 3
+say nobody "hi"
+wait 0
+if sucrose_over lots
+end`,
+			errs: []string{
+				`s.txt:1: fade: mode "sideways"`,
+				"s.txt:2: pan takes tileX tileY speed",
+				`s.txt:3: tileX: "x" is not a whole number`,
+				`s.txt:4: say: unknown portrait "nobody"`,
+				"s.txt:5: wait: seconds 0",
+				`s.txt:6: amount "lots" is not a whole number`,
+			},
+		},
+		{
+			name: "unknown unit",
+			src: `move antony 1 1
+arrive cleopatra`,
+			errs: []string{`s.txt:2: arrive: no spawn named "cleopatra"`},
+		},
+		{
+			name: "unterminated quote",
+			src: `wait 1
+say royal_ant "Antony: oh no`,
+			errs: []string{"s.txt:2: unterminated quote"},
+		},
+		{
+			name: "blocks not closed",
+			src: `parallel
+    wait 1
+and
+    if camera_moved
+        wait 1`,
+			errs: []string{"s.txt:1: block is missing its end", "s.txt:4: block is missing its end"},
+		},
+		{
+			name: "stray keywords",
+			src: `wait 1
+and
+else
+end
+parallel
+    wait 1
+end now`,
+			errs: []string{
+				"s.txt:2: and without a matching block",
+				"s.txt:3: else without a matching block",
+				"s.txt:4: end without a matching block",
+				"s.txt:7: end takes no arguments",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			script, err := ParseCutscene("s.txt", []byte(tc.src), testSpawnNames)
+			if len(tc.errs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got := outline(script.nodes); got != tc.want {
+					t.Errorf("got %v, want %v", got, tc.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %q", tc.errs)
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tc.errs) {
+				t.Fatalf("got %d errors, want %d:\n%v", len(lines), len(tc.errs), err)
+			}
+			for i, want := range tc.errs {
+				if !strings.HasPrefix(lines[i], want) {
+					t.Errorf("error %d is %q, want it to start with %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCutsceneArrival(t *testing.T) {
+	_, err := ParseCutscene("s.txt", []byte("arrive antony\narrive cleopatroach 2.5\narrive antony -1"), testSpawnNames)
+	if err == nil || !strings.HasPrefix(err.Error(), "s.txt:3: arrive: timeout -1 is negative") {
+		t.Fatalf("got %v, want the negative timeout on line 3", err)
+	}
+
+	script, err := ParseCutscene("s.txt", []byte("arrive antony\narrive cleopatroach 2.5"), testSpawnNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		unit    string
+		seconds float64
+	}{
+		{"antony", ArrivalTimeout},
+		{"cleopatroach", 2.5},
+	} {
+		step := script.nodes[i].step
+		if step.Unit != want.unit || step.Seconds != want.seconds {
+			t.Errorf("step %d waits %vs for %v, want %vs for %v", i, step.Seconds, step.Unit, want.seconds, want.unit)
+		}
+		if line := script.nodes[i].line; line != i+1 {
+			t.Errorf("step %d is on line %d, want %d", i, line, i+1)
+		}
+	}
+}
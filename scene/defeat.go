@@ -0,0 +1,69 @@
+package scene
+
+import (
+	"gamejam/audio"
+	"gamejam/fonts"
+	"gamejam/ui"
+	"gamejam/util"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// DefeatScene is shown when a lose objective is met. Retry rebuilds the
+// level from scratch.
+type DefeatScene struct {
+	BaseScene
+	LevelData LevelData
+	reason    string
+	sound     *audio.SoundManager
+	bg        *ebiten.Image
+	fonts     *fonts.All
+	started   bool
+	retryBtn  *ui.Button
+	menuBtn   *ui.Button
+}
+
+func NewDefeatScene(fonts *fonts.All, sound *audio.SoundManager, levelData LevelData, reason string) *DefeatScene {
+	scene := &DefeatScene{
+		LevelData: levelData,
+		reason:    reason,
+		sound:     sound,
+		bg:        util.LoadImage("ui/narrator-bg.png"),
+		fonts:     fonts,
+	}
+	scene.retryBtn = ui.NewButton(fonts.Med, ui.WithText("RETRY"), ui.WithRect(image.Rectangle{
+		Min: image.Point{X: 200, Y: 420},
+		Max: image.Point{X: 390, Y: 470},
+	}), ui.WithClickFunc(func() {
+		scene.sound.Stop("msx_narratorsong")
+		scene.sm.SwitchTo(NewPlayScene(scene.fonts, scene.sound, scene.LevelData))
+	}))
+	scene.menuBtn = ui.NewButton(fonts.Med, ui.WithText("MENU"), ui.WithRect(image.Rectangle{
+		Min: image.Point{X: 410, Y: 420},
+		Max: image.Point{X: 600, Y: 470},
+	}), ui.WithClickFunc(func() {
+		scene.sound.Stop("msx_narratorsong")
+		scene.sm.SwitchTo(NewMenuScene(scene.fonts, scene.sound))
+	}))
+	return scene
+}
+
+func (s *DefeatScene) Update() error {
+	if !s.started {
+		s.started = true
+		s.sound.Play("msx_narratorsong")
+	}
+	s.retryBtn.Update()
+	s.menuBtn.Update()
+	return nil
+}
+
+func (s *DefeatScene) Draw(screen *ebiten.Image) {
+	screen.DrawImage(s.bg, nil)
+	util.DrawCenteredText(screen, s.fonts.XLarge, "DEFEAT", 400, 150, nil)
+	util.DrawCenteredText(screen, s.fonts.Med, s.reason, 400, 260, nil)
+
+	s.retryBtn.Draw(screen)
+	s.menuBtn.Draw(screen)
+}
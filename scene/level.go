@@ -1,539 +1,289 @@
 package scene
 
 import (
+	"cmp"
+	"fmt"
+	"gamejam/data"
+	"gamejam/level"
 	"gamejam/sim"
+	"gamejam/tilemap"
 	"gamejam/ui"
 	"image"
-
-	"github.com/google/uuid"
+	"strings"
+	"sync"
 )
 
+// LevelData is a level from the levels file with the cutscenes it plays
+// parsed, see level.Level for the rest.
 type LevelData struct {
-	LevelNumber             int
-	TileMapPath             string
-	LevelIntroText          string
-	SetupFunc               func(*PlayScene) (queenID string, kingID string)
-	SetupInitialCutscene    func(*PlayScene, string, string)
-	SetupCompletionCutscene func(*PlayScene, string, string)
+	level.Level
+
+	introScript      *CutsceneScript
+	completionScript *CutsceneScript
+	triggerScripts   map[string]*CutsceneScript // cutscenes the map's triggers play, by path
 }
 
 type LevelCollection struct {
 	Levels map[int]LevelData
 }
 
-func NewLevelCollection() *LevelCollection {
-	coll := &LevelCollection{
-		Levels: make(map[int]LevelData),
-	}
-	coll.Levels[0] = LevelData{
-		LevelNumber: 0,
-		TileMapPath: "tilemap/map1.tmx",
-		LevelIntroText: `In the land of Nilopolis, where the sand meets sugar and the air hums with winged gossip, two empires crawl toward destiny.
-
-		One: the mighty Ant-tonian Legion, proud builders and brave foragers. 
-
-		The other: Queen Cleopatroach's royal roachdom, ancient, secretive, and ever-scheming.
-
-		Long hath love fluttered betwixt Antony, soldier of soil, and Cleopatroach, goddess of grime. 
-
-		But lo! A chasm divides them, wide as a footprint and deep as a drain. Wood must be gathered. A bridge must be built. And their love… must scuttle onward.
-		
-
-		Arise, player! Command thy swarm!`,
-		SetupFunc: func(scene *PlayScene) (string, string) {
-			u := sim.NewDefaultAnt()
-			u.SetTilePosition(6, 12)
-			scene.sim.AddUnit(u)
+var levelPortraits = map[string]ui.PortraitType{
+	"royal_ant":   ui.PortraitTypeRoyalAnt,
+	"royal_roach": ui.PortraitTypeRoyalRoach,
+}
 
-			u2 := sim.NewDefaultAnt()
-			u2.SetTilePosition(6, 5)
-			scene.sim.AddUnit(u2)
+// levelConditions are what tutorial steps and cutscene branches can check.
+var levelConditions = map[string]func(ps *PlayScene, amount int) bool{
+	"units_selected": func(ps *PlayScene, _ int) bool {
+		return len(ps.selectedUnitIDs) > 0
+	},
+	"one_unit_selected": func(ps *PlayScene, _ int) bool {
+		return len(ps.selectedUnitIDs) == 1 && ps.sim.DetermineUnitOrHiveById(ps.selectedUnitIDs[0]) == "unit"
+	},
+	"hive_selected": func(ps *PlayScene, _ int) bool {
+		for _, id := range ps.selectedUnitIDs {
+			if ps.sim.DetermineUnitOrHiveById(id) == "hive" {
+				return true
+			}
+		}
+		return false
+	},
+	"camera_moved": func(ps *PlayScene, _ int) bool {
+		return ps.Ui.Camera.ViewPortX != 0 && ps.Ui.Camera.ViewPortY != 0 // TODO fragile!!
+	},
+	"sucrose_over": func(ps *PlayScene, amount int) bool {
+		return int(ps.sim.GetSucroseAmount()) > amount
+	},
+	"wood_over": func(ps *PlayScene, amount int) bool {
+		return int(ps.sim.GetWoodAmount()) > amount
+	},
+	"hive_producing": func(ps *PlayScene, _ int) bool {
+		for _, bld := range ps.sim.GetAllBuildings() {
+			if bld.GetProgress() != 0 {
+				return true
+			}
+		}
+		return false
+	},
+	"placing_building": func(ps *PlayScene, _ int) bool {
+		return ps.constructionMouse.Enabled
+	},
+	"building_started": func(ps *PlayScene, _ int) bool {
+		for _, bld := range ps.sim.GetAllBuildings() {
+			if bld.GetType() == sim.BuildingTypeInConstruction {
+				return true
+			}
+		}
+		return false
+	},
+}
 
-			king := sim.NewRoyalAnt()
-			king.SetTilePosition(12, 11)
-			scene.sim.AddUnit(king)
+var loadLevels = sync.OnceValues(readLevels)
 
-			queen := sim.NewRoyalRoach()
-			queen.SetTilePosition(28, 10)
-			queen.Faction = 1
-			scene.sim.AddUnit(queen)
+// NewLevelCollection returns the embedded levels. They're checked when the
+// game starts, so a broken levels file never gets this far.
+func NewLevelCollection() *LevelCollection {
+	coll, err := loadLevels()
+	if err != nil {
+		panic(err)
+	}
+	return coll
+}
 
-			scene.Ui.Camera.SetZoom(ui.MinZoom)
-			scene.Ui.Camera.SetPosition(10, 160)
-			scene.Ui.Camera.FadeAlpha = 255
+// LoadLevelCollection reads and validates the embedded levels file, once.
+func LoadLevelCollection() (*LevelCollection, error) {
+	return loadLevels()
+}
 
-			h := sim.NewHive()
-			h.SetTilePosition(8, 8)
-			scene.sim.AddBuilding(h)
+func readLevels() (*LevelCollection, error) {
+	raw, err := data.Files.ReadFile(level.File)
+	if err != nil {
+		return nil, fmt.Errorf("opening levels file: %w", err)
+	}
+	coll, err := ParseLevels(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", level.File, err)
+	}
+	return coll, nil
+}
 
-			scene.CompletionCondition = NewSceneCompletion(queen, king, scene.tileMap.MapCompletionObjects[0].Rect)
-			return queen.ID.String(), king.ID.String()
-		},
-		SetupInitialCutscene: func(s *PlayScene, cleopatroach string, antony string) {
-			s.inCutscene = true
-			s.Ui.DrawEnabled = false
-			s.drag.Enabled = false
-			s.cutsceneActions = []CutsceneAction{
-				&FadeCameraAction{Mode: "in", Speed: 2},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: O brave new bugworld! Where art thou, my chitinous queen? I must construct yon bridge, ere my love is lost!",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&IssueUnitCommandAction{
-					unitID:     antony,
-					targetTile: &image.Point{X: 14, Y: 11},
-				},
-				&PanCameraAction{TargetX: float64(27), TargetY: float64(10), Speed: 300},
-				&IssueUnitCommandAction{
-					unitID:     cleopatroach,
-					targetTile: &image.Point{X: 26, Y: 10},
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Cleopatroach:  Love that is count'd is love too small. Rescue me, my six-legged soldier!",
-						ui.PortraitTypeRoyalRoach,
-					),
-				},
-				&PanCameraAction{TargetX: float64(3), TargetY: float64(2), Speed: 300},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: By mandible and might, I shall summon my swarm! To toil, my brethren! Reap the crystal'd sweet!",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&IssueUnitCommandAction{
-					unitID:     cleopatroach,
-					targetTile: &image.Point{X: 28, Y: 10},
-				},
-			}
+// ParseLevels is level.Parse, with the cutscenes each level plays parsed
+// and checked along with everything else.
+func ParseLevels(raw []byte) (*LevelCollection, error) {
+	coll := &LevelCollection{Levels: make(map[int]LevelData)}
+	_, err := level.Parse(raw, func(l *level.Level, tm *tilemap.Tilemap) []error {
+		levelData, errs := newLevelData(l, tm)
+		coll.Levels[l.LevelNumber] = levelData
+		return errs
+	})
+	if err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
 
-			s.tutorialDialogs = []Tutorial{
-				NewTutorialStep( // click and drag units
-					"tutorials/tutorial-1.png",
-					&image.Rectangle{Min: image.Point{X: 412, Y: 341}, Max: image.Point{X: 800, Y: 600}},
-					nil, // trigger always
-					func(ps *PlayScene) bool { // only complete once a unit is selected
-						if len(ps.selectedUnitIDs) > 0 {
-							return true
-						}
-						return false
-					},
-				),
-				NewTutorialStep( // move camera
-					"tutorials/tutorial-2.png",
-					&image.Rectangle{Min: image.Point{X: 412, Y: 341}, Max: image.Point{X: 800, Y: 600}},
-					nil,
-					func(ps *PlayScene) bool { // only complete once a unit is selected
-						if ps.Ui.Camera.ViewPortX != 0 && ps.Ui.Camera.ViewPortY != 0 { // TODO fragile!!
-							return true
-						}
-						return false
-					},
-				),
-				NewTutorialStep( // pause
-					"tutorials/tutorial-pause.png",
-					&image.Rectangle{Min: image.Point{X: 412, Y: 341}, Max: image.Point{X: 800, Y: 600}},
-					nil,
-					nil,
-				),
-				NewTutorialStep( // collected some sucrose + select hive
-					"tutorials/tutorial-3.png",
-					&image.Rectangle{Min: image.Point{X: 0, Y: 341}, Max: image.Point{X: 388, Y: 600}},
-					func(ps *PlayScene) bool {
-						if ps.sim.GetSucroseAmount() > 30 {
-							return true
-						}
-						return false
-					},
-					func(ps *PlayScene) bool {
-						for _, id := range ps.selectedUnitIDs {
-							if ps.sim.DetermineUnitOrHiveById(id) == "hive" {
-								return true
-							}
-						}
-						return false
-					},
-				),
-				NewTutorialStep( // hive selected + build unit
-					"tutorials/tutorial-4.png",
-					&image.Rectangle{Min: image.Point{X: 0, Y: 341}, Max: image.Point{X: 388, Y: 600}},
-					nil,
-					func(ps *PlayScene) bool {
-						for _, bld := range ps.sim.GetAllBuildings() {
-							if bld.GetProgress() != 0 {
-								return true
-							}
-						}
-						return false
-					},
-				),
-				NewTutorialStep( // wood collected + select single unit
-					"tutorials/tutorial-5.png",
-					&image.Rectangle{Min: image.Point{X: 0, Y: 0}, Max: image.Point{X: 388, Y: 259}},
-					func(ps *PlayScene) bool {
-						if ps.sim.GetWoodAmount() > 30 {
-							return true
-						}
-						return false
-					},
-					func(ps *PlayScene) bool {
-						if len(ps.selectedUnitIDs) == 1 {
-							if ps.sim.DetermineUnitOrHiveById(ps.selectedUnitIDs[0]) == "unit" {
-								return true
-							}
-						}
-						return false
-					},
-				),
-				NewTutorialStep( // unit selected + start building bridge
-					"tutorials/tutorial-6.png",
-					&image.Rectangle{Min: image.Point{X: 0, Y: 0}, Max: image.Point{X: 388, Y: 259}},
-					nil,
-					func(ps *PlayScene) bool {
-						return ps.constructionMouse.Enabled
-					},
-				),
-				NewTutorialStep( // info about building bridges
-					"tutorials/tutorial-7.png",
-					&image.Rectangle{Min: image.Point{X: 0, Y: 0}, Max: image.Point{X: 388, Y: 259}},
-					nil,
-					nil,
-				),
-				NewTutorialStep( // Build a bridge
-					"tutorials/tutorial-8.png",
-					&image.Rectangle{Min: image.Point{X: 0, Y: 0}, Max: image.Point{X: 388, Y: 259}},
-					nil,
-					func(ps *PlayScene) bool {
-						for _, bld := range ps.sim.GetAllBuildings() {
-							if bld.GetType() == sim.BuildingTypeInConstruction {
-								return true
-							}
-						}
-						return false
-					},
-				),
-				NewTutorialStep( // finish the bridge
-					"tutorials/tutorial-9.png",
-					&image.Rectangle{Min: image.Point{X: 0, Y: 341}, Max: image.Point{X: 388, Y: 600}},
-					nil,
-					nil,
-				),
+// newLevelData parses the level's cutscenes and checks what level.Parse
+// leaves to the game, the tutorial conditions.
+func newLevelData(l *level.Level, tm *tilemap.Tilemap) (LevelData, []error) {
+	var errs []error
+	names := l.Names()
+	levelData := LevelData{Level: *l}
+	levelData.introScript = loadCutscene(l.IntroCutscene, names, &errs)
+	levelData.completionScript = loadCutscene(l.CompletionCutscene, names, &errs)
+	if tm != nil {
+		levelData.triggerScripts = make(map[string]*CutsceneScript)
+		for _, trigger := range tm.MapTriggers {
+			if trigger.OnEnter != "cutscene" || trigger.Script == "" {
+				continue
 			}
-		},
-
-		SetupCompletionCutscene: func(s *PlayScene, cleopatroach string, antony string) {
-			s.inCutscene = true
-			s.inCutscene = true
-			s.Ui.DrawEnabled = false
-			s.drag.Enabled = false
-
-			s.selectedUnitIDs = []string{} // clear selected unit IDs
-
-			s.cutsceneActions = []CutsceneAction{
-				&IssueUnitCommandAction{
-					unitID:     cleopatroach,
-					targetTile: &image.Point{X: 27, Y: 5},
-				},
-				&IssueUnitCommandAction{
-					unitID:     antony,
-					targetTile: &image.Point{X: 27, Y: 13},
-				},
-				&WaitAction{
-					Duration: 1.0, // wait for 1 second
-				},
-				&IssueUnitCommandAction{
-					unitID:     cleopatroach,
-					targetTile: &image.Point{X: 27, Y: 8},
-				},
-
-				&IssueUnitCommandAction{
-					unitID:     antony,
-					targetTile: &image.Point{X: 27, Y: 10},
-				},
-				&WaitAction{
-					Duration: 1.0, // wait for 1 second
-				},
-				&PanCameraAction{TargetX: float64(30), TargetY: float64(10), Speed: 300},
-				&ZoomCameraAction{
-					TargetZoom: 0.8,
-					Speed:      1,
-					FocusX:     29 * 128,
-					FocusY:     9 * 128,
-				},
-				&DrawTemporarySpriteAction{
-					spr:            ui.NewHeartSprite(uuid.New()),
-					TargetPosition: &image.Point{X: 3525, Y: 1200},
-					MaxDuration:    180,
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: Fear not, thorax of my heart! I have crushed the peril beneath my heel",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Cleopatroach: Come hither, sweet thorax. Let us entwine our antennae in triumph.",
-						ui.PortraitTypeRoyalRoach,
-					),
-				},
-				&FadeCameraAction{Mode: "out", Speed: 1},
+			if _, ok := levelData.triggerScripts[trigger.Script]; !ok {
+				levelData.triggerScripts[trigger.Script] = loadCutscene(trigger.Script, names, &errs)
 			}
-			s.tutorialDialogs = []Tutorial{
-				NewTutorialStep( // goal of level
-					"tutorials/lvl2-tutorial-1.png",
-					&image.Rectangle{Min: image.Point{X: 0, Y: 341}, Max: image.Point{X: 388, Y: 600}},
-					nil,
-					nil,
-				),
+		}
+	}
+	for _, tutorial := range []struct {
+		field string
+		steps []level.TutorialStep
+	}{{"intro_tutorial", l.IntroTutorial}, {"completion_tutorial", l.CompletionTutorial}} {
+		for i, step := range tutorial.steps {
+			for _, cond := range []*level.Condition{step.Trigger, step.Complete} {
+				if cond == nil {
+					continue
+				}
+				if _, ok := levelConditions[cond.Condition]; !ok {
+					errs = append(errs, fmt.Errorf("%v step %d: unknown condition %q", tutorial.field, i, cond.Condition))
+				}
 			}
-		},
+		}
 	}
+	return levelData, errs
+}
 
-	coll.Levels[1] = LevelData{
-		LevelNumber: 1,
-		TileMapPath: "tilemap/map2.tmx",
-		LevelIntroText: `The Senate-mound murmurs with unrest -
-	Some say Ant-tony hath bent his thorax too far,
-	Given up tunnels and treaties for the shimmer of a roach's wing.
-
-	But hark! The queen doth summon him from beyond the ravine again.
-	A bridge must rise! Broods must hatch!
-	And amid wood chips and whispers, history must crawl forward.`,
-		SetupFunc: func(s *PlayScene) (string, string) {
-			// hives
-			h := sim.NewHive()
-			h.SetTilePosition(6, 8)
-			s.sim.AddBuilding(h)
-
-			rh := sim.NewRoachHive()
-			rh.SetTilePosition(40, 12)
-			s.sim.AddBuilding(rh)
+// loadCutscene parses the script at path, adding any problems to errs. An
+// empty path is an empty cutscene.
+func loadCutscene(path string, names map[string]bool, errs *[]error) *CutsceneScript {
+	if path == "" {
+		return &CutsceneScript{}
+	}
+	src, err := data.Files.ReadFile(path)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("cutscene %q: %w", path, err))
+		return nil
+	}
+	script, err := ParseCutscene(path, src, names)
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		*errs = append(*errs, joined.Unwrap()...)
+	} else if err != nil {
+		*errs = append(*errs, err)
+	}
+	return script
+}
 
-			// royalty
-			queen := sim.NewRoyalRoach()
-			queen.SetTilePosition(33, 9)
-			s.sim.AddUnit(queen)
+// Setup places the level's spawns and camera, hands factions to their AIs
+// and builds the completion condition. Named units are kept in s.NamedUnits.
+func (l *LevelData) Setup(s *PlayScene) {
+	for name, u := range l.Place(s.sim) {
+		s.NamedUnits[name] = u.ID.String()
+	}
+	for _, c := range l.Controllers {
+		s.sim.AddController(c.NewController())
+	}
 
-			king := sim.NewRoyalAnt()
-			king.SetTilePosition(10, 10)
-			s.sim.AddUnit(king)
+	zoom := l.Camera.Zoom
+	if zoom == 0 {
+		zoom = ui.MinZoom
+	}
+	s.Ui.Camera.SetZoom(zoom)
+	s.Ui.Camera.SetPosition(l.Camera.X, l.Camera.Y)
+	if l.Camera.Faded {
+		s.Ui.Camera.FadeAlpha = 255
+	}
 
-			// regular guys
-			u := sim.NewDefaultAnt()
-			u.SetTilePosition(4, 7)
-			s.sim.AddUnit(u)
-			// starting them mining here doesnt work!
-			//s.sim.IssueAction(u.ID.String(), &image.Point{X: 70, Y: 235}) // start him mining
+	l.setupObjectives(s)
+}
 
-			s.Ui.Camera.SetZoom(ui.MinZoom)
-			s.Ui.Camera.SetPosition(0, 105)
-			s.Ui.Camera.FadeAlpha = 255
+// setupObjectives builds the win and lose objectives for the units
+// currently in s.NamedUnits.
+func (l *LevelData) setupObjectives(s *PlayScene) {
+	var win, lose []Objective
+	for _, o := range l.Win {
+		win = append(win, l.newObjective(&o, s))
+	}
+	for _, o := range l.Lose {
+		lose = append(lose, l.newObjective(&o, s))
+	}
+	s.Objectives = NewObjectives(win, lose)
+}
 
-			s.inCutscene = true
-			s.Ui.DrawEnabled = false
-			s.drag.Enabled = false
-			s.constructionMouse.Enabled = false
+func (l *LevelData) newObjective(o *level.Objective, s *PlayScene) Objective {
+	faction := uint(level.Factions[o.Faction])
+	switch o.Type {
+	case "units_in_area":
+		var ids []string
+		for _, name := range o.Units {
+			ids = append(ids, s.NamedUnits[name])
+		}
+		text := cmp.Or(o.Text, fmt.Sprintf("%v reached the goal", strings.Join(o.Units, " and ")))
+		return &UnitsInAreaObjective{UnitIDs: ids, Area: s.tileMap.MapCompletionObjects[o.Area].Rect, Text: text}
+	case "unit_killed":
+		text := cmp.Or(o.Text, fmt.Sprintf("%v was killed", o.Unit))
+		return &UnitKilledObjective{UnitID: s.NamedUnits[o.Unit], Text: text}
+	case "hives_destroyed":
+		text := cmp.Or(o.Text, fmt.Sprintf("Every %v hive was destroyed", cmp.Or(o.Faction, "player")))
+		return &HivesDestroyedObjective{Faction: faction, Text: text, hadHive: l.spawnsHive(faction)}
+	case "timer":
+		text := cmp.Or(o.Text, "Time ran out")
+		return &TimerObjective{Ticks: uint64(o.Seconds * float64(s.sim.GetTPS())), Text: text}
+	case "resource":
+		text := cmp.Or(o.Text, fmt.Sprintf("%v %v reached %d", cmp.Or(o.Faction, "player"), o.Resource, o.Amount))
+		return &ResourceObjective{Faction: faction, Resource: o.Resource, Amount: o.Amount, Below: o.Below, Text: text}
+	}
+	panic(fmt.Sprintf("unknown objective type %q", o.Type)) // level.Parse rejects these
+}
 
-			s.CompletionCondition = NewSceneCompletion(queen, king, s.tileMap.MapCompletionObjects[0].Rect)
-			return queen.ID.String(), king.ID.String()
-		},
-		SetupInitialCutscene: func(s *PlayScene, cleopatroach string, antony string) {
-			s.cutsceneActions = []CutsceneAction{
-				&FadeCameraAction{Mode: "in", Speed: 2},
-				// &PanCameraAction{TargetX: float64(2), TargetY: float64(4), Speed: 300},
+// spawnsHive reports whether the level starts the faction off with a hive.
+func (l *LevelData) spawnsHive(faction uint) bool {
+	for _, sp := range l.Spawns {
+		if (sp.Kind == "hive" || sp.Kind == "roach_hive") && uint(level.Factions[sp.Faction]) == faction {
+			return true
+		}
+	}
+	return false
+}
 
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: Yon queen doth beckon from beyond the ravine. But soft! I lack timber for my grand mandibleway...",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&PanCameraAction{TargetX: float64(12), TargetY: float64(4), Speed: 400},
-				&IssueUnitCommandAction{
-					unitID:     cleopatroach,
-					targetTile: &image.Point{X: 31, Y: 9},
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Cleopatroach: The planks lie here, my love! But in return, thou must aid me in raising our mighty brood!",
-						ui.PortraitTypeRoyalRoach,
-					),
-				},
-				&PanCameraAction{TargetX: float64(4), TargetY: float64(4), Speed: 500},
-				&IssueUnitCommandAction{
-					unitID:     antony,
-					targetTile: &image.Point{X: 15, Y: 12},
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: Come, Cleopatroach, my thorax burns for thee - Let us entwine where petals crown the dirt, ",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: In yonder ring where daisies dare to bloom. ",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: There shall we clasp antennae, love, and fate, And make a kingdom of that perfumed ground.",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&IssueUnitCommandAction{
-					unitID:     antony,
-					targetTile: &image.Point{X: 9, Y: 9},
-				},
-				&PanCameraAction{TargetX: float64(1), TargetY: float64(1), Speed: 300},
-			}
-		},
-		SetupCompletionCutscene: func(s *PlayScene, cleopatroach string, antony string) {
-			s.inCutscene = true
-			s.inCutscene = true
-			s.Ui.DrawEnabled = false
-			s.drag.Enabled = false
+// SetupInitialCutscene queues the intro cutscene and tutorial.
+func (l *LevelData) SetupInitialCutscene(s *PlayScene) {
+	s.cutsceneActions = l.introScript.Compile(s)
+	s.tutorialDialogs = l.tutorialSteps(l.IntroTutorial)
+	if len(s.cutsceneActions) > 0 {
+		s.startCutscene()
+	}
+}
 
-			s.selectedUnitIDs = []string{} // clear selected unit IDs
+// SetupCompletionCutscene queues the completion cutscene, the next level
+// starts once it's over.
+func (l *LevelData) SetupCompletionCutscene(s *PlayScene) {
+	s.selectedUnitIDs = []string{} // clear selected unit IDs
+	s.cutsceneActions = l.completionScript.Compile(s)
+	s.tutorialDialogs = l.tutorialSteps(l.CompletionTutorial)
+	s.startCutscene()
+}
 
-			s.cutsceneActions = []CutsceneAction{
-				&IssueUnitCommandAction{
-					unitID:     cleopatroach,
-					targetTile: &image.Point{X: 18, Y: 15},
-				},
-				&IssueUnitCommandAction{
-					unitID:     antony,
-					targetTile: &image.Point{X: 14, Y: 11},
-				},
-				&WaitAction{
-					Duration: 1.0, // wait for 1 second
-				},
-				&IssueUnitCommandAction{
-					unitID:     cleopatroach,
-					targetTile: &image.Point{X: 17, Y: 14},
-				},
-				&IssueUnitCommandAction{
-					unitID:     antony,
-					targetTile: &image.Point{X: 15, Y: 12},
-				},
-				&WaitAction{
-					Duration: 0.5, // wait for 1 second
-				},
-				&PanCameraAction{TargetX: float64(4), TargetY: float64(4), Speed: 500},
-				&ZoomCameraAction{
-					TargetZoom: 0.8,
-					Speed:      1,
-					FocusX:     17 * 128,
-					FocusY:     15 * 128,
-				},
-				&DrawTemporarySpriteAction{
-					spr:            ui.NewHeartSprite(uuid.New()),
-					TargetPosition: &image.Point{X: 2104, Y: 1724},
-					MaxDuration:    180,
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: Sweet Cleopatroach, beneath these perfumed petals we meet, Yet even in this bloom,",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: the thorn of Rome doth prick my side. Octavian's shadow crawls o'er all our kingdoms vast,",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Antony: His claws poised to snatch the crown from humble thorax and wing alike",
-						ui.PortraitTypeRoyalAnt,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Cleopatroach: Antony, my lord, the Emperor Bugustus's gaze is cold and cruel,",
-						ui.PortraitTypeRoyalRoach,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Cleopatroach: His legions swarm the sands, his whispers poison the air.",
-						ui.PortraitTypeRoyalRoach,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Cleopatroach: Let us bind our broods, that none may sunder this fragile alliance.",
-						ui.PortraitTypeRoyalRoach,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Cleopatroach: Then let the courts of Bugustus tremble and the senate-mounds whisper,",
-						ui.PortraitTypeRoyalRoach,
-					),
-				},
-				&ShowPortraitTextAreaAction{
-					portraitTextArea: ui.NewPortraitTextArea(
-						s.fonts,
-						"Cleopatroach: For love, like the smallest insect, can move mountains and topple thrones.",
-						ui.PortraitTypeRoyalRoach,
-					),
-				},
-				&FadeCameraAction{Mode: "out", Speed: 1},
-			}
-		},
+func (l *LevelData) tutorialSteps(steps []level.TutorialStep) []Tutorial {
+	var tutorials []Tutorial
+	for _, step := range steps {
+		rect := image.Rect(step.Rect[0], step.Rect[1], step.Rect[2], step.Rect[3])
+		tutorials = append(tutorials, NewTutorialStep(step.Image, &rect, conditionCheck(step.Trigger), conditionCheck(step.Complete)))
 	}
-	coll.Levels[2] = LevelData{
-		LevelNumber: 2,
-		TileMapPath: "tilemap/map3.tmx",
-		LevelIntroText: `Thanks for playing the demo of ANTony & CleopatROACH! It was created for the Ebitengine Game Jam 2025, and is a work in progress.
-		
-		I wanted to add much more - combat, more levels, more story, more shakespeare puns (Enobarkbug!) and more features - but ran out of time in the two weeks alotted.
-		
-		I appreciate you playing this demo, and hope you enjoyed it!
-		
-		CREDITS:
-		
-		PROGRAMMING & EVERYTHING ELSE:
-		Charles Fahselt
-		
-		GOLANG CONSULTANT:
-		Medge
-
-		SHAKESPEARE CONSULTANT:
-		Chez Oxendine
+	return tutorials
+}
 
-		ART:
-		ChatGPT (and I did a little bit myself)
-		`,
-		SetupFunc: func(s *PlayScene) (string, string) {
-			s.Ui.Camera.SetZoom(ui.MinZoom)
-			return "", ""
-		},
-		SetupInitialCutscene:    func(s *PlayScene, cleopatroach string, antony string) {},
-		SetupCompletionCutscene: func(s *PlayScene, cleopatroach string, antony string) {},
+// conditionCheck returns nil for a missing condition so NewTutorialStep uses
+// its default.
+func conditionCheck(c *level.Condition) func(*PlayScene) bool {
+	if c == nil {
+		return nil
 	}
-	return coll
+	condition := levelConditions[c.Condition]
+	return func(ps *PlayScene) bool { return condition(ps, c.Amount) }
 }
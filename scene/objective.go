@@ -0,0 +1,156 @@
+package scene
+
+import (
+	"gamejam/log"
+	"gamejam/sim"
+	"image"
+	"log/slog"
+)
+
+// Objective is something a level is won or lost by. Objectives only look at
+// the sim, so they hold unit IDs rather than units and survive a save.
+type Objective interface {
+	Met(s *sim.T) bool
+	String() string // shown on the defeat screen
+}
+
+// Objectives are won once every Win objective is met, and lost as soon as
+// any Lose one is. A level without Win objectives can't be won.
+type Objectives struct {
+	Win  []Objective
+	Lose []Objective
+	log  *slog.Logger
+}
+
+func NewObjectives(win, lose []Objective) *Objectives {
+	return &Objectives{
+		Win:  win,
+		Lose: lose,
+		log:  log.NewLogger().With("for", "Objectives"),
+	}
+}
+
+func (o *Objectives) Won(s *sim.T) bool {
+	if o == nil || len(o.Win) == 0 {
+		return false
+	}
+	won := true
+	for _, objective := range o.Win {
+		// every one looks, some keep track of what they've seen
+		if !objective.Met(s) {
+			won = false
+		}
+	}
+	if won {
+		o.log.Warn("Scene completion condition met")
+	}
+	return won
+}
+
+// Lost returns the first lose objective that's met, or nil.
+func (o *Objectives) Lost(s *sim.T) Objective {
+	if o == nil {
+		return nil
+	}
+	for _, objective := range o.Lose {
+		if objective.Met(s) {
+			o.log.Warn("Scene failed", "objective", objective.String())
+			return objective
+		}
+	}
+	return nil
+}
+
+// UnitsInAreaObjective is met while all the units overlap Area.
+type UnitsInAreaObjective struct {
+	UnitIDs []string
+	Area    *image.Rectangle
+	Text    string
+}
+
+func (o *UnitsInAreaObjective) Met(s *sim.T) bool {
+	for _, id := range o.UnitIDs {
+		unit, err := s.GetUnitByID(id)
+		if err != nil || unit.IsDead() || !o.Area.Overlaps(*unit.Rect) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *UnitsInAreaObjective) String() string { return o.Text }
+
+// UnitKilledObjective is met once the unit is dead or gone from the sim.
+type UnitKilledObjective struct {
+	UnitID string
+	Text   string
+}
+
+func (o *UnitKilledObjective) Met(s *sim.T) bool {
+	unit, err := s.GetUnitByID(o.UnitID)
+	return err != nil || unit.IsDead()
+}
+
+func (o *UnitKilledObjective) String() string { return o.Text }
+
+// HivesDestroyedObjective is met when the faction had hives and all of them
+// have been knocked down. A faction that never had one can't lose them, the
+// level's spawns say whether it started with any.
+type HivesDestroyedObjective struct {
+	Faction uint
+	Text    string
+
+	hadHive bool
+}
+
+func (o *HivesDestroyedObjective) Met(s *sim.T) bool {
+	for _, building := range s.GetBuildingsByFaction(o.Faction) {
+		switch building.GetType() {
+		case sim.BuildingTypeHive, sim.BuildingTypeRoachHive:
+			o.hadHive = true
+			return false
+		}
+	}
+	return o.hadHive
+}
+
+func (o *HivesDestroyedObjective) String() string { return o.Text }
+
+// TimerObjective is met once the sim has run for Ticks updates.
+type TimerObjective struct {
+	Ticks uint64
+	Text  string
+}
+
+func (o *TimerObjective) Met(s *sim.T) bool {
+	return s.GetTick() >= o.Ticks
+}
+
+func (o *TimerObjective) String() string { return o.Text }
+
+// ResourceObjective is met while the faction has at least Amount of the
+// resource, or less than Amount when Below is set.
+type ResourceObjective struct {
+	Faction  uint
+	Resource string // sucrose or wood
+	Amount   int
+	Below    bool
+	Text     string
+}
+
+func (o *ResourceObjective) Met(s *sim.T) bool {
+	state := s.GetEnemyState()
+	if o.Faction == uint(sim.PlayerFaction) {
+		state = s.GetPlayerState()
+	}
+	have := int(state.Sucrose)
+	if o.Resource == "wood" {
+		have = int(state.Wood)
+	}
+	if o.Below {
+		return have < o.Amount
+	}
+	return have >= o.Amount
+}
+
+func (o *ResourceObjective) String() string { return o.Text }
@@ -1,6 +1,7 @@
 package scene
 
 import (
+	"bytes"
 	"fmt"
 	"gamejam/audio"
 	"gamejam/config"
@@ -17,6 +18,7 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 var PlayerFaction = 0
@@ -28,14 +30,16 @@ type PlayScene struct {
 	sound       *audio.SoundManager
 	songStarted bool
 
-	QueenID string
-	KingID  string
+	NamedUnits map[string]string // level spawn names to unit IDs
 
 	eventBus *eventing.EventBus
+	eventLog *bytes.Buffer // what the bus recorder wrote, nil unless RecordEvents
 	sim      *sim.T
 	Ui       *ui.Ui
 
 	tileMap           *tilemap.Tilemap
+	staticBg          *ebiten.Image
+	tileImages        map[int]*ebiten.Image // for swapping tiles on staticBg, see resource_tiles.go
 	drag              *ui.Drag
 	constructionMouse *ui.ConstructionMouse
 
@@ -44,33 +48,61 @@ type PlayScene struct {
 	Sprites         map[string]*ui.Sprite
 	selectedUnitIDs []string
 
+	// hotkeys, see control_groups.go
+	controlGroups   map[ebiten.Key][]string
+	lastGroupTap    controlGroupTap
+	cameraBookmarks map[ebiten.Key]CameraState
+
 	// Cutscene stuff
 	cutsceneActions []CutsceneAction
+	cutsceneIndex   int // how many actions of the current cutscene finished, for saves
 	inCutscene      bool
 	currentDialog   *ui.PortraitTextArea
+	triggerCutscene string   // script of the map trigger cutscene playing, "" for the level's own
+	queuedCutscenes []string // map trigger cutscenes waiting for the current one to end
 
 	// Tutorial stuff
 	tutorialDialogs []Tutorial
+	tutorialIndex   int
 	inTutorial      bool
 
+	// what map triggers added, see triggers.go
+	triggerTutorials []string
+	revealedLayers   []string
+
 	// Level completion
-	CompletionCondition *SceneCompletion
-	SceneCompleted      bool
+	Objectives     *Objectives
+	SceneCompleted bool
 
 	// Notifications
 	CurrentNotification *ui.Notification
 
 	ActionIssuedLocation   *image.Point
 	actionIssuedFrameTimer uint
+	targetingOrder         *sim.OrderType // move or attack button pressed, waiting for a click on the map
+
+	// where units recently died, drawn as a fading marker
+	deathMarkers []*deathMarker
 
 	Pause *ui.Pause
 }
 
+type deathMarker struct {
+	location   *image.Point
+	frameTimer uint
+}
+
 func NewPlayScene(fonts *fonts.All, sound *audio.SoundManager, levelData LevelData) *PlayScene {
 	config, _ := config.New()
 
 	tileMap := tilemap.NewTilemap(levelData.TileMapPath)
 	simulation := sim.New(60, tileMap)
+	simulation.EventBus.SetDebug(config.DebugEvents)
+	var eventLog *bytes.Buffer
+	if config.RecordEvents {
+		eventLog = &bytes.Buffer{}
+		simulation.EventBus.SetRecorder(eventing.NewRecorder(eventLog, simulation.GetTick))
+	}
 	constructionMouse := &ui.ConstructionMouse{}
 	scene := &PlayScene{
 		Config:            config,
@@ -80,33 +112,58 @@ func NewPlayScene(fonts *fonts.All, sound *audio.SoundManager, levelData LevelDa
 		sim:               simulation,
 		Ui:                ui.NewUi(fonts, tileMap, simulation),
 		tileMap:           tileMap,
+		staticBg:          ebiten.NewImageFromImage(tileMap.StaticBg),
 		drag:              ui.NewDrag(),
 		constructionMouse: constructionMouse,
 		Sprites:           make(map[string]*ui.Sprite),
+		NamedUnits:        make(map[string]string),
 		eventBus:          simulation.EventBus,
+		eventLog:          eventLog,
 		Pause:             ui.NewPause(sound, *fonts),
 	}
-	scene.constructionMouse.SetSprite("tilemap/bridge.png")
-	scene.eventBus.Subscribe("MakeAntButtonClickedEvent", scene.HandleMakeAntButtonClickedEvent)
-	scene.eventBus.Subscribe("MakeBridgeButtonClickedEvent", scene.HandleMakeBridgeButtonClickedEvent)
-	scene.eventBus.Subscribe("BuildClickedEvent", scene.HandleBuildClickedEvent)
-	scene.eventBus.Subscribe("NotEnoughResourcesEvent", scene.NotEnoughResourcesEvent)
-
-	scene.QueenID, scene.KingID = levelData.SetupFunc(scene)
+	scene.constructionMouse.SetBuilding(sim.BuildingTypeBridge)
+	eventing.Subscribe(scene.eventBus, scene.HandleMakeUnitButtonClickedEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleCancelConstructionButtonClickedEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleCancelQueuedUnitButtonClickedEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleMakeBuildingButtonClickedEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleBuildClickedEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleCommandButtonClickedEvent)
+	eventing.Subscribe(scene.eventBus, scene.NotEnoughResourcesEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleUnitDiedEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleBuildingDestroyedEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleResourceDepletedEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleResourceRegrownEvent)
+	eventing.Subscribe(scene.eventBus, scene.HandleTriggerEnteredEvent)
+
+	levelData.Setup(scene)
 
 	scene.setupSFX()
-	levelData.SetupInitialCutscene(scene, scene.QueenID, scene.KingID)
+	levelData.SetupInitialCutscene(scene)
+
+	scene.Pause.SaveSlots = SaveSlots
+	scene.Pause.OnSave = scene.SaveGame
+	scene.Pause.OnLoad = scene.LoadGame
 
 	return scene
 }
 
-func (s *PlayScene) NotEnoughResourcesEvent(event eventing.Event) {
-	resName := event.Data.(eventing.NotEnoughResourcesEvent).ResourceName
-	target := event.Data.(eventing.NotEnoughResourcesEvent).TargetBeingBuilt
+// startCutscene hands control over to the queued cutscene actions.
+func (s *PlayScene) startCutscene() {
+	s.inCutscene = true
+	s.Ui.DrawEnabled = false
+	s.drag.Enabled = false
+	s.constructionMouse.Enabled = false
+}
+
+func (s *PlayScene) NotEnoughResourcesEvent(event eventing.NotEnoughResourcesEvent) {
+	resName := event.ResourceName
+	target := event.TargetBeingBuilt
 
 	var str string
-	if target == "Bridge" {
-		str = fmt.Sprintf("Not enough %v to build %v\nOr builder is not close enough!", resName, target)
+	if event.Reason != "" {
+		str = event.Reason
+	} else if resName == "" {
+		str = fmt.Sprintf("Can't build %v there\nOr builder is not close enough!", target)
 	} else {
 		str = fmt.Sprintf("Not enough %v to build %v", resName, target)
 	}
@@ -114,32 +171,60 @@ func (s *PlayScene) NotEnoughResourcesEvent(event eventing.Event) {
 	s.CurrentNotification = ui.NewNotification(&s.fonts.Med, str)
 }
 
+func (s *PlayScene) HandleUnitDiedEvent(died eventing.UnitDiedEvent) {
+	delete(s.Sprites, died.UnitID)
+	s.selectedUnitIDs = slices.DeleteFunc(s.selectedUnitIDs, func(id string) bool { return id == died.UnitID })
+	s.removeFromControlGroups(died.UnitID)
+	s.deathMarkers = append(s.deathMarkers, &deathMarker{location: died.Position})
+	eventing.Publish(s.eventBus, eventing.PlayIssueActionSFXEvent{})
+}
+
+func (s *PlayScene) HandleBuildingDestroyedEvent(destroyed eventing.BuildingDestroyedEvent) {
+	delete(s.Sprites, destroyed.BuildingID)
+	s.selectedUnitIDs = slices.DeleteFunc(s.selectedUnitIDs, func(id string) bool { return id == destroyed.BuildingID })
+	s.removeFromControlGroups(destroyed.BuildingID)
+	s.deathMarkers = append(s.deathMarkers, &deathMarker{location: destroyed.Position})
+	eventing.Publish(s.eventBus, eventing.PlayIssueActionSFXEvent{})
+}
+
 func (s *PlayScene) setupSFX() {
 	//s.sound.GlobalVolume = 0.5
-	//s.eventBus.Subscribe("PlayWalkSFX", s.sound.PlayWalkSFX)
-	s.eventBus.Subscribe("PlayIssueActionSFX", s.sound.PlayIssueActionSFX)
-	s.eventBus.Subscribe("PlaySelectHiveSFX", s.sound.PlaySelectHiveSFX)
+	//eventing.Subscribe(s.eventBus, s.sound.PlayWalkSFX)
+	eventing.Subscribe(s.eventBus, s.sound.PlayIssueActionSFX)
+	eventing.Subscribe(s.eventBus, s.sound.PlaySelectHiveSFX)
 }
-func (s *PlayScene) HandleMakeAntButtonClickedEvent(event eventing.Event) {
+func (s *PlayScene) HandleMakeUnitButtonClickedEvent(event eventing.MakeUnitButtonClickedEvent) {
 	if len(s.selectedUnitIDs) == 1 {
 		hiveID := s.selectedUnitIDs[0]
 		unitOrHiveString := s.sim.DetermineUnitOrHiveById(hiveID)
 		if unitOrHiveString == "hive" {
-			s.eventBus.Publish(eventing.Event{
-				Type: "ConstructUnitEvent",
-				Data: eventing.ConstructUnitEvent{
-					HiveID: hiveID,
-				},
+			eventing.Publish(s.eventBus, eventing.ConstructUnitEvent{
+				HiveID:   hiveID,
+				UnitType: event.UnitType,
 			})
 		}
 	}
 }
 
-func (s *PlayScene) HandleMakeBridgeButtonClickedEvent(event eventing.Event) {
+func (s *PlayScene) HandleCancelConstructionButtonClickedEvent(eventing.CancelConstructionButtonClickedEvent) {
+	if len(s.selectedUnitIDs) == 1 && s.sim.CancelConstruction(s.selectedUnitIDs[0]) {
+		s.selectedUnitIDs = nil
+		s.Ui.HUD.RightSideState = ui.HiddenState
+	}
+}
+
+func (s *PlayScene) HandleCancelQueuedUnitButtonClickedEvent(event eventing.CancelQueuedUnitButtonClickedEvent) {
+	if len(s.selectedUnitIDs) == 1 {
+		s.sim.CancelUnit(s.selectedUnitIDs[0], event.Slot)
+	}
+}
+
+func (s *PlayScene) HandleMakeBuildingButtonClickedEvent(event eventing.MakeBuildingButtonClickedEvent) {
 	if len(s.selectedUnitIDs) == 1 {
 		unitID := s.selectedUnitIDs[0]
 		unitOrHiveString := s.sim.DetermineUnitOrHiveById(unitID)
 		if unitOrHiveString == "unit" {
+			s.constructionMouse.SetBuilding(sim.BuildingType(event.Building))
 			s.constructionMouse.Enabled = true
 			s.drag.Enabled = false
 			// s.eventBus.Publish(eventing.Event{
@@ -151,17 +236,19 @@ func (s *PlayScene) HandleMakeBridgeButtonClickedEvent(event eventing.Event) {
 		}
 	}
 }
-func (s *PlayScene) HandleBuildClickedEvent(event eventing.Event) {
-	targetRect := event.Data.(eventing.BuildClickedEvent).TargetRect
+func (s *PlayScene) HandleBuildClickedEvent(clicked eventing.BuildClickedEvent) {
+	targetRect, bt := clicked.TargetRect, sim.BuildingType(clicked.Building)
+	if len(s.selectedUnitIDs) == 1 && queueModifierPressed() {
+		// walk there after whatever else is queued, and keep placing
+		s.sim.QueueOrder(s.selectedUnitIDs[0], sim.BuildOrderFor(bt, *targetRect))
+		return
+	}
 	if len(s.selectedUnitIDs) == 1 {
-		success := s.sim.ConstructBuilding(targetRect, s.selectedUnitIDs[0])
+		success := s.sim.ConstructBuilding(bt, targetRect, s.selectedUnitIDs[0])
 		if !success {
-			s.eventBus.Publish(eventing.Event{
-				Type: "NotEnoughResourcesEvent",
-				Data: eventing.NotEnoughResourcesEvent{ // todo: add reason why, for example "unit not close enough" etc
-					ResourceName:     "Wood",
-					TargetBeingBuilt: "Bridge",
-				},
+			eventing.Publish(s.eventBus, eventing.NotEnoughResourcesEvent{ // "" for a bad spot or a builder that's too far
+				ResourceName:     s.sim.MissingResource(uint(sim.PlayerFaction), bt),
+				TargetBeingBuilt: sim.BuildingCatalog[bt].Name,
 			})
 		}
 	}
@@ -169,6 +256,55 @@ func (s *PlayScene) HandleBuildClickedEvent(event eventing.Event) {
 	s.constructionMouse.Enabled = false
 }
 
+// commandButtonOrders maps the HUD's command buttons to orders.
+var commandButtonOrders = map[string]sim.OrderType{
+	"attack": sim.AttackMoveOrder,
+	"move":   sim.MoveOrder,
+	"stop":   sim.StopOrder,
+	"hold":   sim.HoldOrder,
+}
+
+func (s *PlayScene) HandleCommandButtonClickedEvent(event eventing.CommandButtonClickedEvent) {
+	orderType, ok := commandButtonOrders[event.Command]
+	if !ok {
+		return
+	}
+	switch orderType {
+	case sim.StopOrder, sim.HoldOrder:
+		s.issueGroupAction(s.selectedUnitIDs, orderType, nil)
+	default:
+		// needs a target, the next left click on the map gives it
+		s.targetingOrder = &orderType
+		s.drag.Enabled = false
+		s.constructionMouse.Enabled = false
+	}
+}
+
+// updateTargeting gives the order picked with a command button to the
+// selected units once the map is clicked. Right click cancels, and reports
+// that it used the click.
+func (s *PlayScene) updateTargeting() bool {
+	if s.targetingOrder == nil {
+		return false
+	}
+	if len(s.selectedUnitIDs) == 0 || inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonRight) {
+		s.targetingOrder = nil
+		return true
+	}
+	if !inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
+		return false
+	}
+	mx, my := ebiten.CursorPosition()
+	mapX, mapY := s.Ui.Camera.ScreenPosToMapPos(mx, my)
+	s.ActionIssuedLocation = &image.Point{X: mapX, Y: mapY}
+	s.issueGroupAction(s.selectedUnitIDs, *s.targetingOrder, &image.Point{X: mapX, Y: mapY})
+	eventing.Publish(s.eventBus, eventing.PlayIssueActionSFXEvent{})
+	if !queueModifierPressed() { // keep targeting while queueing
+		s.targetingOrder = nil
+	}
+	return false
+}
+
 func (s *PlayScene) Update() error {
 	if !s.songStarted {
 		s.songStarted = true
@@ -185,9 +321,17 @@ func (s *PlayScene) Update() error {
 		return nil
 	}
 
-	if s.CompletionCondition.IsComplete(s.sim) && !s.SceneCompleted {
-		s.SceneCompleted = true
-		s.LevelData.SetupCompletionCutscene(s, s.QueenID, s.KingID)
+	if !s.SceneCompleted {
+		if lost := s.Objectives.Lost(s.sim); lost != nil {
+			s.sound.Stop("msx_gamesong1")
+			s.BaseScene.sm.SwitchTo(NewDefeatScene(s.fonts, s.sound, *s.LevelData, lost.String()))
+			return nil
+		}
+		if s.Objectives.Won(s.sim) {
+			s.SceneCompleted = true
+			s.cutsceneIndex, s.tutorialIndex = 0, 0
+			s.LevelData.SetupCompletionCutscene(s)
+		}
 	}
 	// make sure all the sim units are in the list of spritess
 	for _, unit := range s.sim.GetAllUnits() {
@@ -201,6 +345,8 @@ func (s *PlayScene) Update() error {
 				s.Sprites[unit.ID.String()] = ui.NewRoyalAntSprite(unit.ID)
 			case sim.UnitTypeRoyalRoach:
 				s.Sprites[unit.ID.String()] = ui.NewRoyalRoachSprite(unit.ID)
+			case sim.UnitTypeSoldierAnt:
+				s.Sprites[unit.ID.String()] = ui.NewSoldierAntSprite(unit.ID)
 			}
 
 		} else {
@@ -223,8 +369,16 @@ func (s *PlayScene) Update() error {
 				spr = ui.NewHiveSprite(building.GetID())
 			case sim.BuildingTypeRoachHive:
 				spr = ui.NewRoachHiveSprite(building.GetID())
+			case sim.BuildingTypeStorageDepot:
+				spr = ui.NewStorageDepotSprite(building.GetID())
+			case sim.BuildingTypeBarracks:
+				spr = ui.NewBarracksSprite(building.GetID())
+			case sim.BuildingTypeDefensiveMound:
+				spr = ui.NewDefensiveMoundSprite(building.GetID())
+			case sim.BuildingTypeNest:
+				spr = ui.NewNestSprite(building.GetID())
 			case sim.BuildingTypeInConstruction:
-				spr = ui.NewInConstructionSprite(building.GetID())
+				spr = ui.NewInConstructionSprite(building.GetID(), building.GetRect().Size())
 			}
 			s.Sprites[building.GetID().String()] = spr
 			s.Sprites[building.GetID().String()].SetPosition(building.GetPosition())
@@ -233,6 +387,7 @@ func (s *PlayScene) Update() error {
 			s.Sprites[building.GetID().String()].SetPosition(building.GetPosition())
 			s.Sprites[building.GetID().String()].ProgressBar.SetProgress(building.GetProgress())
 		}
+		s.Sprites[building.GetID().String()].SetHP(building.GetHP())
 	}
 	// remove building & unit sprites that are no longer in the SIM
 	s.UpdateRemoveInactiveSprites()
@@ -248,10 +403,20 @@ func (s *PlayScene) Update() error {
 		dt := 1.0 / 60.0 // or use actual delta time
 		if len(s.cutsceneActions) == 0 {
 			if s.SceneCompleted {
+				if s.Config.RecordReplays {
+					s.saveReplay()
+				}
+				if s.eventLog != nil {
+					s.saveEventLog()
+				}
 				LevelData := NewLevelCollection().Levels[s.LevelData.LevelNumber+1]
 				s.sound.Stop("msx_gamesong1")
 				s.BaseScene.sm.SwitchTo(NewNarratorScene(s.fonts, s.sound, LevelData)) // switch to next level
 			}
+			s.triggerCutscene = ""
+			if s.nextTriggerCutscene() {
+				return nil
+			}
 			s.inCutscene = false
 			s.Ui.DrawEnabled = true
 			s.drag.Enabled = true
@@ -262,6 +427,7 @@ func (s *PlayScene) Update() error {
 			}
 			if currentCutScene.Update(s, dt) {
 				s.cutsceneActions = s.cutsceneActions[1:]
+				s.cutsceneIndex++
 			}
 			// Early return to skip normal controls
 			return nil
@@ -274,6 +440,7 @@ func (s *PlayScene) Update() error {
 		s.tutorialDialogs[0].CheckTrigger(s) // Check the first tutorial dialog trigger
 		if s.tutorialDialogs[0].IsComplete() {
 			s.tutorialDialogs = s.tutorialDialogs[1:] // Remove the completed dialog
+			s.tutorialIndex++
 			if len(s.tutorialDialogs) == 0 {
 				s.inTutorial = false // No more tutorial dialogs
 			}
@@ -286,6 +453,9 @@ func (s *PlayScene) Update() error {
 		s.currentDialog = nil // no active tutorial dialog
 	}
 
+	s.updateHotkeys()
+	rightClickUsed := s.updateTargeting()
+
 	// handle selectedIDs
 	for _, spr := range s.Sprites {
 		if spr.Type == ui.SpriteTypeStatic {
@@ -321,15 +491,23 @@ func (s *PlayScene) Update() error {
 			switch unitOrHiveString {
 			case "hive":
 				// handle hive
-				// show hive UI elements
-
-				if s.Ui.HUD.RightSideState != ui.HiveSelectedState {
-					s.eventBus.Publish(eventing.Event{
-						Type: "PlaySelectHiveSFX",
-					})
+				// show hive UI elements, only buildings that make units have any
+				if s.sim.IsBuildingSite(s.selectedUnitIDs[0]) {
+					s.Ui.HUD.RightSideState = ui.SiteSelectedState
+				} else if !s.sim.MakesUnits(s.selectedUnitIDs[0]) {
+					s.Ui.HUD.RightSideState = ui.HiddenState
+				} else if s.Ui.HUD.RightSideState != ui.HiveSelectedState {
+					eventing.Publish(s.eventBus, eventing.PlaySelectHiveSFXEvent{})
 					s.Ui.HUD.RightSideState = ui.HiveSelectedState
 					s.constructionMouse.Enabled = false
 				}
+				if s.Ui.HUD.RightSideState == ui.HiveSelectedState && inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonRight) && !rightClickUsed {
+					mx, my := ebiten.CursorPosition()
+					mapX, mapY := s.Ui.Camera.ScreenPosToMapPos(mx, my)
+					s.ActionIssuedLocation = &image.Point{X: mapX, Y: mapY}
+					s.sim.SetRallyPoint(s.selectedUnitIDs[0], image.Pt(mapX, mapY))
+					eventing.Publish(s.eventBus, eventing.PlayIssueActionSFXEvent{})
+				}
 				// s.eventBus.Publish(eventing.Event{
 				// 	Type: "ToggleRightSideHUDEvent",
 				// 	Data: eventing.ToggleRightSideHUDEvent{
@@ -343,15 +521,13 @@ func (s *PlayScene) Update() error {
 					s.constructionMouse.Enabled = false
 				}
 				// handle unit and clicks
-				if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonRight) { // activate on buttonRelease to debounce
+				if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonRight) && !rightClickUsed { // activate on buttonRelease to debounce
 					mx, my := ebiten.CursorPosition()
 					for _, unitId := range s.selectedUnitIDs {
 						mapX, mapY := s.Ui.Camera.ScreenPosToMapPos(mx, my)
 						s.ActionIssuedLocation = &image.Point{X: mapX, Y: mapY}
-						s.sim.IssueAction(unitId, s.ActionIssuedLocation)
-						s.eventBus.Publish(eventing.Event{
-							Type: "PlayIssueActionSFX",
-						})
+						s.issueAction(unitId, sim.SmartOrder, s.ActionIssuedLocation)
+						eventing.Publish(s.eventBus, eventing.PlayIssueActionSFXEvent{})
 					}
 				}
 			}
@@ -362,15 +538,12 @@ func (s *PlayScene) Update() error {
 			}
 
 			// handle multiple units/buildings selected
-			if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonRight) { // activate on buttonRelease to debounce
+			if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonRight) && !rightClickUsed { // activate on buttonRelease to debounce
 				mx, my := ebiten.CursorPosition()
-				for _, unitId := range s.selectedUnitIDs {
-					mapX, mapY := s.Ui.Camera.ScreenPosToMapPos(mx, my)
-					s.sim.IssueAction(unitId, &image.Point{X: mapX, Y: mapY})
-					s.eventBus.Publish(eventing.Event{
-						Type: "PlayIssueActionSFX",
-					})
-				}
+				mapX, mapY := s.Ui.Camera.ScreenPosToMapPos(mx, my)
+				s.ActionIssuedLocation = &image.Point{X: mapX, Y: mapY}
+				s.issueGroupAction(s.selectedUnitIDs, sim.SmartOrder, &image.Point{X: mapX, Y: mapY})
+				eventing.Publish(s.eventBus, eventing.PlayIssueActionSFXEvent{})
 			}
 		}
 	} else {
@@ -380,9 +553,16 @@ func (s *PlayScene) Update() error {
 			s.constructionMouse.Enabled = false
 		}
 	}
+	s.Ui.HUD.SelectedHiveID = ""
+	if len(s.selectedUnitIDs) == 1 && s.Ui.HUD.RightSideState == ui.HiveSelectedState {
+		s.Ui.HUD.SelectedHiveID = s.selectedUnitIDs[0]
+	}
+	s.Ui.HUD.CommandsVisible = slices.ContainsFunc(s.selectedUnitIDs, func(id string) bool {
+		return s.sim.DetermineUnitOrHiveById(id) == "unit"
+	})
 	s.drag.Update(s.Sprites, s.Ui.Camera, s.Ui.HUD)
 	s.constructionMouse.Update(s.tileMap, s.sim)
-	if !s.constructionMouse.Enabled {
+	if !s.constructionMouse.Enabled && s.targetingOrder == nil {
 		s.drag.Enabled = true
 	}
 	s.Ui.Update()
@@ -413,7 +593,7 @@ func (s *PlayScene) Draw(screen *ebiten.Image) {
 	opts := &ebiten.DrawImageOptions{}
 	opts.GeoM.Scale(s.Ui.Camera.ViewPortZoom, s.Ui.Camera.ViewPortZoom)
 	opts.GeoM.Translate(float64(s.Ui.Camera.ViewPortX), float64(s.Ui.Camera.ViewPortY))
-	screen.DrawImage(s.Ui.TileMap.StaticBg, opts)
+	screen.DrawImage(s.staticBg, opts)
 
 	if s.Config.DebugDraw {
 		s.DebugDraw(screen)
@@ -432,8 +612,12 @@ func (s *PlayScene) Draw(screen *ebiten.Image) {
 			sprite.Draw(screen, s.Ui.Camera)
 		}
 	}
+	s.drawQueuedOrders(screen)
+	s.drawRallyPoint(screen)
+	s.drawTargetingCursor(screen)
 	// draw expanding circle to indicate action issued at location
 	s.drawExpandingActionIssuedCircle(screen)
+	s.drawDeathMarkers(screen)
 
 	s.Ui.Draw(screen)
 
@@ -483,6 +667,112 @@ func (s *PlayScene) drawExpandingActionIssuedCircle(screen *ebiten.Image) {
 	}
 }
 
+// issueAction gives the unit an order, adding it to the unit's queue
+// instead when shift is held.
+func (s *PlayScene) issueAction(unitID string, orderType sim.OrderType, point *image.Point) {
+	if queueModifierPressed() {
+		s.sim.QueueAction(unitID, orderType, point)
+	} else {
+		s.sim.IssueAction(unitID, orderType, point)
+	}
+}
+
+// drawTargetingCursor rings the cursor while the map is waiting for a click
+// from the move or attack button.
+func (s *PlayScene) drawTargetingCursor(screen *ebiten.Image) {
+	if s.targetingOrder == nil {
+		return
+	}
+	ringColor := color.RGBA{127, 255, 0, 255}
+	if *s.targetingOrder == sim.AttackMoveOrder {
+		ringColor = color.RGBA{220, 30, 30, 255}
+	}
+	mx, my := ebiten.CursorPosition()
+	vector.StrokeCircle(screen, float32(mx), float32(my), 10, 2, ringColor, true)
+}
+
+// issueGroupAction is issueAction for several units, which spreads them
+// into a formation around point.
+func (s *PlayScene) issueGroupAction(unitIDs []string, orderType sim.OrderType, point *image.Point) {
+	if queueModifierPressed() {
+		s.sim.QueueGroupAction(unitIDs, orderType, point)
+	} else {
+		s.sim.IssueGroupAction(unitIDs, orderType, point)
+	}
+}
+
+func queueModifierPressed() bool {
+	return ebiten.IsKeyPressed(ebiten.KeyShift)
+}
+
+// drawQueuedOrders draws the waypoints of selected units that have orders
+// queued, or of all of them while shift is held.
+func (s *PlayScene) drawQueuedOrders(screen *ebiten.Image) {
+	for _, id := range s.selectedUnitIDs {
+		unit, err := s.sim.GetUnitByID(id)
+		if err != nil || (len(unit.Orders) == 0 && !queueModifierPressed()) {
+			continue
+		}
+		points := unit.GetQueuedPoints()
+		from := *unit.GetCenteredPosition()
+		for i, point := range points {
+			x0, y0 := s.Ui.Camera.MapPosToScreenPos(from.X, from.Y)
+			x1, y1 := s.Ui.Camera.MapPosToScreenPos(point.X, point.Y)
+			lineColor := color.RGBA{127, 255, 0, 90}
+			if i > 0 {
+				lineColor = color.RGBA{255, 215, 0, 140} // queued
+			}
+			vector.StrokeLine(screen, float32(x0), float32(y0), float32(x1), float32(y1), 2, lineColor, true)
+			vector.DrawFilledCircle(screen, float32(x1), float32(y1), 4, lineColor, true)
+			from = point
+		}
+	}
+}
+
+// drawRallyPoint plants a flag where the selected hive sends its units,
+// with a line back to the hive.
+func (s *PlayScene) drawRallyPoint(screen *ebiten.Image) {
+	if len(s.selectedUnitIDs) != 1 {
+		return
+	}
+	point, ok := s.sim.GetRallyPoint(s.selectedUnitIDs[0])
+	if !ok {
+		return
+	}
+	building, err := s.sim.GetBuildingByID(s.selectedUnitIDs[0])
+	if err != nil {
+		return
+	}
+	rect := building.GetRect()
+	center := rect.Min.Add(rect.Max).Div(2)
+	x0, y0 := s.Ui.Camera.MapPosToScreenPos(center.X, center.Y)
+	x1, y1 := s.Ui.Camera.MapPosToScreenPos(point.X, point.Y)
+	flagColor := color.RGBA{255, 215, 0, 255}
+	vector.StrokeLine(screen, float32(x0), float32(y0), float32(x1), float32(y1), 1, color.RGBA{255, 215, 0, 90}, true)
+	vector.StrokeLine(screen, float32(x1), float32(y1), float32(x1), float32(y1-24), 2, flagColor, true)
+	vector.DrawFilledRect(screen, float32(x1), float32(y1-24), 14, 9, flagColor, true)
+	vector.DrawFilledCircle(screen, float32(x1), float32(y1), 3, flagColor, true)
+}
+
+func (s *PlayScene) drawDeathMarkers(screen *ebiten.Image) {
+	for _, marker := range s.deathMarkers {
+		mx, my := s.Ui.Camera.MapPosToScreenPos(marker.location.X, marker.location.Y)
+		radius := 30 - int(float64(marker.frameTimer)*0.5)
+		for angle := 0; angle < 360; angle++ {
+			rad := float64(angle) * (math.Pi / 180)
+			x := mx + int(float64(radius)*math.Cos(rad))
+			y := my + int(float64(radius)*math.Sin(rad))
+			if x >= 0 && y >= 0 && x < screen.Bounds().Dx() && y < screen.Bounds().Dy() {
+				screen.Set(x, y, color.RGBA{200, 0, 0, 255})
+			}
+		}
+		marker.frameTimer++
+	}
+	s.deathMarkers = slices.DeleteFunc(s.deathMarkers, func(marker *deathMarker) bool {
+		return marker.frameTimer >= 60
+	})
+}
+
 func (s *PlayScene) DebugDraw(screen *ebiten.Image) {
 	for _, mo := range s.tileMap.MapObjects {
 		rect := mo.Rect
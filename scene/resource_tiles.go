@@ -0,0 +1,53 @@
+package scene
+
+import (
+	"gamejam/eventing"
+	"gamejam/log"
+	"gamejam/tilemap"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// HandleResourceDepletedEvent swaps a harvested out tile for its empty look.
+func (s *PlayScene) HandleResourceDepletedEvent(depleted eventing.ResourceDepletedEvent) {
+	s.drawBgTile(depleted.Tile, tilemap.DepletedTileIDs[depleted.Resource])
+}
+
+// HandleResourceRegrownEvent puts the map's own tile back.
+func (s *PlayScene) HandleResourceRegrownEvent(regrown eventing.ResourceRegrownEvent) {
+	s.drawBgTile(regrown.Tile, s.tileMap.Tiles[regrown.Tile.X][regrown.Tile.Y].TileID)
+}
+
+// redrawDepletedResources empties the tiles of nodes that were already
+// harvested dry, e.g. after loading a save.
+func (s *PlayScene) redrawDepletedResources() {
+	for _, node := range s.sim.GetResourceNodes() {
+		if node.Depleted() {
+			s.drawBgTile(node.Tile, tilemap.DepletedTileIDs[node.Type])
+		}
+	}
+}
+
+// drawBgTile paints over one tile of the background with another tile from
+// the tileset.
+func (s *PlayScene) drawBgTile(tile image.Point, id int) {
+	if s.tileImages == nil {
+		s.tileImages = make(map[int]*ebiten.Image)
+	}
+	img, ok := s.tileImages[id]
+	if !ok {
+		loaded, err := s.tileMap.TileImage(id)
+		if err != nil {
+			log.NewLogger().With("for", "PlayScene").Warn("unable to load tile", "id", id, "err", err)
+			return
+		}
+		img = ebiten.NewImageFromImage(loaded)
+		s.tileImages[id] = img
+	}
+	size := float64(s.tileMap.TileSize)
+	opts := &ebiten.DrawImageOptions{Blend: ebiten.BlendCopy}
+	opts.GeoM.Scale(size/float64(img.Bounds().Dx()), size/float64(img.Bounds().Dy()))
+	opts.GeoM.Translate(float64(tile.X)*size, float64(tile.Y)*size)
+	s.staticBg.DrawImage(img, opts)
+}
@@ -0,0 +1,176 @@
+package scene
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gamejam/audio"
+	"gamejam/fonts"
+	"gamejam/log"
+	"gamejam/sim"
+	"gamejam/storage"
+	"io/fs"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// SaveVersion is bumped whenever SaveGame changes shape.
+const SaveVersion = 3
+
+var SaveSlots = 3
+
+// SaveGame is a PlayScene in progress. Cutscenes and tutorials are rebuilt
+// from the level and fast forwarded, so only how far along they are is kept.
+type SaveGame struct {
+	Version        int
+	LevelNumber    int
+	NamedUnits     map[string]string
+	Sim            *sim.Snapshot
+	SceneCompleted bool
+	InCutscene     bool
+	CutsceneIndex  int // cutscene actions already finished
+	TutorialIndex  int // tutorial steps already completed
+	Camera         CameraState
+
+	// from map triggers
+	TriggerCutscene  string   `json:",omitempty"` // the cutscene CutsceneIndex is for, if not the level's
+	QueuedCutscenes  []string `json:",omitempty"`
+	TriggerTutorials []string `json:",omitempty"` // come after the level's tutorial steps
+	RevealedLayers   []string `json:",omitempty"`
+}
+
+type CameraState struct {
+	ViewPortX    int
+	ViewPortY    int
+	ViewPortZoom float64
+	FadeAlpha    uint8
+}
+
+func saveSlotName(slot int) string {
+	return fmt.Sprintf("save-%d.json", slot)
+}
+
+func (s *PlayScene) SaveGame(slot int) error {
+	save := SaveGame{
+		Version:        SaveVersion,
+		LevelNumber:    s.LevelData.LevelNumber,
+		NamedUnits:     s.NamedUnits,
+		Sim:            s.sim.Snapshot(),
+		SceneCompleted: s.SceneCompleted,
+		InCutscene:     s.inCutscene,
+		CutsceneIndex:  s.cutsceneIndex,
+		TutorialIndex:  s.tutorialIndex,
+		Camera: CameraState{
+			ViewPortX:    s.Ui.Camera.ViewPortX,
+			ViewPortY:    s.Ui.Camera.ViewPortY,
+			ViewPortZoom: s.Ui.Camera.ViewPortZoom,
+			FadeAlpha:    s.Ui.Camera.FadeAlpha,
+		},
+
+		TriggerCutscene:  s.triggerCutscene,
+		QueuedCutscenes:  s.queuedCutscenes,
+		TriggerTutorials: s.triggerTutorials,
+		RevealedLayers:   s.revealedLayers,
+	}
+	data, err := json.Marshal(save)
+	if err != nil {
+		return err
+	}
+	return storage.Write(saveSlotName(slot), data)
+}
+
+// saveReplay stores the inputs of this level so far, see sim.Recording.
+func (s *PlayScene) saveReplay() {
+	data, err := json.Marshal(s.sim.GetRecording())
+	if err == nil {
+		err = storage.Write(fmt.Sprintf("replay-level-%d.json", s.LevelData.LevelNumber), data)
+	}
+	if err != nil {
+		log.NewLogger().With("for", "PlayScene").Warn("unable to save replay", "err", err)
+	}
+}
+
+func (s *PlayScene) saveEventLog() {
+	err := storage.Write(fmt.Sprintf("events-level-%d.jsonl", s.LevelData.LevelNumber), s.eventLog.Bytes())
+	if err != nil {
+		log.NewLogger().With("for", "PlayScene").Warn("unable to save event log", "err", err)
+	}
+}
+
+// LoadGame replaces this scene with the one saved in slot.
+func (s *PlayScene) LoadGame(slot int) error {
+	data, err := storage.Read(saveSlotName(slot))
+	if errors.Is(err, fs.ErrNotExist) {
+		return errors.New("nothing saved")
+	} else if err != nil {
+		return err
+	}
+	var save SaveGame
+	if err := json.Unmarshal(data, &save); err != nil {
+		return fmt.Errorf("reading save slot %d: %w", slot, err)
+	}
+	loaded, err := LoadPlayScene(s.fonts, s.sound, &save)
+	if err != nil {
+		return err
+	}
+	s.sound.Stop("msx_gamesong1")
+	s.BaseScene.sm.SwitchTo(loaded)
+	return nil
+}
+
+// LoadPlayScene sets the saved level up as usual, then swaps in the saved
+// sim and skips the cutscene and tutorial steps that were already done.
+func LoadPlayScene(fonts *fonts.All, sound *audio.SoundManager, save *SaveGame) (*PlayScene, error) {
+	if save.Version != SaveVersion {
+		return nil, fmt.Errorf("save version %d, expected %d", save.Version, SaveVersion)
+	}
+	levelData, ok := NewLevelCollection().Levels[save.LevelNumber]
+	if !ok {
+		return nil, fmt.Errorf("save is for unknown level %d", save.LevelNumber)
+	}
+	s := NewPlayScene(fonts, sound, levelData)
+	if err := s.sim.Restore(save.Sim); err != nil {
+		return nil, err
+	}
+	if len(save.RevealedLayers) > 0 {
+		s.revealedLayers = save.RevealedLayers
+		s.staticBg = ebiten.NewImageFromImage(s.tileMap.RenderBg(s.revealedLayers))
+	}
+	s.redrawDepletedResources()
+
+	// the level spawned its own named units, point everything at the saved ones
+	s.NamedUnits = save.NamedUnits
+	levelData.setupObjectives(s)
+	s.SceneCompleted = save.SceneCompleted
+	if s.SceneCompleted {
+		levelData.SetupCompletionCutscene(s)
+	} else {
+		levelData.SetupInitialCutscene(s)
+		for _, image := range save.TriggerTutorials {
+			s.addTriggerTutorial(image)
+		}
+	}
+	s.queuedCutscenes = save.QueuedCutscenes
+
+	s.inCutscene = save.InCutscene
+	if s.inCutscene {
+		if save.TriggerCutscene != "" {
+			s.triggerCutscene = save.TriggerCutscene
+			s.cutsceneActions = levelData.triggerScripts[save.TriggerCutscene].Compile(s)
+		}
+		s.cutsceneIndex = min(save.CutsceneIndex, len(s.cutsceneActions))
+		s.cutsceneActions = s.cutsceneActions[s.cutsceneIndex:]
+	} else {
+		s.cutsceneActions = nil
+		s.Ui.DrawEnabled = true
+		s.drag.Enabled = true
+	}
+	s.tutorialIndex = min(save.TutorialIndex, len(s.tutorialDialogs))
+	s.tutorialDialogs = s.tutorialDialogs[s.tutorialIndex:]
+
+	s.Ui.Camera.ViewPortX = save.Camera.ViewPortX
+	s.Ui.Camera.ViewPortY = save.Camera.ViewPortY
+	s.Ui.Camera.ViewPortZoom = save.Camera.ViewPortZoom
+	s.Ui.Camera.FadeAlpha = save.Camera.FadeAlpha
+	return s, nil
+}
@@ -0,0 +1,65 @@
+package scene
+
+import (
+	"gamejam/eventing"
+	"image"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// TriggerTutorialRect is where tutorial images from map triggers show up.
+var TriggerTutorialRect = image.Rect(412, 341, 800, 600)
+
+// HandleTriggerEnteredEvent runs what a map trigger asks for. Spawns are the
+// sim's business, everything else is the scene's, see tilemap.MapTrigger.
+func (s *PlayScene) HandleTriggerEnteredEvent(entered eventing.TriggerEnteredEvent) {
+	switch entered.OnEnter {
+	case "cutscene":
+		if s.SceneCompleted {
+			return
+		}
+		s.queuedCutscenes = append(s.queuedCutscenes, entered.Script)
+		if !s.inCutscene {
+			s.nextTriggerCutscene()
+		}
+	case "tutorial":
+		if s.SceneCompleted {
+			return
+		}
+		s.addTriggerTutorial(entered.Script)
+	case "reveal":
+		s.toggleLayer(entered.Script)
+	}
+}
+
+// nextTriggerCutscene starts the oldest cutscene a trigger queued up while
+// another one was playing, if there is one.
+func (s *PlayScene) nextTriggerCutscene() bool {
+	if len(s.queuedCutscenes) == 0 {
+		return false
+	}
+	s.triggerCutscene = s.queuedCutscenes[0]
+	s.queuedCutscenes = s.queuedCutscenes[1:]
+	s.cutsceneActions = s.LevelData.triggerScripts[s.triggerCutscene].Compile(s)
+	s.cutsceneIndex = 0
+	s.startCutscene()
+	return true
+}
+
+func (s *PlayScene) addTriggerTutorial(image string) {
+	rect := TriggerTutorialRect
+	s.tutorialDialogs = append(s.tutorialDialogs, NewTutorialStep(image, &rect, nil, nil))
+	s.triggerTutorials = append(s.triggerTutorials, image)
+}
+
+// toggleLayer shows a hidden tile layer or hides a shown one.
+func (s *PlayScene) toggleLayer(name string) {
+	if i := slices.Index(s.revealedLayers, name); i >= 0 {
+		s.revealedLayers = slices.Delete(s.revealedLayers, i, i+1)
+	} else {
+		s.revealedLayers = append(s.revealedLayers, name)
+	}
+	s.staticBg = ebiten.NewImageFromImage(s.tileMap.RenderBg(s.revealedLayers))
+	s.redrawDepletedResources()
+}
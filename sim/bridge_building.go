@@ -5,7 +5,7 @@ type BridgeBuilding struct {
 }
 
 func NewBridgeBuilding(x, y int) BuildingInterface {
-	building := NewBuilding(x, y, TileDimensions, TileDimensions, 1, BuildingTypeBridge, 0)
+	building := NewBuilding(x, y, TileDimensions, TileDimensions, uint(NeutralFaction), BuildingTypeBridge, 0)
 
 	bb := &BridgeBuilding{
 		Building: building,
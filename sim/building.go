@@ -18,6 +18,21 @@ type Building struct {
 
 	ProgressMax     uint
 	ProgressCurrent uint
+
+	HPMax uint // 0 for buildings that can't be hurt, see BuildingHP
+	HPCur uint
+}
+
+// BuildingHP is how much damage each type of building takes before it's
+// destroyed. Types that aren't here, like bridges, can't be hurt.
+var BuildingHP = map[BuildingType]uint{
+	BuildingTypeInConstruction: 200,
+	BuildingTypeHive:           1000,
+	BuildingTypeRoachHive:      1000,
+	BuildingTypeStorageDepot:   400,
+	BuildingTypeBarracks:       600,
+	BuildingTypeDefensiveMound: 500,
+	BuildingTypeNest:           300,
 }
 
 type BuildingType int
@@ -27,6 +42,10 @@ const (
 	BuildingTypeHive
 	BuildingTypeRoachHive
 	BuildingTypeBridge
+	BuildingTypeStorageDepot
+	BuildingTypeBarracks
+	BuildingTypeDefensiveMound
+	BuildingTypeNest
 )
 
 type BuildingInterface interface {
@@ -39,13 +58,15 @@ type BuildingInterface interface {
 	GetClosestPosition(x, y int) *image.Point
 	GetRect() *image.Rectangle
 	GetFaction() uint
+	SetFaction(faction uint)
 
 	Update(sim *T) // if buildings have an Update behavior
 	DistanceTo(point image.Point) uint
 	GetProgress() float64
 
-	// needs to be implemented by inheriting building
-	AddUnitToBuildQueue()
+	GetHP() (cur, max uint)
+	TakeDamage(amount uint)
+	IsDestroyed() bool
 }
 
 func NewBuilding(x, y, width, height int, faction uint, bt BuildingType, progressMax uint) *Building {
@@ -61,6 +82,8 @@ func NewBuilding(x, y, width, height int, faction uint, bt BuildingType, progres
 		Rect:        rect,
 		Faction:     faction,
 		ProgressMax: progressMax,
+		HPMax:       BuildingHP[bt],
+		HPCur:       BuildingHP[bt],
 	}
 }
 
@@ -70,9 +93,10 @@ func (b *Building) SetPosition(x, y, width, height int) {
 	b.Rect.Max = image.Point{X: x + width, Y: y + height}
 }
 func (b *Building) SetTilePosition(x, y int) {
+	size := b.Rect.Size()
 	b.Position = &image.Point{X: x * TileDimensions, Y: y * TileDimensions}
 	b.Rect.Min = *b.Position
-	b.Rect.Max = image.Point{X: b.Position.X + TileDimensions*2, Y: b.Position.Y + TileDimensions*2}
+	b.Rect.Max = b.Position.Add(size)
 }
 
 func (b *Building) GetID() uuid.UUID          { return b.ID }
@@ -108,6 +132,7 @@ func (b *Building) GetClosestPosition(x, y int) *image.Point {
 
 func (b *Building) GetRect() *image.Rectangle { return b.Rect }
 func (b *Building) GetFaction() uint          { return b.Faction }
+func (b *Building) SetFaction(faction uint)   { b.Faction = faction }
 func (b *Building) DistanceTo(point image.Point) uint {
 	xDist := math.Abs(float64(b.Position.X - point.X))
 	yDist := math.Abs(float64(b.Position.Y - point.Y))
@@ -119,5 +144,15 @@ func (b *Building) GetProgress() float64 {
 	}
 	return float64(b.ProgressCurrent) / float64(b.ProgressMax)
 }
-func (b *Building) Update(_ *T)          {} // Default no-op
-func (b *Building) AddUnitToBuildQueue() {}
+func (b *Building) Update(_ *T) {} // Default no-op
+
+func (b *Building) GetHP() (cur, max uint) { return b.HPCur, b.HPMax }
+
+// TakeDamage lowers HP without underflowing, buildings that can't be hurt
+// ignore it. Destroyed buildings are taken out at the end of the tick.
+func (b *Building) TakeDamage(amount uint) {
+	b.HPCur -= min(amount, b.HPCur)
+}
+
+// IsDestroyed reports whether the building has been knocked down.
+func (b *Building) IsDestroyed() bool { return b.HPMax > 0 && b.HPCur == 0 }
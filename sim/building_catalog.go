@@ -0,0 +1,154 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"gamejam/tilemap"
+	"image"
+	"slices"
+)
+
+// Placement is where a building is allowed to go.
+type Placement int
+
+const (
+	// PlaceOnBuildable needs one of the map's buildable spots, e.g. a chasm
+	// for a bridge. Its collision is taken out once the building is done.
+	PlaceOnBuildable Placement = iota
+	// PlaceOnGround needs open walkable ground without resources, units or
+	// other buildings on it.
+	PlaceOnGround
+)
+
+// BuildingSpec is what it takes to put up a building.
+type BuildingSpec struct {
+	Name        string
+	WoodCost    uint16
+	SucroseCost uint16
+	Size        image.Point // footprint in tiles
+	BuildTime   uint        // ticks
+	Placement   Placement
+	Neutral     bool // belongs to nobody once it's done, like bridges
+	New         func() BuildingInterface
+}
+
+// BuildingCatalog is everything builders can put up, tweak the specs to
+// rebalance.
+var BuildingCatalog = map[BuildingType]*BuildingSpec{
+	BuildingTypeBridge: {
+		Name: "Bridge", WoodCost: 50, Size: image.Pt(1, 1), BuildTime: 160,
+		Placement: PlaceOnBuildable, Neutral: true,
+		New: func() BuildingInterface { return NewBridgeBuilding(0, 0) },
+	},
+	BuildingTypeHive: {
+		Name: "Hive", WoodCost: 200, SucroseCost: 100, Size: image.Pt(2, 2), BuildTime: 900,
+		Placement: PlaceOnGround, New: NewHive,
+	},
+	BuildingTypeStorageDepot: {
+		Name: "Storage Depot", WoodCost: 75, Size: image.Pt(1, 1), BuildTime: 400,
+		Placement: PlaceOnGround, New: NewStorageDepot,
+	},
+	BuildingTypeBarracks: {
+		Name: "Barracks", WoodCost: 150, SucroseCost: 50, Size: image.Pt(2, 2), BuildTime: 600,
+		Placement: PlaceOnGround, New: NewBarracks,
+	},
+	BuildingTypeDefensiveMound: {
+		Name: "Defensive Mound", WoodCost: 100, SucroseCost: 25, Size: image.Pt(1, 1), BuildTime: 500,
+		Placement: PlaceOnGround, New: NewDefensiveMound,
+	},
+	BuildingTypeNest: {
+		Name: "Nest", WoodCost: 60, Size: image.Pt(1, 1), BuildTime: 300,
+		Placement: PlaceOnGround, New: NewNest,
+	},
+}
+
+// BuildMenu is the order buildings are offered in.
+var BuildMenu = []BuildingType{
+	BuildingTypeBridge,
+	BuildingTypeHive,
+	BuildingTypeStorageDepot,
+	BuildingTypeBarracks,
+	BuildingTypeDefensiveMound,
+	BuildingTypeNest,
+}
+
+// DropOffBuildings are where harvesters can bring resources.
+var DropOffBuildings = []BuildingType{BuildingTypeHive, BuildingTypeRoachHive, BuildingTypeStorageDepot}
+
+// Footprint is the rect a building of this spec covers with its top left
+// corner at the given world position.
+func (spec *BuildingSpec) Footprint(topLeft image.Point) image.Rectangle {
+	return image.Rectangle{Min: topLeft, Max: topLeft.Add(spec.Size.Mul(TileDimensions))}
+}
+
+// MissingResource returns the first resource the faction doesn't have enough
+// of to build bt, or "" if it can afford it.
+func (s *T) MissingResource(faction uint, bt BuildingType) string {
+	spec, ok := BuildingCatalog[bt]
+	if !ok {
+		return ""
+	}
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	state := s.factionState(faction)
+	switch {
+	case state.Wood < spec.WoodCost:
+		return "Wood"
+	case state.Sucrose < spec.SucroseCost:
+		return "Sucrose"
+	}
+	return ""
+}
+
+// CanPlaceBuilding checks a building of type bt could go at target, going by
+// its spec's footprint and placement rule. Costs and builders aren't checked.
+func (s *T) CanPlaceBuilding(bt BuildingType, target image.Rectangle) error {
+	spec, ok := BuildingCatalog[bt]
+	if !ok {
+		return fmt.Errorf("building type %d can't be built", bt)
+	}
+	if target != spec.Footprint(target.Min) {
+		return fmt.Errorf("%v needs %vx%v tiles", spec.Name, spec.Size.X, spec.Size.Y)
+	}
+	if target.Min.X%TileDimensions != 0 || target.Min.Y%TileDimensions != 0 {
+		return errors.New("not lined up with the tiles")
+	}
+	for _, building := range s.playerBuildings {
+		if building.GetRect().Overlaps(target) {
+			return errors.New("there's already a building there")
+		}
+	}
+
+	switch spec.Placement {
+	case PlaceOnBuildable:
+		if !slices.ContainsFunc(s.world.TileMap.MapObjects, func(mo *tilemap.MapObject) bool {
+			return mo.IsBuildable && *mo.Rect == target
+		}) {
+			return fmt.Errorf("%v has to go on a buildable spot", spec.Name)
+		}
+	case PlaceOnGround:
+		tm := s.world.TileMap
+		if !target.In(image.Rect(0, 0, tm.Width*tm.TileSize, tm.Height*tm.TileSize)) {
+			return errors.New("off the map")
+		}
+		for x := target.Min.X / tm.TileSize; x < target.Max.X/tm.TileSize; x++ {
+			for y := target.Min.Y / tm.TileSize; y < target.Max.Y/tm.TileSize; y++ {
+				tile := tm.Tiles[x][y]
+				if !tile.Walkable || tile.Resource != "" {
+					return errors.New("the ground isn't clear")
+				}
+			}
+		}
+		if s.overlapsMapObject(&target) {
+			return errors.New("the ground isn't clear")
+		}
+		blocked := false
+		s.unitIndex.Query(target, func(_ *Unit, rect *image.Rectangle) {
+			blocked = blocked || rect.Overlaps(target)
+		})
+		if blocked {
+			return errors.New("units are in the way")
+		}
+	}
+	return nil
+}
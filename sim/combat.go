@@ -0,0 +1,217 @@
+package sim
+
+import (
+	"gamejam/eventing"
+	"image"
+	"math"
+	"slices"
+)
+
+// IsHostileTo reports whether two units should fight each other.
+// Neutral units never fight, everything else fights other factions.
+func (unit *Unit) IsHostileTo(other *Unit) bool {
+	if other == nil || unit.Faction == other.Faction {
+		return false
+	}
+	return unit.Faction != uint(NeutralFaction) && other.Faction != uint(NeutralFaction)
+}
+
+// IsHostileToBuilding reports whether the unit should knock the building
+// down. Buildings that can't be hurt are left alone.
+func (unit *Unit) IsHostileToBuilding(b BuildingInterface) bool {
+	if b == nil || b.IsDestroyed() || unit.Faction == b.GetFaction() {
+		return false
+	}
+	if _, max := b.GetHP(); max == 0 {
+		return false
+	}
+	return unit.Faction != uint(NeutralFaction) && b.GetFaction() != uint(NeutralFaction)
+}
+
+func (unit *Unit) IsDead() bool {
+	return unit.Stats.HPCur == 0
+}
+
+// EdgeDistanceToRect returns the gap between the unit's rect and another rect,
+// 0 if they touch or overlap.
+func (unit *Unit) EdgeDistanceToRect(other *image.Rectangle) uint {
+	dx := max(other.Min.X-unit.Rect.Max.X, unit.Rect.Min.X-other.Max.X, 0)
+	dy := max(other.Min.Y-unit.Rect.Max.Y, unit.Rect.Min.Y-other.Max.Y, 0)
+	return uint(math.Sqrt(float64(dx*dx + dy*dy)))
+}
+
+func (unit *Unit) InAttackRange(target *Unit) bool {
+	if target == nil || target.IsDead() || unit.Stats.Damage == 0 {
+		return false
+	}
+	return unit.EdgeDistanceToRect(target.Rect) <= unit.Stats.Range
+}
+
+// Attack hits the target if the unit is off cooldown.
+func (unit *Unit) Attack(sim *T, target *Unit) {
+	if unit.AttackCooldown > 0 || target.IsDead() {
+		return
+	}
+	// face the target while attacking
+	from := unit.GetCenteredPosition()
+	to := target.GetCenteredPosition()
+	unit.MovingAngle = math.Atan2(float64(to.Y-from.Y), float64(to.X-from.X)) + math.Pi/2
+
+	target.TakeDamage(unit.Stats.Damage, unit)
+	unit.AttackCooldown = unit.Stats.AttackSpeed
+}
+
+func (unit *Unit) InBuildingAttackRange(b BuildingInterface) bool {
+	if b == nil || b.IsDestroyed() || unit.Stats.Damage == 0 {
+		return false
+	}
+	return unit.EdgeDistanceToRect(b.GetRect()) <= unit.Stats.Range
+}
+
+// AttackBuilding hits the building if the unit is off cooldown.
+func (unit *Unit) AttackBuilding(b BuildingInterface) {
+	if unit.AttackCooldown > 0 || b.IsDestroyed() {
+		return
+	}
+	from := unit.GetCenteredPosition()
+	to := b.GetCenteredPosition()
+	unit.MovingAngle = math.Atan2(float64(to.Y-from.Y), float64(to.X-from.X)) + math.Pi/2
+
+	b.TakeDamage(unit.Stats.Damage)
+	unit.AttackCooldown = unit.Stats.AttackSpeed
+}
+
+// TakeDamage lowers HP without underflowing and marks the unit dead at zero.
+func (unit *Unit) TakeDamage(amount uint, attacker *Unit) {
+	if unit.IsDead() {
+		return
+	}
+	if amount >= unit.Stats.HPCur {
+		unit.Stats.HPCur = 0
+		unit.Action = DeadAction
+		if attacker != nil {
+			unit.killerID = attacker.ID.String()
+		}
+		return
+	}
+	unit.Stats.HPCur -= amount
+	// retaliate if we were just standing around
+	if attacker != nil && unit.NearestEnemy == nil && unit.IsHostileTo(attacker) {
+		unit.NearestEnemy = attacker
+	}
+}
+
+// updateTargets ticks attack cooldowns and points every unit at the nearest
+// hostile unit within its sight range, or the nearest hostile building when
+// there's no unit to fight.
+func (s *T) updateTargets() {
+	for _, unit := range s.enemyUnits {
+		s.updateTarget(unit)
+	}
+	for _, unit := range s.playerUnits {
+		s.updateTarget(unit)
+	}
+}
+
+func (s *T) updateTarget(unit *Unit) {
+	if unit.AttackCooldown > 0 {
+		unit.AttackCooldown--
+	}
+	unit.nearestBuilding = nil
+	if unit.IsDead() || unit.Stats.Damage == 0 {
+		unit.SetNearestEnemy(nil)
+		return
+	}
+	var nearest *Unit
+	minDist := uint(math.MaxUint32)
+	sight := unit.Rect.Inset(-int(unit.Stats.SightRange))
+	s.unitIndex.Query(sight, func(other *Unit, _ *image.Rectangle) {
+		if other.IsDead() || !unit.IsHostileTo(other) {
+			return
+		}
+		dist := unit.EdgeDistanceToRect(other.Rect)
+		if dist <= unit.Stats.SightRange && dist < minDist {
+			nearest = other
+			minDist = dist
+		}
+	})
+	unit.SetNearestEnemy(nearest)
+	if nearest != nil {
+		return
+	}
+	s.buildingIndex.Query(sight, func(building BuildingInterface, rect *image.Rectangle) {
+		if !unit.IsHostileToBuilding(building) {
+			return
+		}
+		dist := unit.EdgeDistanceToRect(rect)
+		if dist <= unit.Stats.SightRange && dist < minDist {
+			unit.nearestBuilding = building
+			minDist = dist
+		}
+	})
+}
+
+// removeDeadUnits takes dead units out of the sim and lets everyone else know.
+func (s *T) removeDeadUnits() {
+	var dead []*Unit
+	for _, unit := range s.GetAllUnits() {
+		if unit.IsDead() {
+			dead = append(dead, unit)
+		}
+	}
+	if len(dead) == 0 {
+		return
+	}
+	// one pass over everyone, however many died this tick
+	s.playerUnits = slices.DeleteFunc(s.playerUnits, (*Unit).IsDead)
+	s.enemyUnits = slices.DeleteFunc(s.enemyUnits, (*Unit).IsDead)
+	for _, unit := range s.GetAllUnits() {
+		if unit.NearestEnemy != nil && unit.NearestEnemy.IsDead() {
+			unit.SetNearestEnemy(nil)
+		}
+	}
+	for _, unit := range dead {
+		s.forgetUnit(unit)
+		pos := *unit.GetCenteredPosition()
+		eventing.PublishDeferred(s.EventBus, eventing.UnitDiedEvent{
+			UnitID:   unit.ID.String(),
+			KillerID: unit.killerID,
+			Faction:  unit.Faction,
+			Position: &pos,
+		})
+	}
+}
+
+// removeDestroyedBuildings takes knocked down buildings out of the sim.
+// Harvesters heading to one find another place to drop off and builders of
+// a destroyed site go idle.
+func (s *T) removeDestroyedBuildings() {
+	for _, building := range slices.Clone(s.playerBuildings) {
+		if !building.IsDestroyed() {
+			continue
+		}
+		s.RemoveBuilding(building)
+		if site, ok := building.(*InConstructionBuilding); ok {
+			site.done = true
+		}
+		for _, unit := range s.GetAllUnits() {
+			if unit.NearestHome == building {
+				unit.NearestHome = nil
+				if unit.Action == DeliveringAction {
+					unit.Destination = unit.LastResourcePos
+					unit.Action = CollectingAction
+				}
+			}
+			if unit.nearestBuilding == building {
+				unit.nearestBuilding = nil
+			}
+		}
+		pos := *building.GetCenteredPosition()
+		eventing.PublishDeferred(s.EventBus, eventing.BuildingDestroyedEvent{
+			BuildingID: building.GetID().String(),
+			Type:       int(building.GetType()),
+			Faction:    building.GetFaction(),
+			Position:   &pos,
+		})
+	}
+}
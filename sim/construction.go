@@ -0,0 +1,106 @@
+package sim
+
+import (
+	"image"
+)
+
+// BuilderReach is how close to a building site's edge a builder has to be
+// to work on it.
+var BuilderReach = uint(100)
+
+// MaxBuildersPerSite is how many builders can speed up one site, more than
+// that just stand around.
+var MaxBuildersPerSite = 4
+
+// ConstructionRefund is the part of a building's cost given back when its
+// site is cancelled.
+var ConstructionRefund = 0.75
+
+// startBuilding sends the unit to work on site until it's done.
+func (unit *Unit) startBuilding(site *InConstructionBuilding) {
+	unit.site = site
+	unit.Destination = site.GetClosestPosition(unit.Position.X, unit.Position.Y)
+	unit.DestinationType = LocationDestination
+	unit.Action = BuildingAction
+}
+
+// build walks the unit up to its site, the site counts who's working on it.
+func (unit *Unit) build(sim *T) {
+	if unit.site == nil || unit.site.done {
+		unit.site = nil
+		unit.Action = IdleAction
+		return
+	}
+	if unit.EdgeDistanceToRect(unit.site.Rect) <= BuilderReach {
+		return
+	}
+	unit.Destination = unit.site.GetClosestPosition(unit.Position.X, unit.Position.Y)
+	unit.MoveToDestination(sim, false)
+	if unit.Action == IdleAction { // stuck for good
+		unit.site = nil
+	}
+}
+
+// builders counts the units working on the site right now, up to
+// MaxBuildersPerSite.
+func (icb *InConstructionBuilding) builders(sim *T) int {
+	area := icb.Rect.Inset(-int(BuilderReach))
+	count := 0
+	sim.unitIndex.Query(area, func(unit *Unit, _ *image.Rectangle) {
+		if unit.site == icb && unit.Action == BuildingAction && !unit.IsDead() &&
+			unit.EdgeDistanceToRect(icb.Rect) <= BuilderReach {
+			count++
+		}
+	})
+	return min(count, MaxBuildersPerSite)
+}
+
+// siteAt returns the building site covering point, if there is one.
+func (s *T) siteAt(point image.Point) *InConstructionBuilding {
+	for _, building := range s.playerBuildings {
+		if site, ok := building.(*InConstructionBuilding); ok && point.In(*site.Rect) {
+			return site
+		}
+	}
+	return nil
+}
+
+// IsBuildingSite reports whether the building is still under construction.
+func (s *T) IsBuildingSite(buildingID string) bool {
+	building, err := s.GetBuildingByID(buildingID)
+	return err == nil && building.GetType() == BuildingTypeInConstruction
+}
+
+// joinSite puts the unit to work on its faction's site at point, or attack
+// moves there when there's none.
+func (unit *Unit) joinSite(sim *T, point image.Point) {
+	site := sim.siteAt(point)
+	if site == nil || site.Faction != unit.Faction {
+		unit.startOrder(sim, Order{Type: AttackMoveOrder, Point: point})
+		return
+	}
+	unit.startBuilding(site)
+}
+
+// CancelConstruction takes down a building site and gives its faction back
+// ConstructionRefund of what it cost. Its builders go idle.
+func (s *T) CancelConstruction(siteID string) bool {
+	building, err := s.GetBuildingByID(siteID)
+	if err != nil {
+		return false
+	}
+	site, ok := building.(*InConstructionBuilding)
+	if !ok {
+		return false // already done
+	}
+	s.record(Command{Type: CommandCancelConstruction, ID: siteID})
+	spec := BuildingCatalog[site.targetBuilding]
+	s.stateMu.Lock()
+	state := s.factionState(site.Faction)
+	state.Wood += uint16(float64(spec.WoodCost) * ConstructionRefund)
+	state.Sucrose += uint16(float64(spec.SucroseCost) * ConstructionRefund)
+	s.stateMu.Unlock()
+	site.done = true
+	s.RemoveBuilding(site)
+	return true
+}
@@ -0,0 +1,39 @@
+package sim
+
+// Controller drives the units and buildings of a single non-player faction.
+// Controllers are ticked from T.Update before any units move.
+type Controller interface {
+	GetFaction() uint
+	Update(sim *T)
+}
+
+func (s *T) AddController(c Controller) {
+	s.controllers = append(s.controllers, c)
+}
+
+// ControllerSnapshot is what a controller was up to, so a restored one
+// carries on instead of starting over. Only RoachAI has state worth saving.
+type ControllerSnapshot struct {
+	Faction uint
+	RoachAI *RoachAISnapshot `json:",omitempty"`
+}
+
+func (s *T) GetUnitsByFaction(faction uint) []*Unit {
+	var units []*Unit
+	for _, unit := range s.GetAllUnits() {
+		if unit.Faction == faction {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+func (s *T) GetBuildingsByFaction(faction uint) []BuildingInterface {
+	var buildings []BuildingInterface
+	for _, building := range s.playerBuildings {
+		if building.GetFaction() == faction {
+			buildings = append(buildings, building)
+		}
+	}
+	return buildings
+}
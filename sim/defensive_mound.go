@@ -0,0 +1,47 @@
+package sim
+
+import (
+	"image"
+	"math"
+)
+
+// Defensive mounds shoot at the closest hostile unit in range.
+var MoundDamage = uint(15)
+var MoundRange = uint(384) // edge to edge
+var MoundAttackSpeed = uint(45)
+
+type DefensiveMound struct {
+	*Building
+	AttackCooldown uint
+}
+
+func NewDefensiveMound() BuildingInterface {
+	building := NewBuilding(0, 0, TileDimensions, TileDimensions, 0, BuildingTypeDefensiveMound, 0)
+	return &DefensiveMound{Building: building}
+}
+
+func (m *DefensiveMound) Update(sim *T) {
+	if m.AttackCooldown > 0 {
+		m.AttackCooldown--
+		return
+	}
+	if m.Faction == uint(NeutralFaction) {
+		return
+	}
+	var target *Unit
+	minDist := uint(math.MaxUint32)
+	sim.unitIndex.Query(m.Rect.Inset(-int(MoundRange)), func(unit *Unit, _ *image.Rectangle) {
+		if unit.IsDead() || unit.Faction == m.Faction || unit.Faction == uint(NeutralFaction) {
+			return
+		}
+		if dist := unit.EdgeDistanceToRect(m.Rect); dist <= MoundRange && dist < minDist {
+			target = unit
+			minDist = dist
+		}
+	})
+	if target == nil {
+		return
+	}
+	target.TakeDamage(MoundDamage, nil)
+	m.AttackCooldown = MoundAttackSpeed
+}
@@ -0,0 +1,220 @@
+package sim
+
+import (
+	"image"
+	"math"
+	"slices"
+)
+
+// FormationSpacing is the gap between units standing in formation.
+var FormationSpacing = 16
+
+// AvoidanceRadius is how far past its own edge a moving unit looks for others
+// to steer around.
+var AvoidanceRadius = 96
+
+// AvoidanceWeight is how hard a unit turns away from units ahead of it,
+// compared to heading straight for its waypoint.
+var AvoidanceWeight = 0.8
+
+// IssueGroupAction gives one order to several units at once. Orders with a
+// point spread the units into a formation around it instead of sending them
+// all to the same spot, and the group moves at the pace of its slowest unit.
+// IDs that aren't units, e.g. a selected hive, are skipped.
+func (s *T) IssueGroupAction(ids []string, orderType OrderType, point *image.Point) error {
+	return s.groupAction(ids, orderType, point, s.IssueOrder)
+}
+
+// QueueGroupAction is IssueGroupAction for the end of each unit's queue.
+func (s *T) QueueGroupAction(ids []string, orderType OrderType, point *image.Point) error {
+	return s.groupAction(ids, orderType, point, s.QueueOrder)
+}
+
+func (s *T) groupAction(ids []string, orderType OrderType, point *image.Point, give func(string, Order) error) error {
+	var units []*Unit
+	for _, id := range ids {
+		if unit, err := s.GetUnitByID(id); err == nil {
+			units = append(units, unit)
+		}
+	}
+	if len(units) == 0 {
+		return nil
+	}
+
+	var order Order
+	if point != nil {
+		// smart orders mean whatever the clicked spot means, not each slot
+		order = s.resolve(Order{Type: orderType, Point: *point})
+	} else {
+		order = Order{Type: orderType}
+	}
+	spread := point != nil && len(units) > 1 && (order.Type == MoveOrder || order.Type == AttackMoveOrder)
+	if !spread {
+		for _, unit := range units {
+			unitOrder := order
+			if point == nil {
+				unitOrder.Point = *unit.Position
+			}
+			if err := give(unit.ID.String(), unitOrder); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	pace := units[0].Stats.MoveSpeed
+	for _, unit := range units {
+		pace = min(pace, unit.Stats.MoveSpeed)
+	}
+	slots := s.assignFormation(units, *point)
+	for i, unit := range units {
+		unitOrder := order
+		unitOrder.Point = slots[i]
+		unitOrder.Pace = pace
+		if err := give(unit.ID.String(), unitOrder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignFormation lays out a grid of slots around point, sized to fit the
+// biggest unit, skipping slots on blocked tiles, and hands them out so units
+// don't cross each other's paths. Slots are returned as top-left positions in
+// the units' order.
+func (s *T) assignFormation(units []*Unit, point image.Point) []image.Point {
+	cell := 0
+	for _, unit := range units {
+		cell = max(cell, unit.Rect.Dx(), unit.Rect.Dy())
+	}
+	cell += FormationSpacing
+
+	// walk outwards ring by ring until there are enough slot centers
+	tileSize := s.world.TileMap.TileSize
+	var centers []image.Point
+	for ring := 0; len(centers) < len(units) && ring <= len(units); ring++ {
+		for dy := -ring; dy <= ring; dy++ {
+			for dx := -ring; dx <= ring; dx++ {
+				if max(abs(dx), abs(dy)) != ring {
+					continue
+				}
+				center := point.Add(image.Pt(dx*cell, dy*cell))
+				if tx, ty := WorldToTile(center, tileSize); !s.IsWalkable(tx, ty) && ring > 0 {
+					continue
+				}
+				centers = append(centers, center)
+			}
+		}
+	}
+	for len(centers) < len(units) { // boxed in, double up on the clicked spot
+		centers = append(centers, point)
+	}
+
+	// fill the slots furthest from the group first, with the units closest to
+	// them, so the front of the group takes the far side of the formation and
+	// nobody has to walk through units that already arrived
+	var from image.Point
+	for _, unit := range units {
+		from = from.Add(*unit.GetCenteredPosition())
+	}
+	from = from.Div(len(units))
+	byDistance := func(a, b image.Point) int {
+		return int(distance(b, from)) - int(distance(a, from))
+	}
+	centers = centers[:len(units)]
+	slices.SortStableFunc(centers, byDistance)
+
+	slots := make([]image.Point, len(units))
+	assigned := make([]bool, len(units))
+	for _, center := range centers {
+		best := -1
+		for i, unit := range units {
+			if assigned[i] {
+				continue
+			}
+			if best == -1 || unit.DistanceTo(center) < units[best].DistanceTo(center) {
+				best = i
+			}
+		}
+		assigned[best] = true
+		slots[best] = center.Sub(image.Pt(units[best].Rect.Dx()/2, units[best].Rect.Dy()/2))
+	}
+	return slots
+}
+
+// moveSpeed is how far the unit moves per tick, held back to its group's
+// pace while moving in formation.
+func (unit *Unit) moveSpeed() float64 {
+	if unit.pace > 0 && unit.pace < unit.Stats.MoveSpeed {
+		return float64(unit.pace)
+	}
+	return float64(unit.Stats.MoveSpeed)
+}
+
+// avoidance returns a sideways nudge away from units we'd walk into within
+// reach when heading in direction dirX, dirY, so units flow around each other
+// instead of jamming. Units pass each other on the right, so two heading at
+// each other don't dance back and forth. Stuck units turn harder.
+func (unit *Unit) avoidance(sim *T, dirX, dirY, reach float64) (float64, float64) {
+	center := *unit.GetCenteredPosition()
+	var pushX, pushY float64
+	sim.unitIndex.Query(unit.Rect.Inset(-AvoidanceRadius), func(other *Unit, _ *image.Rectangle) {
+		if other == unit || other.IsDead() {
+			return
+		}
+		oc := *other.GetCenteredPosition()
+		toX, toY := float64(oc.X-center.X), float64(oc.Y-center.Y)
+		dist := math.Hypot(toX, toY)
+		gap := float64(unit.EdgeDistanceToRect(other.Rect))
+		if dist == 0 || gap > float64(AvoidanceRadius) {
+			return
+		}
+		along := toX*dirX + toY*dirY
+		if along <= 0 || along > reach {
+			return // behind us, or past where we're going
+		}
+		lateral := dirX*toY - dirY*toX // > 0 when it's to our right
+		if math.Abs(lateral) >= float64(unit.Rect.Dx()+other.Rect.Dx())/2 {
+			return // we'd pass it going straight
+		}
+		// keep right, unless the other unit is well off to our right already.
+		// Deciding by small offsets makes units flip sides every tick
+		side := 1.0
+		if lateral/dist > 0.5 {
+			side = -1.0
+		}
+		weight := along / dist * (1 - gap/float64(AvoidanceRadius+1))
+		pushX += side * -dirY * weight
+		pushY += side * dirX * weight
+	})
+	stuck := min(unit.StuckFrames, 2*ReplanStuckFrames)
+	weight := AvoidanceWeight * (1 + float64(stuck)/float64(ReplanStuckFrames))
+	return pushX * weight, pushY * weight
+}
+
+// steer returns this tick's move towards target. With nobody in the way it
+// moves up to the unit's speed along each axis, like it always has.
+func (unit *Unit) steer(sim *T, target image.Point) (int, int) {
+	speed := unit.moveSpeed()
+	dx := float64(target.X - unit.Position.X)
+	dy := float64(target.Y - unit.Position.Y)
+	dist := math.Hypot(dx, dy)
+	straightX := math.Copysign(math.Min(math.Abs(dx), speed), dx)
+	straightY := math.Copysign(math.Min(math.Abs(dy), speed), dy)
+	if dist <= speed {
+		return int(straightX), int(straightY)
+	}
+
+	dirX, dirY := dx/dist, dy/dist
+	pushX, pushY := unit.avoidance(sim, dirX, dirY, dist+float64(unit.Rect.Dx()))
+	if pushX == 0 && pushY == 0 {
+		return int(straightX), int(straightY)
+	}
+	steerX, steerY := dirX+pushX, dirY+pushY
+	length := math.Hypot(steerX, steerY)
+	return int(math.Round(steerX / length * speed)), int(math.Round(steerY / length * speed))
+}
+
+func distance(a, b image.Point) float64 {
+	return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+}
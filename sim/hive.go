@@ -1,56 +1,72 @@
 package sim
 
 import (
-	"gamejam/util"
+	"gamejam/util/queue"
 	"image"
 	"math"
 	"sort"
 )
 
-var UnitConstructionTime = 120
-
+// Hive is any building that makes units, see Produces.
 type Hive struct {
 	*Building
-	buildQueue      *util.Queue[*Unit]
+	buildQueue      *queue.Queue[ProductionOrder]
 	UnitContructing bool
+	rally           *RallyPoint
 }
 
 func NewHive() BuildingInterface {
-	building := NewBuilding(0, 0, TileDimensions*2, TileDimensions*2, 0, BuildingTypeHive, uint(UnitConstructionTime))
+	building := NewBuilding(0, 0, TileDimensions*2, TileDimensions*2, 0, BuildingTypeHive, 0)
 	h := &Hive{
 		Building:        building,
 		UnitContructing: false,
-		buildQueue:      util.NewQueue[*Unit](),
+		buildQueue:      queue.New[ProductionOrder](),
 	}
 	return h
 }
 
 func NewRoachHive() BuildingInterface {
-	building := NewBuilding(0, 0, TileDimensions*2, TileDimensions*2, 0, BuildingTypeRoachHive, uint(UnitConstructionTime))
+	building := NewBuilding(0, 0, TileDimensions*2, TileDimensions*2, 0, BuildingTypeRoachHive, 0)
+	h := &Hive{
+		Building:        building,
+		UnitContructing: false,
+		buildQueue:      queue.New[ProductionOrder](),
+	}
+	return h
+}
+
+// NewBarracks trains soldier ants instead of workers.
+func NewBarracks() BuildingInterface {
+	building := NewBuilding(0, 0, TileDimensions*2, TileDimensions*2, 0, BuildingTypeBarracks, 0)
 	h := &Hive{
 		Building:        building,
 		UnitContructing: false,
-		buildQueue:      util.NewQueue[*Unit](),
+		buildQueue:      queue.New[ProductionOrder](),
 	}
 	return h
 }
 
 func (h *Hive) Update(sim *T) {
-	if !h.buildQueue.IsEmpty() {
-		h.UnitContructing = true
-		h.ProgressCurrent += 1
-		if h.ProgressCurrent >= uint(UnitConstructionTime) {
-			u, err := h.buildQueue.Dequeue()
-			if err != nil {
-				return // todo handle?
-			}
-			// make sure position isnt colliding with anything and try again
-			u.SetPosition(h.GetNearbyPosition(sim, 128)) // unit size static for now but could change later
-			sim.AddUnit(u)
-			h.UnitContructing = false
-			h.ProgressCurrent = 0
-		}
+	current, err := h.buildQueue.Peek()
+	if err != nil {
+		return
 	}
+	h.UnitContructing = true
+	h.ProgressMax = current.Spec.BuildTime
+	h.ProgressCurrent += 1
+	if h.ProgressCurrent < h.ProgressMax {
+		return
+	}
+	h.buildQueue.Dequeue()
+	u := NewUnitOfType(current.Type)
+	u.Faction = h.Faction
+	u.SetPosition(h.GetNearbyPosition(sim, 128)) // unit size static for now but could change later
+	sim.AddUnit(u)
+	if h.rally != nil {
+		sim.IssueOrder(u.ID.String(), h.rallyOrder(sim))
+	}
+	h.UnitContructing = false
+	h.ProgressCurrent = 0
 }
 
 func (h *Hive) DistanceTo(point image.Point) uint {
@@ -59,16 +75,8 @@ func (h *Hive) DistanceTo(point image.Point) uint {
 	return uint(math.Sqrt(math.Pow(xDist, 2) + math.Pow(yDist, 2)))
 }
 
-func (h *Hive) AddUnitToBuildQueue() {
-	var unit *Unit
-	switch h.Type {
-	case BuildingTypeHive:
-		unit = NewDefaultAnt()
-	case BuildingTypeRoachHive:
-		unit = NewDefaultRoach()
-	}
-	h.buildQueue.Enqueue(unit)
-}
+// GetNearbyPosition finds a clear spot next to the hive for a new unit,
+// away from crowds and on the side facing the rally point if there is one.
 func (h *Hive) GetNearbyPosition(sim *T, unitSize int) *image.Point {
 	const maxRadius = 3
 	const tileSize = 128
@@ -78,8 +86,14 @@ func (h *Hive) GetNearbyPosition(sim *T, unitSize int) *image.Point {
 		point   image.Point
 		rect    *image.Rectangle
 		density int
+		reach   uint // distance to the rally point
 	}
 
+	var rally *image.Point
+	if h.rally != nil {
+		target := h.rallyTarget(sim)
+		rally = &target
+	}
 	var candidates []spawnCandidate
 
 	for dx := -maxRadius; dx <= maxRadius; dx++ {
@@ -97,34 +111,35 @@ func (h *Hive) GetNearbyPosition(sim *T, unitSize int) *image.Point {
 			}
 
 			// Score this tile by number of units overlapping or nearby
-			density := 0
-			for _, unit := range append(sim.playerUnits, sim.enemyUnits...) {
-				if unit == nil || unit.ID.String() == h.ID.String() {
-					continue
-				}
-				// Check proximity (not just overlap)
-				unitCenter := unit.GetCenteredPosition()
-				dist := math.Hypot(float64(unitCenter.X-x), float64(unitCenter.Y-y))
-				if dist < float64(tileSize*2) { // count units within 2-tile radius
-					density++
-				}
-			}
+			density := len(sim.GetUnitsNear(image.Pt(x, y), tileSize*2)) // count units within 2-tile radius
 
-			candidates = append(candidates, spawnCandidate{
+			candidate := spawnCandidate{
 				point:   image.Point{X: x - unitSize/2, Y: y - unitSize/2},
 				rect:    rect,
 				density: density,
-			})
+			}
+			if rally != nil {
+				candidate.reach = uint(math.Hypot(float64(x-rally.X), float64(y-rally.Y)))
+			}
+			candidates = append(candidates, candidate)
 		}
 	}
 
-	// Sort by least density first
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].density < candidates[j].density
+	// Sort by least density first, then closest to the rally point
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].density != candidates[j].density {
+			return candidates[i].density < candidates[j].density
+		}
+		return candidates[i].reach < candidates[j].reach
 	})
 
 	// Try the best candidates in order
+	tm := sim.world.TileMap
+	bounds := image.Rect(0, 0, tm.Width*tm.TileSize, tm.Height*tm.TileSize)
 	for _, c := range candidates {
+		if !c.rect.In(bounds) || sim.overlapsMapObject(c.rect) || sim.overlapsImpassableTile(c.rect) {
+			continue // off the map, walls and water
+		}
 		colliders := sim.GetAllCollidersOverlapping(c.rect)
 		collision := false
 		for _, collider := range colliders {
@@ -1,14 +1,17 @@
 package sim
 
-var BridgeBuildTime = 160
-
 type InConstructionBuilding struct {
 	*Building
 	targetBuilding BuildingType
+	done           bool // finished or cancelled, builders leave
 }
 
+// NewInConstructionBuilding is a building site for targetBuilding, sized and
+// timed by its spec in BuildingCatalog.
 func NewInConstructionBuilding(x, y int, targetBuilding BuildingType) *InConstructionBuilding {
-	building := NewBuilding(x, y, TileDimensions, TileDimensions, 1, BuildingTypeInConstruction, uint(BridgeBuildTime))
+	spec := BuildingCatalog[targetBuilding]
+	size := spec.Size.Mul(TileDimensions)
+	building := NewBuilding(x, y, size.X, size.Y, uint(NeutralFaction), BuildingTypeInConstruction, spec.BuildTime)
 
 	icb := &InConstructionBuilding{
 		Building:       building,
@@ -18,23 +21,30 @@ func NewInConstructionBuilding(x, y int, targetBuilding BuildingType) *InConstru
 	return icb
 }
 
+// GetTargetBuilding returns what the site turns into once it's done.
+func (icb *InConstructionBuilding) GetTargetBuilding() BuildingType {
+	return icb.targetBuilding
+}
+
+// Update only makes progress while builders are next to the site, one step
+// per builder.
 func (icb *InConstructionBuilding) Update(sim *T) {
-	// check if there are ants around?
-	icb.ProgressCurrent += 1
+	icb.ProgressCurrent += uint(icb.builders(sim))
 	if icb.ProgressCurrent <= icb.ProgressMax {
 		return
 	}
 	// else create the new building
 	icb.ProgressCurrent = 0
+	icb.done = true
 	sim.RemoveBuilding(icb)
-	sim.world.TileMap.RemoveCollisionRect(icb.Rect)
-	switch icb.targetBuilding {
-	case BuildingTypeInConstruction: // shouldnt happen
-	case BuildingTypeHive:
-	case BuildingTypeBridge:
-		bb := NewBridgeBuilding(icb.Position.X, icb.Position.Y)
-		sim.AddBuilding(bb)
-
+	spec := BuildingCatalog[icb.targetBuilding]
+	if spec.Placement == PlaceOnBuildable {
+		sim.removeCollisionRect(*icb.Rect)
 	}
-
+	building := spec.New()
+	building.SetPosition(icb.Position.X, icb.Position.Y, icb.Rect.Dx(), icb.Rect.Dy())
+	if !spec.Neutral {
+		building.SetFaction(icb.Faction)
+	}
+	sim.AddBuilding(building)
 }
@@ -0,0 +1,11 @@
+package sim
+
+// Nest is a small building that only adds supply, see SupplyProvided.
+type Nest struct {
+	*Building
+}
+
+func NewNest() BuildingInterface {
+	building := NewBuilding(0, 0, TileDimensions, TileDimensions, 0, BuildingTypeNest, 0)
+	return &Nest{Building: building}
+}
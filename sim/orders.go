@@ -0,0 +1,179 @@
+package sim
+
+import (
+	"gamejam/eventing"
+	"image"
+	"slices"
+)
+
+// OrderType is what a unit does once it gets to an order in its queue.
+type OrderType int
+
+const (
+	MoveOrder OrderType = iota
+	AttackMoveOrder
+	GatherOrder
+	BuildOrder
+	HoldOrder
+	StopOrder
+	// SmartOrder is whatever a right click on the point means, see OrderAt.
+	// It's turned into one of the others when given.
+	SmartOrder
+)
+
+// IdleChaseRange is how close an enemy has to get before an idle unit goes
+// after it. Holding units never move.
+var IdleChaseRange = uint(200)
+
+// Order is one step of a unit's command queue, see Unit.Orders.
+type Order struct {
+	Type     OrderType
+	Point    image.Point
+	Target   *image.Rectangle `json:",omitempty"` // build site for BuildOrder, nil helps with the site at Point
+	Building BuildingType     `json:",omitempty"` // what BuildOrder builds
+	Pace     uint             `json:",omitempty"` // shared speed of a group moving in formation
+}
+
+// OrderAt is the order a right click on point gives: attack move onto
+// enemies and open ground, gather from resources and help build sites.
+func (s *T) OrderAt(point image.Point) Order {
+	if s.siteAt(point) != nil {
+		return Order{Type: BuildOrder, Point: point}
+	}
+	switch s.DetermineDestinationType(&point) {
+	case ResourceDestination:
+		return Order{Type: GatherOrder, Point: point}
+	default:
+		return Order{Type: AttackMoveOrder, Point: point}
+	}
+}
+
+// BuildOrderFor starts construction of bt at target and sends the builder
+// there to work on it.
+func BuildOrderFor(bt BuildingType, target image.Rectangle) Order {
+	center := target.Min.Add(target.Max).Div(2)
+	return Order{Type: BuildOrder, Point: center, Target: &target, Building: bt}
+}
+
+// resolve turns a SmartOrder into a concrete one.
+func (s *T) resolve(order Order) Order {
+	switch {
+	case order.Type == SmartOrder:
+		return s.OrderAt(order.Point)
+	case order.Type == BuildOrder && order.Building == BuildingTypeInConstruction:
+		order.Building = BuildingTypeBridge // recorded when only bridges existed
+	}
+	return order
+}
+
+// orderFor builds the order for IssueAction and QueueAction. Stop and hold
+// don't need a point, they happen wherever the unit is.
+func orderFor(unit *Unit, orderType OrderType, point *image.Point) Order {
+	if point == nil {
+		point = unit.Position
+	}
+	return Order{Type: orderType, Point: *point}
+}
+
+// QueueOrder adds an order to the end of the unit's queue. An idle unit
+// starts on it next tick, a busy one once it's done with what it's doing.
+// Gathering and holding never finish on their own, so orders queued behind
+// them wait until a harvester has nowhere to deliver or gets a new command.
+func (s *T) QueueOrder(id string, order Order) error {
+	unit, err := s.GetUnitByID(id)
+	if err != nil {
+		return err
+	}
+	recorded := order
+	if order.Target != nil {
+		target := *order.Target
+		recorded.Target = &target
+	}
+	s.record(Command{Type: CommandQueueOrder, ID: id, Order: &recorded})
+	unit.Orders = append(unit.Orders, s.resolve(recorded))
+	return nil
+}
+
+// QueueAction is IssueAction for the end of the unit's queue.
+func (s *T) QueueAction(id string, orderType OrderType, point *image.Point) error {
+	unit, err := s.GetUnitByID(id)
+	if err != nil {
+		return err
+	}
+	return s.QueueOrder(id, orderFor(unit, orderType, point))
+}
+
+// GetQueuedPoints returns where the unit is headed now, if anywhere, followed
+// by the points of its queued orders. Used to draw waypoints.
+func (unit *Unit) GetQueuedPoints() []image.Point {
+	var points []image.Point
+	switch unit.Action {
+	case MovingAction, AttackMovingAction, CollectingAction, DeliveringAction, BuildingAction:
+		points = append(points, *unit.Destination)
+	}
+	for _, order := range unit.Orders {
+		points = append(points, order.Point)
+	}
+	return points
+}
+
+// clearOrders drops the queue and leaves whatever site the unit was building.
+func (unit *Unit) clearOrders() {
+	unit.Orders = nil
+	unit.site = nil
+}
+
+// nextOrder starts the next queued order. Only called while the unit is idle.
+func (unit *Unit) nextOrder(sim *T) {
+	if len(unit.Orders) == 0 {
+		return
+	}
+	order := unit.Orders[0]
+	unit.Orders = slices.Delete(unit.Orders, 0, 1)
+	unit.startOrder(sim, order)
+}
+
+func (unit *Unit) startOrder(sim *T, order Order) {
+	dest := order.Point
+	unit.Destination = &dest
+	unit.pace = order.Pace
+	switch order.Type {
+	case MoveOrder:
+		unit.DestinationType = LocationDestination
+		unit.Action = MovingAction
+	case AttackMoveOrder:
+		unit.DestinationType = sim.DetermineDestinationType(&dest)
+		unit.Action = AttackMovingAction
+	case GatherOrder:
+		dest = sim.gatherPoint(unit, dest)
+		unit.DestinationType = ResourceDestination
+		unit.Action = CollectingAction
+	case HoldOrder:
+		unit.Destination = clonePoint(unit.Position)
+		unit.DestinationType = LocationDestination
+		unit.Action = HoldingPositionAction
+	case StopOrder:
+		unit.Destination = clonePoint(unit.Position)
+		unit.DestinationType = LocationDestination
+		unit.Action = IdleAction
+	case BuildOrder:
+		if order.Target == nil {
+			unit.joinSite(sim, dest)
+		} else {
+			unit.placeBuilding(sim, *order.Target, order.Building)
+		}
+	}
+}
+
+// placeBuilding starts construction at site and puts the unit to work on it,
+// or lets the player know it couldn't.
+func (unit *Unit) placeBuilding(sim *T, site image.Rectangle, bt BuildingType) {
+	unit.Action = IdleAction
+	if sim.constructBuilding(bt, site, unit) || unit.Faction != uint(PlayerFaction) {
+		return
+	}
+	eventing.Publish(sim.EventBus, eventing.NotEnoughResourcesEvent{ // same as a failed build click
+		ResourceName:     sim.MissingResource(unit.Faction, bt),
+		TargetBeingBuilt: BuildingCatalog[bt].Name,
+	})
+}
@@ -1,15 +1,53 @@
 package sim
 
 import (
-	"gamejam/tilemap"
+	"container/heap"
 	"image"
-	"math"
+	"slices"
 )
 
+// path costs, diagonal is roughly straight * sqrt(2)
+const straightCost = 10
+const diagonalCost = 14
+
+var ReplanStuckFrames = 30
+
 type PathNode struct {
 	X, Y   int
 	G, H   int
 	Parent *PathNode
+	index  int // position in the open set heap
+}
+
+func (n *PathNode) F() int { return n.G + n.H }
+
+// pathHeap is a min-heap of nodes ordered by F, used as the A* open set.
+type pathHeap []*PathNode
+
+func (h pathHeap) Len() int { return len(h) }
+func (h pathHeap) Less(i, j int) bool {
+	if h[i].F() == h[j].F() {
+		return h[i].H < h[j].H // prefer nodes closer to the goal
+	}
+	return h[i].F() < h[j].F()
+}
+func (h pathHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *pathHeap) Push(x any) {
+	node := x.(*PathNode)
+	node.index = len(*h)
+	*h = append(*h, node)
+}
+func (h *pathHeap) Pop() any {
+	old := *h
+	n := len(old)
+	node := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return node
 }
 
 // Convert from world to tile coordinates
@@ -22,92 +60,274 @@ func TileToWorld(x, y, tileSize int) image.Point {
 	return image.Point{X: x * tileSize, Y: y * tileSize}
 }
 
-// Is tile at x, y walkable considering other units and static obstacles
-func IsWalkable(x, y int, tm *tilemap.Tilemap, sim *T, selfID string) bool {
-	return true
+// IsWalkable reports whether the tile at x, y is passable in the tileset and
+// free of map collision and buildings. Bridges don't block, and make the
+// water under them walkable, building one removes the collision rect there.
+func (s *T) IsWalkable(x, y int) bool {
+	tm := s.world.TileMap
 	if x < 0 || y < 0 || x >= tm.Width || y >= tm.Height {
 		return false
 	}
+	if s.blockedTiles == nil {
+		s.buildBlockedTiles()
+	}
+	return !s.blockedTiles[x][y]
+}
 
-	tileRect := tm.Tiles[x][y].Rect
+// buildBlockedTiles caches which tiles are impassable or covered by static
+// obstacles. It is rebuilt lazily after anything calls invalidatePathGrid.
+func (s *T) buildBlockedTiles() {
+	tm := s.world.TileMap
+	s.blockedTiles = make([][]bool, tm.Width)
+	for x := range s.blockedTiles {
+		s.blockedTiles[x] = make([]bool, tm.Height)
+	}
+	markRect := func(rect *image.Rectangle, blocked bool) {
+		minX, minY := WorldToTile(rect.Min, tm.TileSize)
+		maxX, maxY := WorldToTile(rect.Max.Sub(image.Pt(1, 1)), tm.TileSize)
+		for x := max(minX, 0); x <= min(maxX, tm.Width-1); x++ {
+			for y := max(minY, 0); y <= min(maxY, tm.Height-1); y++ {
+				s.blockedTiles[x][y] = blocked
+			}
+		}
+	}
+	for x, column := range tm.Tiles {
+		for y, tile := range column {
+			s.blockedTiles[x][y] = tile != nil && !tile.Walkable
+		}
+	}
+	for _, building := range s.playerBuildings {
+		if building.GetType() == BuildingTypeBridge {
+			markRect(building.GetRect(), false)
+		}
+	}
+	s.impassableTiles = make([][]bool, tm.Width)
+	for x := range s.blockedTiles {
+		s.impassableTiles[x] = slices.Clone(s.blockedTiles[x])
+	}
+	for _, mo := range tm.MapObjects {
+		markRect(mo.Rect, true)
+	}
+	for _, building := range s.playerBuildings {
+		if building.GetType() != BuildingTypeBridge {
+			markRect(building.GetRect(), true)
+		}
+	}
+}
 
-	for _, obj := range tm.MapObjects {
-		if obj.Rect.Overlaps(*tileRect) {
-			return false
+// overlapsImpassableTile reports whether rect touches a tile the tileset says
+// can't be walked on, so units can't be pushed onto water without collision
+// rects drawn over it.
+func (s *T) overlapsImpassableTile(rect *image.Rectangle) bool {
+	if s.blockedTiles == nil {
+		s.buildBlockedTiles()
+	}
+	tm := s.world.TileMap
+	minX, minY := WorldToTile(rect.Min, tm.TileSize)
+	maxX, maxY := WorldToTile(rect.Max.Sub(image.Pt(1, 1)), tm.TileSize)
+	for x := max(minX, 0); x <= min(maxX, tm.Width-1); x++ {
+		for y := max(minY, 0); y <= min(maxY, tm.Height-1); y++ {
+			if s.impassableTiles[x][y] {
+				return true
+			}
 		}
 	}
+	return false
+}
 
-	for _, u := range sim.GetAllUnits() {
-		if u.ID.String() == selfID {
+// invalidatePathGrid must be called whenever collision rects or buildings change.
+func (s *T) invalidatePathGrid() {
+	s.blockedTiles = nil
+	s.impassableTiles = nil
+	s.mapObjectIndex = nil
+}
+
+// occupiedTiles returns the tiles other units are standing on, used when
+// replanning around a jam.
+func (s *T) occupiedTiles(self *Unit) map[image.Point]bool {
+	tileSize := s.world.TileMap.TileSize
+	occupied := make(map[image.Point]bool)
+	for _, other := range s.GetAllUnits() {
+		if other == self {
 			continue
 		}
-		if u.Rect.Overlaps(*tileRect) {
-			return false
-		}
+		x, y := WorldToTile(*other.GetCenteredPosition(), tileSize)
+		occupied[image.Pt(x, y)] = true
 	}
+	return occupied
+}
 
-	return true
+// FindPath runs A* over the tile grid and returns the top-left world
+// position of every tile from start to end, or nil if end can't be reached.
+// The end tile is always allowed so units can path up to buildings and walls.
+func FindPath(start, end image.Point, sim *T) []image.Point {
+	return findPath(start, end, sim, nil)
 }
 
-// Main pathfinding function
-func FindPath(start, end image.Point, unit *Unit, sim *T) []image.Point {
-	tm := sim.world.TileMap
-	tileSize := tm.TileSize
+func findPath(start, end image.Point, sim *T, avoid map[image.Point]bool) []image.Point {
+	tileSize := sim.world.TileMap.TileSize
 	startX, startY := WorldToTile(start, tileSize)
 	endX, endY := WorldToTile(end, tileSize)
+	goal := image.Pt(endX, endY)
 
-	type key struct{ x, y int }
-	openSet := map[key]*PathNode{}
-	closedSet := map[key]bool{}
+	walkable := func(x, y int) bool {
+		if x == endX && y == endY {
+			return x >= 0 && y >= 0 && x < sim.world.TileMap.Width && y < sim.world.TileMap.Height
+		}
+		return sim.IsWalkable(x, y) && !avoid[image.Pt(x, y)]
+	}
+	heuristic := func(x, y int) int {
+		dx := abs(x - endX)
+		dy := abs(y - endY)
+		return straightCost*(dx+dy) + (diagonalCost-2*straightCost)*min(dx, dy) // octile distance
+	}
 
-	startNode := &PathNode{X: startX, Y: startY, G: 0, H: int(math.Abs(float64(startX-endX)) + math.Abs(float64(startY-endY)))}
-	openSet[key{startX, startY}] = startNode
+	if startX < 0 || startY < 0 || startX >= sim.world.TileMap.Width || startY >= sim.world.TileMap.Height {
+		return nil // off the map, nowhere to start from
+	}
+	tiles := sim.pathTiles()
+	open := &pathHeap{}
+	heap.Push(open, &PathNode{X: startX, Y: startY, H: heuristic(startX, startY)})
+	tiles.get(startX, startY).setG(tiles.search, 0)
 
-	var current *PathNode
+	dirs := [8][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
 
-	for len(openSet) > 0 {
-		// Get node with lowest F
-		for _, node := range openSet {
-			if current == nil || node.G+node.H < current.G+current.H {
-				current = node
-			}
+	var found *PathNode
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*PathNode)
+		tile := tiles.get(current.X, current.Y)
+		if tile.closed(tiles.search) {
+			continue // stale entry, a cheaper one was already expanded
 		}
-		if current.X == endX && current.Y == endY {
+		if image.Pt(current.X, current.Y) == goal {
+			found = current
 			break
 		}
-		delete(openSet, key{current.X, current.Y})
-		closedSet[key{current.X, current.Y}] = true
-
-		dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+		tile.close()
 
 		for _, d := range dirs {
 			nx, ny := current.X+d[0], current.Y+d[1]
-			k := key{nx, ny}
-
-			if closedSet[k] || !IsWalkable(nx, ny, tm, sim, unit.ID.String()) {
+			if !walkable(nx, ny) {
 				continue
 			}
-
-			gCost := current.G + 1
-			hCost := math.Abs(float64(nx-endX)) + math.Abs(float64(ny-endY))
-			neighbor := &PathNode{X: nx, Y: ny, G: gCost, H: int(hCost), Parent: current}
-
-			if existing, ok := openSet[k]; !ok || gCost < existing.G {
-				openSet[k] = neighbor
+			next := tiles.get(nx, ny)
+			if next.closed(tiles.search) {
+				continue
+			}
+			cost := straightCost
+			if d[0] != 0 && d[1] != 0 {
+				// no cutting corners past walls
+				if !walkable(current.X+d[0], current.Y) || !walkable(current.X, current.Y+d[1]) {
+					continue
+				}
+				cost = diagonalCost
+			}
+			if tile := sim.world.TileMap.Tiles[nx][ny]; tile != nil && tile.MoveCost > 1 {
+				cost = int(float64(cost) * tile.MoveCost) // rough ground, go around if it's cheaper
+			}
+			g := current.G + cost
+			if existing, ok := next.bestG(tiles.search); ok && existing <= g {
+				continue
 			}
+			next.setG(tiles.search, g)
+			heap.Push(open, &PathNode{X: nx, Y: ny, G: g, H: heuristic(nx, ny), Parent: current})
 		}
 	}
 
-	// Reconstruct path
-	if current == nil || current.X != endX || current.Y != endY {
+	if found == nil {
 		return nil
 	}
-
 	path := []image.Point{}
-	for current != nil {
-		worldPos := TileToWorld(current.X, current.Y, tileSize)
-		path = append([]image.Point{worldPos}, path...)
-		current = current.Parent
+	for node := found; node != nil; node = node.Parent {
+		path = append([]image.Point{TileToWorld(node.X, node.Y, tileSize)}, path...)
 	}
 	return path
 }
+
+// pathTile is findPath's bookkeeping for one tile. It only counts for the
+// search it was written in, so the grid never needs clearing.
+type pathTile struct {
+	search   uint32
+	g        int
+	isClosed bool
+}
+
+func (t *pathTile) bestG(search uint32) (int, bool) { return t.g, t.search == search }
+func (t *pathTile) closed(search uint32) bool       { return t.search == search && t.isClosed }
+
+func (t *pathTile) setG(search uint32, g int) {
+	if t.search != search {
+		*t = pathTile{search: search}
+	}
+	t.g = g
+}
+
+// close marks a tile expanded, it was given a g when it was pushed.
+func (t *pathTile) close() { t.isClosed = true }
+
+// pathGrid is a pathTile per map tile, kept on the sim between searches so
+// a long path doesn't build maps of the whole map every time.
+type pathGrid struct {
+	search uint32
+	height int
+	tiles  []pathTile
+}
+
+func (g *pathGrid) get(x, y int) *pathTile { return &g.tiles[x*g.height+y] }
+
+// pathTiles starts a new search on the sim's path grid.
+func (s *T) pathTiles() *pathGrid {
+	tm := s.world.TileMap
+	if s.pathGrid == nil || len(s.pathGrid.tiles) != tm.Width*tm.Height {
+		s.pathGrid = &pathGrid{height: tm.Height, tiles: make([]pathTile, tm.Width*tm.Height)}
+	}
+	s.pathGrid.search++
+	return s.pathGrid
+}
+
+// planPath fills the unit's waypoints towards its Destination. The first and
+// last tiles are dropped: the unit is already on the first one and heads for
+// the exact Destination instead of the last tile's center.
+func (unit *Unit) planPath(sim *T, avoidUnits bool) {
+	goal := *unit.Destination
+	unit.pathGoal = &goal
+	unit.Path = nil
+
+	var avoid map[image.Point]bool
+	if avoidUnits {
+		avoid = sim.occupiedTiles(unit)
+	}
+	destCenter := image.Pt(goal.X+unit.Rect.Dx()/2, goal.Y+unit.Rect.Dy()/2)
+	tiles := findPath(*unit.GetCenteredPosition(), destCenter, sim, avoid)
+	if len(tiles) <= 2 {
+		return // unreachable or right next to us, walk straight there
+	}
+	tileSize := sim.world.TileMap.TileSize
+	for _, tile := range tiles[1 : len(tiles)-1] {
+		unit.Path = append(unit.Path, image.Point{
+			X: tile.X + tileSize/2 - unit.Rect.Dx()/2,
+			Y: tile.Y + tileSize/2 - unit.Rect.Dy()/2,
+		})
+	}
+}
+
+// nextWaypoint drops waypoints the unit has reached and returns where it
+// should head next.
+func (unit *Unit) nextWaypoint() image.Point {
+	reach := max(int(unit.Stats.MoveSpeed), ArrivalThreshold/2)
+	for len(unit.Path) > 0 {
+		wp := unit.Path[0]
+		if abs(wp.X-unit.Position.X) > reach || abs(wp.Y-unit.Position.Y) > reach {
+			return wp
+		}
+		unit.Path = unit.Path[1:]
+	}
+	return *unit.Destination
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,159 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// UnitSpec is what it takes for a hive to make a unit.
+type UnitSpec struct {
+	Name        string
+	WoodCost    uint16
+	SucroseCost uint16
+	BuildTime   uint   // ticks
+	Supply      uint16 // see SupplyProvided
+}
+
+// UnitCatalog is everything hives can make, tweak the specs to rebalance.
+var UnitCatalog = map[UnitType]*UnitSpec{
+	UnitTypeDefaultAnt:   {Name: "Ant", SucroseCost: 50, BuildTime: 120, Supply: 1},
+	UnitTypeDefaultRoach: {Name: "Roach", SucroseCost: 50, BuildTime: 120, Supply: 1},
+	UnitTypeSoldierAnt:   {Name: "Soldier Ant", WoodCost: 25, SucroseCost: 75, BuildTime: 180, Supply: 2},
+}
+
+// Produces is what each kind of hive makes, the first one is what
+// ConstructUnit queues.
+var Produces = map[BuildingType][]UnitType{
+	BuildingTypeHive:      {UnitTypeDefaultAnt, UnitTypeSoldierAnt},
+	BuildingTypeRoachHive: {UnitTypeDefaultRoach},
+	BuildingTypeBarracks:  {UnitTypeSoldierAnt},
+}
+
+// MaxBuildQueue is how many units a hive can have queued, counting the one
+// it's working on.
+var MaxBuildQueue = 5
+
+// ErrBuildQueueFull is returned by QueueUnit when the hive has MaxBuildQueue
+// units queued already.
+var ErrBuildQueueFull = errors.New("build queue is full")
+
+// ProductionOrder is a unit waiting in a hive's build queue. Cancelling it
+// gives back what Spec cost.
+type ProductionOrder struct {
+	Type UnitType
+	Spec *UnitSpec
+}
+
+func newProductionOrder(ut UnitType) ProductionOrder {
+	return ProductionOrder{Type: ut, Spec: UnitCatalog[ut]}
+}
+
+// MissingUnitResource returns the first resource the faction doesn't have
+// enough of to make ut, or "" if it can afford it.
+func (s *T) MissingUnitResource(faction uint, ut UnitType) string {
+	spec, ok := UnitCatalog[ut]
+	if !ok {
+		return ""
+	}
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	state := s.factionState(faction)
+	switch {
+	case state.Wood < spec.WoodCost:
+		return "Wood"
+	case state.Sucrose < spec.SucroseCost:
+		return "Sucrose"
+	}
+	return ""
+}
+
+// ConstructUnit queues the first unit type the hive makes, see QueueUnit.
+func (s *T) ConstructUnit(hiveID string) bool {
+	hive, err := s.hiveByID(hiveID)
+	if err != nil {
+		return false
+	}
+	return s.QueueUnit(hiveID, Produces[hive.Type][0]) == nil
+}
+
+// QueueUnit pays for a unit of type ut and adds it to the end of the hive's
+// build queue. It needs the supply for it as well as the resources.
+func (s *T) QueueUnit(hiveID string, ut UnitType) error {
+	hive, err := s.hiveByID(hiveID)
+	if err != nil {
+		return err
+	}
+	spec, ok := UnitCatalog[ut]
+	if !ok || !slices.Contains(Produces[hive.Type], ut) {
+		return fmt.Errorf("building type %d can't make unit type %d", hive.Type, ut)
+	}
+	if hive.buildQueue.Len() >= MaxBuildQueue {
+		return ErrBuildQueueFull
+	}
+	if used, provided := s.Supply(hive.Faction); used+spec.Supply > provided {
+		return ErrSupplyCapped
+	}
+	if name := s.MissingUnitResource(hive.Faction, ut); name != "" {
+		return fmt.Errorf("not enough %v", name)
+	}
+	s.stateMu.Lock()
+	state := s.factionState(hive.Faction)
+	state.Wood -= spec.WoodCost
+	state.Sucrose -= spec.SucroseCost
+	s.stateMu.Unlock()
+	hive.buildQueue.Enqueue(newProductionOrder(ut))
+	s.record(Command{Type: CommandConstructUnit, ID: hiveID, Unit: &ut})
+	return nil
+}
+
+// CancelUnit takes the unit in the given slot out of the hive's build queue
+// and refunds all of it. Slot 0 is the one being made, its progress is lost.
+func (s *T) CancelUnit(hiveID string, slot int) error {
+	hive, err := s.hiveByID(hiveID)
+	if err != nil {
+		return err
+	}
+	order, err := hive.buildQueue.RemoveAt(slot)
+	if err != nil {
+		return err
+	}
+	s.record(Command{Type: CommandCancelUnit, ID: hiveID, Slot: slot})
+	s.stateMu.Lock()
+	state := s.factionState(hive.Faction)
+	state.Wood += order.Spec.WoodCost
+	state.Sucrose += order.Spec.SucroseCost
+	s.stateMu.Unlock()
+	if slot == 0 {
+		hive.ProgressCurrent = 0
+		hive.UnitContructing = false
+	}
+	return nil
+}
+
+// GetBuildQueue returns what the hive has queued, the one being made first.
+func (s *T) GetBuildQueue(hiveID string) []ProductionOrder {
+	hive, err := s.hiveByID(hiveID)
+	if err != nil {
+		return nil
+	}
+	return hive.buildQueue.Items()
+}
+
+// MakesUnits reports whether the building can be asked to ConstructUnit.
+func (s *T) MakesUnits(buildingID string) bool {
+	_, err := s.hiveByID(buildingID)
+	return err == nil
+}
+
+func (s *T) hiveByID(id string) (*Hive, error) {
+	building, err := s.GetBuildingByID(id)
+	if err != nil {
+		return nil, err
+	}
+	hive, ok := building.(*Hive)
+	if !ok {
+		return nil, errors.New("building doesn't make units")
+	}
+	return hive, nil
+}
@@ -0,0 +1,74 @@
+package sim
+
+import (
+	"image"
+)
+
+// RallyPoint is where a hive sends the units it makes. When it was set on a
+// unit, new units head to wherever that unit is when they come out, or to
+// Point once it's gone.
+type RallyPoint struct {
+	Point  image.Point
+	UnitID string `json:",omitempty"`
+}
+
+// SetRallyPoint sets where the hive's new units go, same as a right click on
+// point: a resource has them gather from it, a unit has them follow it and
+// an enemy has them attack move. A point on the hive itself clears it.
+func (s *T) SetRallyPoint(hiveID string, point image.Point) error {
+	hive, err := s.hiveByID(hiveID)
+	if err != nil {
+		return err
+	}
+	s.record(Command{Type: CommandSetRallyPoint, ID: hiveID, Point: &point})
+	if point.In(*hive.Rect) {
+		hive.rally = nil
+		return nil
+	}
+	hive.rally = &RallyPoint{Point: point}
+	if unit := s.unitAt(point); unit != nil {
+		hive.rally.UnitID = unit.ID.String()
+	}
+	return nil
+}
+
+// GetRallyPoint returns where the hive's new units go right now, and false
+// if it has no rally point.
+func (s *T) GetRallyPoint(hiveID string) (image.Point, bool) {
+	hive, err := s.hiveByID(hiveID)
+	if err != nil || hive.rally == nil {
+		return image.Point{}, false
+	}
+	return hive.rallyTarget(s), true
+}
+
+// rallyTarget is the rally point, or where the followed unit is now.
+func (h *Hive) rallyTarget(sim *T) image.Point {
+	if h.rally.UnitID != "" {
+		if unit, err := sim.GetUnitByID(h.rally.UnitID); err == nil && !unit.IsDead() {
+			return *unit.GetCenteredPosition()
+		}
+	}
+	return h.rally.Point
+}
+
+// rallyOrder is the order a unit fresh out of the hive gets. Friendly units
+// are walked up to rather than attack moved onto.
+func (h *Hive) rallyOrder(sim *T) Order {
+	target := h.rallyTarget(sim)
+	if unit, err := sim.GetUnitByID(h.rally.UnitID); err == nil && !unit.IsDead() && unit.Faction == h.Faction {
+		return Order{Type: MoveOrder, Point: target}
+	}
+	return Order{Type: SmartOrder, Point: target}
+}
+
+// unitAt returns a live unit covering point, if there is one.
+func (s *T) unitAt(point image.Point) *Unit {
+	var found *Unit
+	s.unitIndex.Query(radiusRect(point, 1), func(unit *Unit, rect *image.Rectangle) {
+		if found == nil && !unit.IsDead() && point.In(*rect) {
+			found = unit
+		}
+	})
+	return found
+}
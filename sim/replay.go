@@ -0,0 +1,261 @@
+package sim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"gamejam/eventing"
+	"hash/fnv"
+	"image"
+	"math"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+var DefaultSeed = uint64(1)
+
+// ChecksumInterval is how many ticks apart state checksums are recorded.
+var ChecksumInterval = uint64(60)
+
+type CommandType string
+
+const (
+	CommandIssueAction        CommandType = "IssueAction"
+	CommandConstructUnit      CommandType = "ConstructUnit"
+	CommandConstructBuilding  CommandType = "ConstructBuilding"
+	CommandQueueOrder         CommandType = "QueueOrder"
+	CommandCancelConstruction CommandType = "CancelConstruction"
+	CommandSetRallyPoint      CommandType = "SetRallyPoint"
+	CommandCancelUnit         CommandType = "CancelUnit"
+)
+
+// Command is one input given to the sim from outside of Update, e.g. a
+// player click or a cutscene. Tick is how many updates had run when it came in.
+type Command struct {
+	Tick   uint64
+	Type   CommandType
+	ID     string           // unit, hive, builder or site the command is for
+	Point  *image.Point     `json:",omitempty"`
+	Target *image.Rectangle `json:",omitempty"`
+	Order  *Order           `json:",omitempty"`
+
+	Building *BuildingType `json:",omitempty"` // for ConstructBuilding, nil was recorded when only bridges existed
+	Unit     *UnitType     `json:",omitempty"` // for ConstructUnit, nil is the hive's first unit type
+	Slot     int           `json:",omitempty"` // build queue slot for CancelUnit
+}
+
+type Checksum struct {
+	Tick uint64
+	Sum  uint64
+}
+
+// Recording is everything needed to play a session back on a sim that was
+// set up the same way: the seed, every command and checksums to compare.
+type Recording struct {
+	Seed      uint64
+	Commands  []Command
+	Checksums []Checksum
+}
+
+// DesyncError is returned by Replay when the replayed state stops matching.
+type DesyncError struct {
+	Tick     uint64
+	Expected uint64
+	Got      uint64
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("desync at tick %d: checksum %x, recorded %x", e.Tick, e.Got, e.Expected)
+}
+
+func (s *T) GetTick() uint64 { return s.tick }
+
+// GetTPS is how many ticks make a second of game time.
+func (s *T) GetTPS() int { return s.tps }
+
+// GetRecording returns the commands and checksums recorded since New.
+func (s *T) GetRecording() *Recording {
+	return &Recording{
+		Seed:      s.seed,
+		Commands:  append([]Command(nil), s.commands...),
+		Checksums: append([]Checksum(nil), s.checksums...),
+	}
+}
+
+// newID hands out sequential IDs so runs with the same inputs match.
+func (s *T) newID() uuid.UUID {
+	s.nextID++
+	var id uuid.UUID
+	binary.BigEndian.PutUint64(id[8:], s.nextID)
+	return id
+}
+
+// record keeps a command if it came from outside Update. Commands given by
+// controllers during Update are replayed by the controllers themselves.
+// Callers record once the command is accepted, so rejected orders stay out
+// of the log.
+func (s *T) record(cmd Command) {
+	if s.updating {
+		return
+	}
+	cmd.Tick = s.tick
+	s.commands = append(s.commands, cmd)
+}
+
+func (s *T) applyCommand(cmd Command) {
+	switch cmd.Type {
+	case CommandIssueAction:
+		if cmd.Order == nil { // recorded before orders had types
+			point := *cmd.Point
+			s.IssueAction(cmd.ID, SmartOrder, &point)
+		} else {
+			s.IssueOrder(cmd.ID, *cmd.Order)
+		}
+	case CommandConstructUnit:
+		if cmd.Unit == nil {
+			s.ConstructUnit(cmd.ID)
+		} else {
+			s.QueueUnit(cmd.ID, *cmd.Unit)
+		}
+	case CommandConstructBuilding:
+		target := *cmd.Target
+		bt := BuildingTypeBridge
+		if cmd.Building != nil {
+			bt = *cmd.Building
+		}
+		s.ConstructBuilding(bt, &target, cmd.ID)
+	case CommandQueueOrder:
+		s.QueueOrder(cmd.ID, *cmd.Order)
+	case CommandCancelConstruction:
+		s.CancelConstruction(cmd.ID)
+	case CommandSetRallyPoint:
+		s.SetRallyPoint(cmd.ID, *cmd.Point)
+	case CommandCancelUnit:
+		s.CancelUnit(cmd.ID, cmd.Slot)
+	}
+}
+
+// Replay runs the sim until the recording's last command and checksum have
+// been reached, feeding commands in at their ticks. The sim must be set up
+// the same way the recorded one was, with the same seed.
+func (s *T) Replay(rec *Recording) error {
+	if rec.Seed != s.seed {
+		return fmt.Errorf("recording seed %d, sim seed %d", rec.Seed, s.seed)
+	}
+	var end uint64
+	if n := len(rec.Commands); n > 0 {
+		end = rec.Commands[n-1].Tick
+	}
+	if n := len(rec.Checksums); n > 0 {
+		end = max(end, rec.Checksums[n-1].Tick)
+	}
+
+	nextCmd, nextSum := 0, 0
+	for {
+		// checksums are taken at the end of Update, before that tick's commands
+		for nextSum < len(rec.Checksums) && rec.Checksums[nextSum].Tick <= s.tick {
+			expected := rec.Checksums[nextSum]
+			if expected.Tick == s.tick {
+				if got := s.Checksum(); got != expected.Sum {
+					return &DesyncError{Tick: s.tick, Expected: expected.Sum, Got: got}
+				}
+			}
+			nextSum++
+		}
+		for nextCmd < len(rec.Commands) && rec.Commands[nextCmd].Tick <= s.tick {
+			s.applyCommand(rec.Commands[nextCmd])
+			nextCmd++
+		}
+		if s.tick >= end {
+			return nil
+		}
+		s.Update()
+	}
+}
+
+// Checksum hashes everything that affects how the sim plays out.
+func (s *T) Checksum() uint64 {
+	h := fnv.New64a()
+	write := func(values ...uint64) {
+		var buf [8]byte
+		for _, v := range values {
+			binary.LittleEndian.PutUint64(buf[:], v)
+			h.Write(buf[:])
+		}
+	}
+	point := func(p *image.Point) {
+		if p == nil {
+			write(0)
+			return
+		}
+		write(1, uint64(p.X), uint64(p.Y))
+	}
+
+	s.stateMu.RLock()
+	write(s.tick, s.nextID,
+		uint64(s.playerState.Sucrose), uint64(s.playerState.Wood),
+		uint64(s.enemyState.Sucrose), uint64(s.enemyState.Wood))
+	s.stateMu.RUnlock()
+
+	for _, unit := range s.GetAllUnits() {
+		h.Write(unit.ID[:])
+		write(uint64(unit.Type), uint64(unit.Faction), uint64(unit.Action), math.Float64bits(unit.MovingAngle))
+		point(unit.Position)
+		point(unit.Destination)
+		write(uint64(unit.Stats.HPCur), uint64(unit.Stats.ResourceCarried), uint64(unit.Stats.ResourceCollectTime),
+			uint64(unit.AttackCooldown), uint64(unit.StuckFrames))
+		h.Write([]byte(unit.Stats.ResourceTypeCarried))
+	}
+	for _, building := range s.playerBuildings {
+		id := building.GetID()
+		h.Write(id[:])
+		write(uint64(building.GetType()), uint64(building.GetFaction()))
+		point(&building.GetRect().Min)
+		b := building.(baser).base()
+		write(uint64(b.ProgressCurrent), uint64(b.HPCur))
+		switch b := building.(type) {
+		case *Hive:
+			write(uint64(b.buildQueue.Len()))
+			if b.rally != nil {
+				point(&b.rally.Point)
+			}
+		case *DefensiveMound:
+			write(uint64(b.AttackCooldown))
+		}
+	}
+	for _, node := range s.resourceNodes {
+		write(uint64(node.Remaining))
+	}
+	for _, t := range s.triggers {
+		write(uint64(len(t.inside)))
+		if t.fired {
+			write(1)
+		}
+	}
+	return h.Sum64()
+}
+
+// SimEvents are the events the sim publishes itself. InjectEvents skips them,
+// the events that caused them make them again.
+var SimEvents = []string{"UnitDiedEvent", "BuildingDestroyedEvent", "ResourceDepletedEvent", "ResourceRegrownEvent", "TriggerEnteredEvent", "NotEnoughResourcesEvent"}
+
+// InjectEvents runs the sim up to each recorded event's tick and publishes it
+// on the bus again, e.g. to see what a log from the game does headless. Only
+// events something subscribed to do anything. Records have to be in order.
+func (s *T) InjectEvents(records []eventing.Recorded) error {
+	for _, rec := range records {
+		if slices.Contains(SimEvents, rec.Type) {
+			continue
+		}
+		if rec.Tick < s.tick {
+			return fmt.Errorf("%v at tick %d, the sim is at %d", rec.Type, rec.Tick, s.tick)
+		}
+		for s.tick < rec.Tick {
+			s.Update()
+		}
+		if err := eventing.Republish(s.EventBus, rec); err != nil {
+			return fmt.Errorf("tick %d: %w", rec.Tick, err)
+		}
+	}
+	return nil
+}
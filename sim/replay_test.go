@@ -0,0 +1,147 @@
+package sim
+
+import (
+	"encoding/json"
+	"errors"
+	"gamejam/tilemap"
+	"image"
+	"testing"
+)
+
+const testSeed = 42
+
+// newSkirmish is a small fight on map2: the player's hive and six ants
+// against a roach hive run by RoachAI.
+func newSkirmish(t testing.TB, seed uint64) *T {
+	t.Helper()
+	tm, err := tilemap.LoadTilemap("tilemap/map2.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSeeded(60, tm, seed)
+	hive := NewHive()
+	hive.SetTilePosition(14, 14)
+	s.AddBuilding(hive)
+	for i := range 6 {
+		ant := NewDefaultAnt()
+		ant.SetTilePosition(10+i%3, 12+i/3)
+		s.AddUnit(ant)
+	}
+	roachHive := NewRoachHive()
+	roachHive.SetTilePosition(8, 3)
+	roachHive.SetFaction(uint(EnemyFaction))
+	s.AddBuilding(roachHive)
+	s.playerState.Sucrose = 200
+	s.enemyState.Sucrose = 1000 // enough for a wave or two
+	s.AddController(NewRoachAI(uint(EnemyFaction)))
+	return s
+}
+
+// playSkirmish runs ticks updates on s, giving the player's commands along
+// the way.
+func playSkirmish(s *T, ticks int) {
+	hiveID := s.GetBuildingsByFaction(uint(PlayerFaction))[0].GetID().String()
+	for tick := range ticks {
+		switch tick {
+		case 100:
+			target := image.Pt(9*128, 5*128)
+			for _, ant := range s.GetUnitsByFaction(uint(PlayerFaction)) {
+				s.IssueAction(ant.ID.String(), SmartOrder, &target)
+			}
+		case 200:
+			s.ConstructUnit(hiveID)
+			s.ConstructUnit(hiveID)
+		case 250:
+			s.CancelUnit(hiveID, 1)
+		case 300:
+			s.SetRallyPoint(hiveID, image.Pt(12*128, 12*128))
+		}
+		s.Update()
+	}
+}
+
+func TestReplayMatchesRecording(t *testing.T) {
+	s := newSkirmish(t, testSeed)
+	playSkirmish(s, 1800)
+	rec := s.GetRecording()
+	if len(rec.Commands) == 0 || len(rec.Checksums) == 0 {
+		t.Fatalf("recorded %d commands and %d checksums", len(rec.Commands), len(rec.Checksums))
+	}
+
+	// go through JSON like a saved replay would
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Recording
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := newSkirmish(t, testSeed)
+	if err := replayed.Replay(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	if replayed.GetTick() != s.GetTick() {
+		t.Errorf("replay stopped at tick %d, recording at %d", replayed.GetTick(), s.GetTick())
+	}
+	if replayed.Checksum() != s.Checksum() {
+		t.Errorf("final checksum %x, want %x", replayed.Checksum(), s.Checksum())
+	}
+}
+
+func TestReplayDetectsDesync(t *testing.T) {
+	s := newSkirmish(t, testSeed)
+	playSkirmish(s, 600)
+
+	changed := newSkirmish(t, testSeed)
+	changed.playerState.Sucrose += 50
+	var desync *DesyncError
+	if err := changed.Replay(s.GetRecording()); !errors.As(err, &desync) {
+		t.Fatalf("got %v, want a DesyncError", err)
+	}
+
+	other := newSkirmish(t, testSeed+1)
+	if err := other.Replay(s.GetRecording()); err == nil {
+		t.Fatal("replayed on a sim with another seed")
+	}
+}
+
+func TestRestoreCarriesOn(t *testing.T) {
+	s := newSkirmish(t, testSeed)
+	playSkirmish(s, 915) // mid think, see RoachAIThinkFrames
+	data, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+
+	tm, err := tilemap.LoadTilemap("tilemap/map2.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewSeeded(60, tm, testSeed)
+	restored.AddController(NewRoachAI(uint(EnemyFaction)))
+	if err := restored.Restore(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Checksum() != s.Checksum() {
+		t.Fatalf("restored checksum %x, want %x", restored.Checksum(), s.Checksum())
+	}
+	for range 1800 {
+		s.Update()
+		restored.Update()
+	}
+	if restored.Checksum() != s.Checksum() {
+		t.Errorf("checksum %x after carrying on, want %x", restored.Checksum(), s.Checksum())
+	}
+
+	// the command log came along, so the whole game still replays
+	replayed := newSkirmish(t, testSeed)
+	if err := replayed.Replay(restored.GetRecording()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,200 @@
+package sim
+
+import (
+	"gamejam/eventing"
+	"gamejam/tilemap"
+	"image"
+	"math"
+)
+
+// How much a fresh resource tile holds before it's harvested dry, unless the
+// tileset gives it a resource_amount.
+var SucroseNodeAmount = uint(1000)
+var WoodNodeAmount = uint(500)
+
+// HarvestAmount is how much a unit carries back per trip.
+var HarvestAmount = uint(5)
+
+// MaxHarvestersPerNode is how many units can work a node at once, extra
+// gatherers get sent to the nearest node with room.
+var MaxHarvestersPerNode = 3
+
+// WoodRegrowFrames is how often every wood node grows back WoodRegrowAmount,
+// up to what it started with. 0 turns regrowth off.
+var WoodRegrowFrames = uint64(600)
+var WoodRegrowAmount = uint(5)
+
+// ResourceNode is a harvestable resource tile that runs out.
+type ResourceNode struct {
+	Type      string          // "sucrose" or "wood"
+	Tile      image.Point     // tile coordinates
+	Rect      image.Rectangle // world rect of the tile
+	Remaining uint
+	Max       uint
+}
+
+// Depleted reports whether there's nothing left to harvest.
+func (node *ResourceNode) Depleted() bool {
+	return node.Remaining == 0
+}
+
+func (node *ResourceNode) center() image.Point {
+	return node.Rect.Min.Add(node.Rect.Max).Div(2)
+}
+
+// newResourceNodes makes a node for every resource tile on the map, column by
+// column so the order is always the same.
+func newResourceNodes(tileMap *tilemap.Tilemap) ([]*ResourceNode, map[image.Point]*ResourceNode) {
+	var nodes []*ResourceNode
+	byTile := make(map[image.Point]*ResourceNode)
+	for _, column := range tileMap.Tiles {
+		for _, tile := range column {
+			if tile == nil {
+				continue
+			}
+			amount := tile.ResourceAmount
+			switch {
+			case tile.Resource == "":
+				continue
+			case amount > 0: // the tileset says
+			case tile.Resource == tilemap.ResourceSucrose:
+				amount = SucroseNodeAmount
+			default:
+				amount = WoodNodeAmount
+			}
+			node := &ResourceNode{
+				Type:      tile.Resource,
+				Tile:      *tile.Coordinates,
+				Rect:      *tile.Rect,
+				Remaining: amount,
+				Max:       amount,
+			}
+			nodes = append(nodes, node)
+			byTile[node.Tile] = node
+		}
+	}
+	return nodes, byTile
+}
+
+// GetResourceNodes returns every resource node, depleted ones included.
+func (s *T) GetResourceNodes() []*ResourceNode {
+	return s.resourceNodes
+}
+
+// GetResourceNodeAt returns the node covering a world position, or nil.
+func (s *T) GetResourceNodeAt(point image.Point) *ResourceNode {
+	if point.X < 0 || point.Y < 0 {
+		return nil
+	}
+	x, y := WorldToTile(point, s.world.TileMap.TileSize)
+	return s.resourceNodeIndex[image.Pt(x, y)]
+}
+
+// harvesters counts the units working a node, i.e. headed to it or bringing
+// back what they took from it, leaving out except.
+func (s *T) harvesters(node *ResourceNode, except *Unit) int {
+	count := 0
+	for _, unit := range s.GetAllUnits() {
+		if unit == except || unit.IsDead() {
+			continue
+		}
+		var working *image.Point
+		switch unit.Action {
+		case CollectingAction:
+			working = unit.Destination
+		case DeliveringAction:
+			working = unit.LastResourcePos
+		}
+		if working != nil && working.In(node.Rect) {
+			count++
+		}
+	}
+	return count
+}
+
+// hasRoom reports whether unit can start working node.
+func (s *T) hasRoom(node *ResourceNode, unit *Unit) bool {
+	return !node.Depleted() && s.harvesters(node, unit) < MaxHarvestersPerNode
+}
+
+// FindNearestResourceNode returns the closest node of the given type that
+// isn't depleted and has room for unit, or nil. unit can be nil to ignore
+// harvester limits.
+func (s *T) FindNearestResourceNode(from image.Point, resourceType string, unit *Unit) *ResourceNode {
+	var nearest *ResourceNode
+	minDist := math.MaxInt
+	for _, node := range s.resourceNodes {
+		if node.Type != resourceType || node.Depleted() {
+			continue
+		}
+		if unit != nil && !s.hasRoom(node, unit) {
+			continue
+		}
+		d := node.center().Sub(from)
+		if dist := d.X*d.X + d.Y*d.Y; dist < minDist {
+			nearest = node
+			minDist = dist
+		}
+	}
+	return nearest
+}
+
+// gatherPoint is where a unit told to gather at point actually goes: the
+// node itself if it has room, otherwise the nearest one of the same kind
+// that does. Full nodes are still worked if there's nothing else.
+func (s *T) gatherPoint(unit *Unit, point image.Point) image.Point {
+	node := s.GetResourceNodeAt(point)
+	if node == nil || s.hasRoom(node, unit) {
+		return point
+	}
+	if other := s.FindNearestResourceNode(point, node.Type, unit); other != nil {
+		return other.center()
+	}
+	return point
+}
+
+// retargetHarvest sends a unit whose node ran dry to the nearest node of the
+// same type, or leaves it idle when there's none.
+func (unit *Unit) retargetHarvest(sim *T, node *ResourceNode) {
+	unit.Stats.ResourceCollectTime = 0
+	var next *ResourceNode
+	if node != nil {
+		next = sim.FindNearestResourceNode(*unit.GetCenteredPosition(), node.Type, unit)
+		if next == nil { // everything's busy, queue up at the closest one
+			next = sim.FindNearestResourceNode(*unit.GetCenteredPosition(), node.Type, nil)
+		}
+	}
+	if next == nil {
+		unit.Action = IdleAction
+		return
+	}
+	dest := next.center()
+	unit.Destination = &dest
+}
+
+// harvest takes up to amount from the node and returns how much was taken.
+func (s *T) harvest(node *ResourceNode, amount uint) uint {
+	amount = min(amount, node.Remaining)
+	node.Remaining -= amount
+	if node.Depleted() {
+		eventing.PublishDeferred(s.EventBus, eventing.ResourceDepletedEvent{Tile: node.Tile, Resource: node.Type})
+	}
+	return amount
+}
+
+// regrowResources lets wood come back, see WoodRegrowFrames.
+func (s *T) regrowResources() {
+	if WoodRegrowFrames == 0 || s.tick%WoodRegrowFrames != 0 {
+		return
+	}
+	for _, node := range s.resourceNodes {
+		if node.Type != tilemap.ResourceWood || node.Remaining >= node.Max {
+			continue
+		}
+		wasDepleted := node.Depleted()
+		node.Remaining = min(node.Max, node.Remaining+WoodRegrowAmount)
+		if wasDepleted {
+			eventing.PublishDeferred(s.EventBus, eventing.ResourceRegrownEvent{Tile: node.Tile, Resource: node.Type})
+		}
+	}
+}
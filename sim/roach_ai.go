@@ -0,0 +1,273 @@
+package sim
+
+import (
+	"image"
+	"slices"
+)
+
+var RoachAIThinkFrames = 30
+var RoachAIHarvesterCount = 4
+var RoachAIFirstWaveSize = 4
+var RoachAIWaveGrowth = 2
+var RoachAIMaxQueuedUnits = 2
+var RoachAIScoutTimeoutFrames = 60 * 20 // give up on a scout point after this long
+
+type roachRole int
+
+const (
+	roachRoleSoldier roachRole = iota
+	roachRoleHarvester
+	roachRoleScout
+)
+
+// RoachAI is the Controller for the roach faction. Every few frames it tops up
+// its harvesters, spends sucrose on new roaches at its hives, keeps one scout
+// looking for the player and sends soldiers out in growing attack waves once
+// it knows where the player is.
+type RoachAI struct {
+	faction  uint
+	frame    int
+	roles    map[string]roachRole
+	waveSize int
+
+	scoutPoints   []image.Point
+	nextScoutIdx  int
+	scoutSentAt   int
+	KnownTarget   *image.Point
+	WavesLaunched int
+}
+
+func NewRoachAI(faction uint) *RoachAI {
+	return &RoachAI{
+		faction:  faction,
+		roles:    make(map[string]roachRole),
+		waveSize: RoachAIFirstWaveSize,
+	}
+}
+
+func (ai *RoachAI) GetFaction() uint { return ai.faction }
+
+// RoachAISnapshot is a RoachAI's memory. Scout points aren't kept, they come
+// from the map.
+type RoachAISnapshot struct {
+	Frame         int
+	Roles         map[string]int // unit ID to role
+	WaveSize      int
+	NextScoutIdx  int
+	ScoutSentAt   int
+	KnownTarget   *image.Point `json:",omitempty"`
+	WavesLaunched int
+}
+
+func (ai *RoachAI) snapshot() *RoachAISnapshot {
+	snap := &RoachAISnapshot{
+		Frame:         ai.frame,
+		Roles:         make(map[string]int, len(ai.roles)),
+		WaveSize:      ai.waveSize,
+		NextScoutIdx:  ai.nextScoutIdx,
+		ScoutSentAt:   ai.scoutSentAt,
+		KnownTarget:   clonePoint(ai.KnownTarget),
+		WavesLaunched: ai.WavesLaunched,
+	}
+	for id, role := range ai.roles {
+		snap.Roles[id] = int(role)
+	}
+	return snap
+}
+
+func (ai *RoachAI) restore(snap *RoachAISnapshot) {
+	ai.frame = snap.Frame
+	ai.roles = make(map[string]roachRole, len(snap.Roles))
+	for id, role := range snap.Roles {
+		ai.roles[id] = roachRole(role)
+	}
+	ai.waveSize = snap.WaveSize
+	ai.nextScoutIdx = snap.NextScoutIdx
+	ai.scoutSentAt = snap.ScoutSentAt
+	ai.KnownTarget = clonePoint(snap.KnownTarget)
+	ai.WavesLaunched = snap.WavesLaunched
+}
+
+func (ai *RoachAI) Update(sim *T) {
+	ai.frame++
+	if ai.frame%RoachAIThinkFrames != 0 {
+		return
+	}
+	units := sim.GetUnitsByFaction(ai.faction)
+	ai.pruneRoles(units)
+	ai.assignRoles(units)
+	ai.lookForPlayer(sim, units)
+
+	ai.defend(sim, units)
+	ai.harvest(sim, units)
+	ai.build(sim)
+	ai.scout(sim, units)
+	ai.attack(sim, units)
+}
+
+// pruneRoles forgets units that have died since the last think.
+func (ai *RoachAI) pruneRoles(units []*Unit) {
+	for id := range ai.roles {
+		if !slices.ContainsFunc(units, func(u *Unit) bool { return u.ID.String() == id }) {
+			delete(ai.roles, id)
+		}
+	}
+}
+
+// assignRoles gives every new roach a job: harvesting first, then one scout,
+// everything else fights. Royal roaches are left alone.
+func (ai *RoachAI) assignRoles(units []*Unit) {
+	harvesters, scouts := 0, 0
+	for _, role := range ai.roles {
+		switch role {
+		case roachRoleHarvester:
+			harvesters++
+		case roachRoleScout:
+			scouts++
+		}
+	}
+	for _, unit := range units {
+		id := unit.ID.String()
+		if _, ok := ai.roles[id]; ok || unit.Type != UnitTypeDefaultRoach {
+			continue
+		}
+		switch {
+		case harvesters < RoachAIHarvesterCount:
+			ai.roles[id] = roachRoleHarvester
+			harvesters++
+		case scouts < 1 && ai.KnownTarget == nil:
+			ai.roles[id] = roachRoleScout
+			scouts++
+		default:
+			ai.roles[id] = roachRoleSoldier
+		}
+	}
+}
+
+// lookForPlayer remembers the position of any hostile building or unit
+// that one of our units can currently see.
+func (ai *RoachAI) lookForPlayer(sim *T, units []*Unit) {
+	for _, unit := range units {
+		for _, building := range sim.GetAllBuildings() {
+			if building.GetFaction() == ai.faction || building.GetFaction() == uint(NeutralFaction) {
+				continue
+			}
+			if unit.EdgeDistanceToRect(building.GetRect()) <= unit.Stats.SightRange {
+				ai.KnownTarget = building.GetCenteredPosition()
+				return
+			}
+		}
+		if unit.NearestEnemy != nil {
+			ai.KnownTarget = unit.NearestEnemy.GetCenteredPosition()
+			return
+		}
+	}
+}
+
+func (ai *RoachAI) harvest(sim *T, units []*Unit) {
+	for _, unit := range units {
+		if ai.roles[unit.ID.String()] != roachRoleHarvester {
+			continue
+		}
+		fought := unit.Action == AttackMovingAction && unit.NearestEnemy == nil
+		if unit.Action != IdleAction && !fought {
+			continue
+		}
+		node := sim.FindNearestResourceNode(*unit.GetCenteredPosition(), "sucrose", unit)
+		if node == nil {
+			continue
+		}
+		target := node.center()
+		sim.IssueAction(unit.ID.String(), GatherOrder, &target)
+	}
+}
+
+// defend has harvesters fight back when something hostile comes at them,
+// they'd otherwise keep gathering until they die. harvest sends them back to
+// work once the fight is over.
+func (ai *RoachAI) defend(sim *T, units []*Unit) {
+	for _, unit := range units {
+		if ai.roles[unit.ID.String()] != roachRoleHarvester || unit.NearestEnemy == nil {
+			continue
+		}
+		if unit.Action != CollectingAction && unit.Action != DeliveringAction {
+			continue
+		}
+		target := *unit.NearestEnemy.GetCenteredPosition()
+		sim.IssueAction(unit.ID.String(), AttackMoveOrder, &target)
+	}
+}
+
+// build keeps the faction's roach hives busy, other hives are left to
+// whoever else plays the faction.
+func (ai *RoachAI) build(sim *T) {
+	for _, building := range sim.GetBuildingsByFaction(ai.faction) {
+		hive, ok := building.(*Hive)
+		if !ok || !slices.Contains(Produces[hive.Type], UnitTypeDefaultRoach) || hive.buildQueue.Len() >= RoachAIMaxQueuedUnits {
+			continue
+		}
+		sim.ConstructUnit(hive.ID.String())
+	}
+}
+
+func (ai *RoachAI) scout(sim *T, units []*Unit) {
+	if ai.scoutPoints == nil {
+		ai.scoutPoints = sim.scoutPoints()
+	}
+	for _, unit := range units {
+		if ai.roles[unit.ID.String()] != roachRoleScout {
+			continue
+		}
+		if ai.KnownTarget != nil { // job done, join the army
+			ai.roles[unit.ID.String()] = roachRoleSoldier
+			continue
+		}
+		timedOut := ai.frame-ai.scoutSentAt > RoachAIScoutTimeoutFrames
+		if (unit.Action == IdleAction || timedOut) && len(ai.scoutPoints) > 0 {
+			point := ai.scoutPoints[ai.nextScoutIdx%len(ai.scoutPoints)]
+			ai.nextScoutIdx++
+			ai.scoutSentAt = ai.frame
+			sim.IssueAction(unit.ID.String(), AttackMoveOrder, &point)
+		}
+	}
+}
+
+// attack sends every idle soldier at the last known player position once
+// there are enough of them, then raises the bar for the next wave.
+func (ai *RoachAI) attack(sim *T, units []*Unit) {
+	if ai.KnownTarget == nil {
+		return
+	}
+	var wave []*Unit
+	for _, unit := range units {
+		if ai.roles[unit.ID.String()] == roachRoleSoldier && unit.Action == IdleAction {
+			wave = append(wave, unit)
+		}
+	}
+	if len(wave) < ai.waveSize {
+		return
+	}
+	ids := make([]string, len(wave))
+	for i, unit := range wave {
+		ids[i] = unit.ID.String()
+	}
+	target := *ai.KnownTarget
+	sim.IssueGroupAction(ids, AttackMoveOrder, &target)
+	ai.WavesLaunched++
+	ai.waveSize += RoachAIWaveGrowth
+}
+
+// scoutPoints returns the map corners and center, nudged a few tiles inwards.
+func (s *T) scoutPoints() []image.Point {
+	tm := s.world.TileMap
+	w := tm.Width * tm.TileSize
+	h := tm.Height * tm.TileSize
+	inset := tm.TileSize * 2
+	return []image.Point{
+		{X: inset, Y: inset},
+		{X: w - inset, Y: inset},
+		{X: w / 2, Y: h / 2},
+		{X: w - inset, Y: h - inset},
+		{X: inset, Y: h - inset},
+	}
+}
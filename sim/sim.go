@@ -1,18 +1,19 @@
 package sim
 
 import (
+	"errors"
 	"fmt"
 	"gamejam/eventing"
 	"gamejam/tilemap"
 	"image"
+	"math/rand/v2"
 	"slices"
 	"sync"
+
+	"github.com/google/uuid"
 )
 
 var NearbyDistance = uint(300)
-var UnitSucroseCost = uint16(50)
-var BuildingWoodCost = uint16(50)
-var BuilderMaxDistance = uint(340)
 
 type T struct {
 	EventBus *eventing.EventBus
@@ -21,6 +22,7 @@ type T struct {
 	world    *World
 
 	playerState PlayerState
+	enemyState  PlayerState
 	stateMu     sync.RWMutex
 
 	playerSpawnX, playerSpawnY float64
@@ -28,8 +30,43 @@ type T struct {
 	playerBuildings            []BuildingInterface
 	enemyUnits                 []*Unit
 	enemySpawnX, enemySpawnY   float64
+	unitsByID                  map[uuid.UUID]*Unit
 
 	selectedUnits []*Unit
+
+	controllers []Controller
+
+	// determinism, see replay.go
+	seed      uint64
+	rngSource *rand.PCG
+	rng       *rand.Rand
+	nextID    uint64
+	tick      uint64
+	updating  bool
+	commands  []Command
+	checksums []Checksum
+
+	// map collision taken out by finished bridges, kept for snapshots
+	removedCollisionRects []image.Rectangle
+
+	// harvestable tiles, see resource.go
+	resourceNodes     []*ResourceNode
+	resourceNodeIndex map[image.Point]*ResourceNode // by tile coordinates
+
+	// trigger zones from the map, see triggers.go
+	triggers []*trigger
+
+	// tiles covered by map collision or buildings, nil when it needs rebuilding
+	blockedTiles [][]bool
+	// tiles the tileset says are impassable, minus bridges
+	impassableTiles [][]bool
+	// scratch space for findPath
+	pathGrid *pathGrid
+
+	// spatial indexes for collider queries, see spatial_hash.go
+	unitIndex      *spatialHash[*Unit]
+	buildingIndex  *spatialHash[BuildingInterface]
+	mapObjectIndex *spatialHash[*tilemap.MapObject] // nil when it needs rebuilding
 }
 
 type Collider struct {
@@ -48,8 +85,31 @@ func (s *T) GetPlayerState() PlayerState {
 	return s.playerState
 }
 
+// GetEnemyState returns the resources of the non-player factions.
+func (s *T) GetEnemyState() PlayerState {
+	s.stateMu.RLock()
+	defer s.stateMu.RUnlock()
+	return s.enemyState
+}
+
+// factionState returns the resource pool a faction spends from and delivers
+// to. Callers hold stateMu, controllers and the UI read it from elsewhere.
+func (s *T) factionState(faction uint) *PlayerState {
+	if faction == uint(PlayerFaction) {
+		return &s.playerState
+	}
+	return &s.enemyState
+}
+
 func New(tps int, tileMap *tilemap.Tilemap) *T {
+	return NewSeeded(tps, tileMap, DefaultSeed)
+}
+
+// NewSeeded makes a sim whose randomness comes only from seed, so the same
+// setup and commands always play out the same.
+func NewSeeded(tps int, tileMap *tilemap.Tilemap, seed uint64) *T {
 	bus := eventing.NewEventBus()
+	source := rand.NewPCG(seed, seed)
 
 	sim := &T{
 		EventBus: bus,
@@ -66,29 +126,62 @@ func New(tps int, tileMap *tilemap.Tilemap) *T {
 		//playerWorkers: make([]Worker, 1),
 		playerUnits: make([]*Unit, 0, 10),
 		enemyUnits:  make([]*Unit, 0, 10),
+		unitsByID:   make(map[uuid.UUID]*Unit),
+
+		unitIndex:     newSpatialHash[*Unit](SpatialCellSize),
+		buildingIndex: newSpatialHash[BuildingInterface](SpatialCellSize),
+
+		seed:      seed,
+		rngSource: source,
+		rng:       rand.New(source),
 	}
-	bus.Subscribe("ConstructUnitEvent", sim.HandleConstructUnitEvent)
+	sim.resourceNodes, sim.resourceNodeIndex = newResourceNodes(tileMap)
+	sim.triggers = newTriggers(tileMap)
+	eventing.Subscribe(bus, sim.HandleConstructUnitEvent)
 	return sim
 }
-func (s *T) HandleConstructUnitEvent(event eventing.Event) {
-	hiveID := event.Data.(eventing.ConstructUnitEvent).HiveID
-	success := s.ConstructUnit(hiveID)
-	if !success {
-		s.EventBus.Publish(eventing.Event{
-			Type: "NotEnoughResourcesEvent",
-			Data: eventing.NotEnoughResourcesEvent{
-				ResourceName:     "Sucrose",
-				TargetBeingBuilt: "Ant",
-			},
+func (s *T) HandleConstructUnitEvent(event eventing.ConstructUnitEvent) {
+	hive, err := s.hiveByID(event.HiveID)
+	if err != nil {
+		return
+	}
+	ut := UnitType(event.UnitType)
+	if !slices.Contains(Produces[hive.Type], ut) {
+		return
+	}
+	switch err := s.QueueUnit(event.HiveID, ut); {
+	case errors.Is(err, ErrBuildQueueFull):
+		eventing.Publish(s.EventBus, eventing.NotEnoughResourcesEvent{
+			TargetBeingBuilt: UnitCatalog[ut].Name,
+			Reason:           "The build queue is full",
+		})
+	case errors.Is(err, ErrSupplyCapped):
+		eventing.Publish(s.EventBus, eventing.NotEnoughResourcesEvent{
+			ResourceName:     "Supply",
+			TargetBeingBuilt: UnitCatalog[ut].Name,
+			Reason:           fmt.Sprintf("Not enough supply to make %v\nBuild a hive or nest for more", UnitCatalog[ut].Name),
+		})
+	case err != nil:
+		eventing.Publish(s.EventBus, eventing.NotEnoughResourcesEvent{
+			ResourceName:     s.MissingUnitResource(hive.Faction, ut),
+			TargetBeingBuilt: UnitCatalog[ut].Name,
 		})
 	}
 }
 
 func (s *T) Update() {
+	s.update()
+	s.EventBus.Drain() // once the tick is over, so handlers can give commands
+}
 
+func (s *T) update() {
+	s.updating = true
+	defer func() { s.updating = false }()
+	for _, c := range s.controllers {
+		c.Update(s)
+	}
+	s.updateTargets()
 	for _, unit := range s.playerUnits {
-		//nearestEnemy := findNearestEnemy()
-		//unit.SetNearestEnemy()
 		unit.Update(s)
 	}
 	for _, building := range s.playerBuildings {
@@ -97,39 +190,74 @@ func (s *T) Update() {
 	for _, unit := range s.enemyUnits {
 		unit.Update(s)
 	}
-	// update resource counts
-	// Update unit movement
-	// calculate damage done
-	// process unit removals
-	// process unit additions
+	s.removeDeadUnits()
+	s.removeDestroyedBuildings()
+	s.regrowResources()
+	s.checkTriggers(true)
+
+	s.tick++
+	if s.tick%ChecksumInterval == 0 {
+		s.checksums = append(s.checksums, Checksum{Tick: s.tick, Sum: s.Checksum()})
+	}
 }
 
 func (s *T) RemoveUnit(u *Unit) {
-	s.playerUnits = slices.DeleteFunc(s.playerUnits, func(other *Unit) bool {
+	isUnit := func(other *Unit) bool {
 		return other.ID == u.ID
-	})
+	}
+	s.playerUnits = slices.DeleteFunc(s.playerUnits, isUnit)
+	s.enemyUnits = slices.DeleteFunc(s.enemyUnits, isUnit)
+	s.forgetUnit(u)
+}
+
+// forgetUnit drops the unit from the lookups once it's out of the lists.
+func (s *T) forgetUnit(u *Unit) {
+	delete(s.unitsByID, u.ID)
+	s.unitIndex.Remove(u)
+	u.index = nil
 }
 
+// AddUnit places the unit in the player or enemy list based on its faction.
+// The unit gets a new ID from the sim, read it after adding.
 func (s *T) AddUnit(u *Unit) {
-	s.playerUnits = append(s.playerUnits, u)
+	u.ID = s.newID()
+	if u.Faction == uint(PlayerFaction) {
+		s.playerUnits = append(s.playerUnits, u)
+	} else {
+		s.enemyUnits = append(s.enemyUnits, u)
+	}
+	s.unitsByID[u.ID] = u
+	s.unitIndex.Insert(u, u.Rect)
+	u.index = s.unitIndex // keeps the index current from SetPosition
 }
+
+// AddBuilding gives the building a new ID from the sim and adds it.
 func (s *T) AddBuilding(b BuildingInterface) {
+	b.(baser).base().ID = s.newID()
 	s.playerBuildings = append(s.playerBuildings, b)
+	s.buildingIndex.Insert(b, b.GetRect())
+	s.invalidatePathGrid()
 }
 func (s *T) RemoveBuilding(b BuildingInterface) {
 	s.playerBuildings = slices.DeleteFunc(s.playerBuildings, func(other BuildingInterface) bool {
 		return other.GetID() == b.GetID()
 	})
+	s.buildingIndex.Remove(b)
+	s.invalidatePathGrid()
 }
 
-func (s *T) GetUnitByID(id string) (*Unit, error) {
-	for _, unit := range s.playerUnits {
-		if unit.ID.String() == id {
-			return unit, nil
-		}
+// removeCollisionRect takes a collision rect out of the map, e.g. once a
+// bridge is finished over the chasm.
+func (s *T) removeCollisionRect(rect image.Rectangle) {
+	if s.world.TileMap.RemoveCollisionRect(&rect) {
+		s.removedCollisionRects = append(s.removedCollisionRects, rect)
 	}
-	for _, unit := range s.enemyUnits {
-		if unit.ID.String() == id {
+	s.invalidatePathGrid()
+}
+
+func (s *T) GetUnitByID(id string) (*Unit, error) {
+	if uid, err := uuid.Parse(id); err == nil {
+		if unit, ok := s.unitsByID[uid]; ok {
 			return unit, nil
 		}
 	}
@@ -144,23 +272,26 @@ func (s *T) GetBuildingByID(id string) (BuildingInterface, error) {
 	return nil, fmt.Errorf("unable to find unit with ID:%v", id)
 }
 
-func (s *T) IssueAction(id string, point *image.Point) error {
+// IssueAction replaces whatever the unit is doing and has queued with a new
+// order. Point can be nil for StopOrder and HoldOrder.
+func (s *T) IssueAction(id string, orderType OrderType, point *image.Point) error {
 	unit, err := s.GetUnitByID(id)
 	if err != nil {
 		return err
 	}
-	unit.Destination = point
-	unit.DestinationType = s.DetermineDestinationType(point)
-	// TODO: take passed in ACTION into account as it might matter for some UI buttons
-	switch unit.DestinationType {
-	case EnemyDestination:
-		unit.Action = AttackMovingAction
-	case ResourceDestination:
-		unit.Action = CollectingAction
-	case LocationDestination:
-		unit.Action = AttackMovingAction
-	}
+	return s.IssueOrder(id, orderFor(unit, orderType, point))
+}
 
+// IssueOrder is IssueAction with the order spelled out, e.g. a formation
+// slot and pace.
+func (s *T) IssueOrder(id string, order Order) error {
+	unit, err := s.GetUnitByID(id)
+	if err != nil {
+		return err
+	}
+	s.record(Command{Type: CommandIssueAction, ID: id, Point: clonePoint(&order.Point), Order: &order})
+	unit.clearOrders()
+	unit.startOrder(s, s.resolve(order))
 	return nil
 }
 
@@ -171,8 +302,7 @@ func (s *T) DetermineDestinationType(point *image.Point) DestinationType {
 			return EnemyDestination
 		}
 	}
-	tile := s.world.TileMap.GetTileByPosition(point.X, point.Y)
-	if tile != nil && (tile.Type == "wood" || tile.Type == "sucrose") {
+	if node := s.GetResourceNodeAt(*point); node != nil && !node.Depleted() {
 		return ResourceDestination
 	}
 
@@ -180,7 +310,9 @@ func (s *T) DetermineDestinationType(point *image.Point) DestinationType {
 }
 
 func (s *T) GetAllUnits() []*Unit {
-	return append(s.enemyUnits, s.playerUnits...)
+	all := make([]*Unit, 0, len(s.enemyUnits)+len(s.playerUnits))
+	all = append(all, s.enemyUnits...)
+	return append(all, s.playerUnits...)
 }
 
 func (s *T) GetAllNearbyCollidersHarvesting(x, y int) []*image.Rectangle {
@@ -207,57 +339,94 @@ func (s *T) GetAllNearbyCollidersHarvesting(x, y int) []*image.Rectangle {
 }
 func (s *T) GetAllNearbyColliders(x, y int) []*Collider {
 	var nearbyColliders []*Collider
-	for _, unit := range append(s.enemyUnits, s.playerUnits...) {
-		if unit == nil {
-			continue
-		}
-		distance := unit.DistanceTo(image.Pt(x, y))
-		if distance <= NearbyDistance {
+	point := image.Pt(x, y)
+	area := radiusRect(point, int(NearbyDistance))
+	s.unitIndex.Query(area, func(unit *Unit, rect *image.Rectangle) {
+		if unit.DistanceTo(point) <= NearbyDistance {
 			nearbyColliders = append(nearbyColliders, &Collider{
-				Rect:    unit.Rect,
+				Rect:    rect,
 				OwnerID: unit.ID.String(),
 			})
 		}
-	}
-	for _, building := range s.playerBuildings {
-		distance := building.DistanceTo(image.Pt(x, y))
-		if distance <= NearbyDistance {
+	})
+	s.buildingIndex.Query(area, func(building BuildingInterface, rect *image.Rectangle) {
+		if building.DistanceTo(point) <= NearbyDistance {
 			nearbyColliders = append(nearbyColliders, &Collider{
-				Rect:    building.GetRect(),
+				Rect:    rect,
 				OwnerID: building.GetID().String(),
 			})
 		}
-	}
+	})
 	return nearbyColliders
 }
 
 func (s *T) GetAllCollidersOverlapping(rect *image.Rectangle) []*Collider {
 	var colliders []*Collider
-	for _, unit := range append(s.enemyUnits, s.playerUnits...) {
-		if unit == nil {
-			continue
-		}
-		if unit.Rect.Overlaps(*rect) {
+	s.unitIndex.Query(*rect, func(unit *Unit, unitRect *image.Rectangle) {
+		if unitRect.Overlaps(*rect) {
 			colliders = append(colliders, &Collider{
-				Rect:    unit.Rect,
+				Rect:    unitRect,
 				OwnerID: unit.ID.String(),
 			})
 		}
-	}
-	for _, building := range s.playerBuildings {
+	})
+	s.buildingIndex.Query(*rect, func(building BuildingInterface, buildingRect *image.Rectangle) {
 		if building.GetType() == BuildingTypeBridge { // bridges dont have collision!
-			continue
+			return
 		}
-		if building.GetRect().Overlaps(*rect) {
+		if buildingRect.Overlaps(*rect) {
 			colliders = append(colliders, &Collider{
-				Rect:    building.GetRect(),
+				Rect:    buildingRect,
 				OwnerID: building.GetID().String(),
 			})
 		}
-	}
+	})
 	return colliders
 }
 
+// overlapsCollider reports whether rect hits a building or a unit other than
+// self. It's GetAllCollidersOverlapping without building the list, units
+// check this for every step they take.
+func (s *T) overlapsCollider(rect *image.Rectangle, self *Unit) bool {
+	hit := false
+	s.unitIndex.Query(*rect, func(unit *Unit, unitRect *image.Rectangle) {
+		hit = hit || (unit != self && unitRect.Overlaps(*rect))
+	})
+	if hit {
+		return true
+	}
+	s.buildingIndex.Query(*rect, func(building BuildingInterface, buildingRect *image.Rectangle) {
+		hit = hit || (building.GetType() != BuildingTypeBridge && buildingRect.Overlaps(*rect))
+	})
+	return hit
+}
+
+// GetUnitsNear returns every unit whose center is within radius of point.
+func (s *T) GetUnitsNear(point image.Point, radius int) []*Unit {
+	var units []*Unit
+	s.unitIndex.Query(radiusRect(point, radius), func(unit *Unit, _ *image.Rectangle) {
+		if unit.DistanceTo(point) <= uint(radius) {
+			units = append(units, unit)
+		}
+	})
+	return units
+}
+
+// overlapsMapObject reports whether rect hits any map collision rect.
+func (s *T) overlapsMapObject(rect *image.Rectangle) bool {
+	if s.mapObjectIndex == nil {
+		s.mapObjectIndex = newSpatialHash[*tilemap.MapObject](SpatialCellSize)
+		for _, mo := range s.world.TileMap.MapObjects {
+			s.mapObjectIndex.Insert(mo, mo.Rect)
+		}
+	}
+	hit := false
+	s.mapObjectIndex.Query(*rect, func(_ *tilemap.MapObject, moRect *image.Rectangle) {
+		hit = hit || moRect.Overlaps(*rect)
+	})
+	return hit
+}
+
 func (s *T) GetAllBuildings() []BuildingInterface {
 	return s.playerBuildings
 }
@@ -275,53 +444,63 @@ func (s *T) DetermineUnitOrHiveById(id string) string { // TODO use building.Get
 }
 
 func (s *T) AddWood(amount uint) {
-	s.playerState.Wood += uint16(amount)
+	s.AddResource(uint(PlayerFaction), "wood", amount)
 }
 func (s *T) GetWoodAmount() uint16 {
-	return s.playerState.Wood
+	return s.GetPlayerState().Wood
 }
 func (s *T) AddSucrose(amount uint) {
-	s.playerState.Sucrose += uint16(amount)
+	s.AddResource(uint(PlayerFaction), "sucrose", amount)
 }
 func (s *T) GetSucroseAmount() uint16 {
-	return s.playerState.Sucrose
+	return s.GetPlayerState().Sucrose
 }
 
-func (s *T) ConstructUnit(hiveId string) bool {
-	hive, err := s.GetBuildingByID(hiveId)
-	if err != nil {
-		return false
-	}
-	if s.playerState.Sucrose >= UnitSucroseCost {
-		s.playerState.Sucrose -= UnitSucroseCost
-		hive.AddUnitToBuildQueue()
-		return true
-	} else {
-		return false
+// AddResource credits a delivered resource to the given faction.
+func (s *T) AddResource(faction uint, resourceType string, amount uint) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	state := s.factionState(faction)
+	switch resourceType {
+	case "wood":
+		state.Wood += uint16(amount)
+	case "sucrose":
+		state.Sucrose += uint16(amount)
 	}
 }
 
-func (s *T) ConstructBuilding(target *image.Rectangle, builderID string) bool {
-	if s.playerState.Wood < BuildingWoodCost { // cant afford it
-		return false
-	}
+// ConstructBuilding puts a site for a building of type bt at target, paid
+// for by the builder's faction, and sends the builder to work on it. It fails
+// if they can't afford it or the spot breaks the building's placement rule.
+func (s *T) ConstructBuilding(bt BuildingType, target *image.Rectangle, builderID string) bool {
 	unit, err := s.GetUnitByID(builderID)
 	if err != nil {
 		return false // todo print builder doesnt exist
 	}
+	if !s.constructBuilding(bt, *target, unit) {
+		return false
+	}
+	recorded := *target
+	s.record(Command{Type: CommandConstructBuilding, ID: builderID, Target: &recorded, Building: &bt})
+	return true
+}
 
-	targetCenter := image.Pt(
-		target.Min.X+(target.Dx()/2),
-		target.Min.Y+(target.Dy()/2),
-	)
-
-	if unit.DistanceTo(targetCenter) > BuilderMaxDistance { // todo min should be center!
+// constructBuilding is ConstructBuilding without recording, for orders that
+// were recorded when they were given.
+func (s *T) constructBuilding(bt BuildingType, target image.Rectangle, unit *Unit) bool {
+	if s.MissingResource(unit.Faction, bt) != "" || s.CanPlaceBuilding(bt, target) != nil {
 		return false
-	} else {
-		// actually build the thing
-		s.playerState.Wood -= BuildingWoodCost
-		inConstructionBuilding := NewInConstructionBuilding(target.Min.X, target.Min.Y, BuildingTypeBridge) // always bridge for now, but easy to change
-		s.playerBuildings = append(s.playerBuildings, inConstructionBuilding)
-		return true
 	}
+
+	spec := BuildingCatalog[bt]
+	s.stateMu.Lock()
+	state := s.factionState(unit.Faction)
+	state.Wood -= spec.WoodCost
+	state.Sucrose -= spec.SucroseCost
+	s.stateMu.Unlock()
+	inConstructionBuilding := NewInConstructionBuilding(target.Min.X, target.Min.Y, bt)
+	inConstructionBuilding.SetFaction(unit.Faction)
+	s.AddBuilding(inConstructionBuilding)
+	unit.startBuilding(inConstructionBuilding)
+	return true
 }
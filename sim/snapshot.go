@@ -0,0 +1,360 @@
+package sim
+
+import (
+	"fmt"
+	"image"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// SnapshotVersion is bumped whenever Snapshot changes shape, old saves are
+// rejected instead of half loading.
+const SnapshotVersion = 11
+
+// Snapshot is everything needed to put a sim back the way it was. It's meant
+// to be encoded as JSON and restored into a fresh sim made for the same map.
+// Paths and enemy targets aren't saved, units replan and re-acquire them on
+// the next tick. The command log is, so a replay can still be taken from a
+// game that was saved and loaded.
+type Snapshot struct {
+	Version               int
+	Tick                  uint64
+	Seed                  uint64
+	NextID                uint64
+	RNG                   []byte // PCG state
+	PlayerState           PlayerState
+	EnemyState            PlayerState
+	Units                 []UnitSnapshot
+	Buildings             []BuildingSnapshot
+	RemovedCollisionRects []image.Rectangle
+	ResourceNodes         []ResourceNodeSnapshot `json:",omitempty"` // only the ones that were harvested
+	FiredTriggers         []int                  `json:",omitempty"` // map object IDs
+	Controllers           []ControllerSnapshot   `json:",omitempty"` // in the order they were added
+	Commands              []Command              `json:",omitempty"`
+	Checksums             []Checksum             `json:",omitempty"`
+}
+
+type ResourceNodeSnapshot struct {
+	Tile      image.Point
+	Remaining uint
+}
+
+type UnitSnapshot struct {
+	ID                    uuid.UUID
+	Type                  UnitType
+	Faction               uint
+	Rect                  image.Rectangle
+	MovingAngle           float64
+	Stats                 UnitStats
+	Action                Action
+	Destination           *image.Point
+	DestinationType       DestinationType
+	NearestHomeID         *uuid.UUID `json:",omitempty"`
+	LastResourcePos       *image.Point
+	StuckFrames           int
+	StuckSidestepAttempts int
+	AttackCooldown        uint
+	Orders                []Order       `json:",omitempty"`
+	SiteID                *uuid.UUID    `json:",omitempty"` // building site the unit works on
+	Pace                  uint          `json:",omitempty"`
+	Path                  []image.Point `json:",omitempty"`
+	PathGoal              *image.Point  `json:",omitempty"`
+	NearestEnemyID        *uuid.UUID    `json:",omitempty"`
+}
+
+type BuildingSnapshot struct {
+	ID              uuid.UUID
+	Type            BuildingType
+	Faction         uint
+	Rect            image.Rectangle
+	ProgressCurrent uint
+	ProgressMax     uint
+	HPCur           uint
+
+	// hives only
+	BuildQueue      []UnitType  `json:",omitempty"`
+	UnitContructing bool        `json:",omitempty"`
+	Rally           *RallyPoint `json:",omitempty"`
+	// in construction buildings only
+	TargetBuilding BuildingType `json:",omitempty"`
+	// defensive mounds only
+	AttackCooldown uint `json:",omitempty"`
+}
+
+// baser lets snapshots reach the shared Building of any building type.
+type baser interface {
+	base() *Building
+}
+
+func (b *Building) base() *Building { return b }
+
+func (s *T) Snapshot() *Snapshot {
+	rng, _ := s.rngSource.MarshalBinary() // never fails for PCG
+	s.stateMu.RLock()
+	snap := &Snapshot{
+		Version:               SnapshotVersion,
+		Tick:                  s.tick,
+		Seed:                  s.seed,
+		NextID:                s.nextID,
+		RNG:                   rng,
+		PlayerState:           s.playerState,
+		EnemyState:            s.enemyState,
+		RemovedCollisionRects: slices.Clone(s.removedCollisionRects),
+		Commands:              slices.Clone(s.commands),
+		Checksums:             slices.Clone(s.checksums),
+	}
+	s.stateMu.RUnlock()
+
+	for _, controller := range s.controllers {
+		cs := ControllerSnapshot{Faction: controller.GetFaction()}
+		if ai, ok := controller.(*RoachAI); ok {
+			cs.RoachAI = ai.snapshot()
+		}
+		snap.Controllers = append(snap.Controllers, cs)
+	}
+
+	for _, node := range s.resourceNodes {
+		if node.Remaining != node.Max {
+			snap.ResourceNodes = append(snap.ResourceNodes, ResourceNodeSnapshot{Tile: node.Tile, Remaining: node.Remaining})
+		}
+	}
+
+	for _, t := range s.GetFiredTriggers() {
+		snap.FiredTriggers = append(snap.FiredTriggers, t.ID)
+	}
+
+	for _, unit := range s.GetAllUnits() {
+		us := UnitSnapshot{
+			ID:                    unit.ID,
+			Type:                  unit.Type,
+			Faction:               unit.Faction,
+			Rect:                  *unit.Rect,
+			MovingAngle:           unit.MovingAngle,
+			Stats:                 *unit.Stats,
+			Action:                unit.Action,
+			Destination:           clonePoint(unit.Destination),
+			DestinationType:       unit.DestinationType,
+			LastResourcePos:       clonePoint(unit.LastResourcePos),
+			StuckFrames:           unit.StuckFrames,
+			StuckSidestepAttempts: unit.StuckSidestepAttempts,
+			AttackCooldown:        unit.AttackCooldown,
+			Orders:                slices.Clone(unit.Orders),
+			Pace:                  unit.pace,
+			Path:                  slices.Clone(unit.Path),
+			PathGoal:              clonePoint(unit.pathGoal),
+		}
+		if unit.NearestEnemy != nil {
+			id := unit.NearestEnemy.ID
+			us.NearestEnemyID = &id
+		}
+		if unit.NearestHome != nil {
+			id := unit.NearestHome.GetID()
+			us.NearestHomeID = &id
+		}
+		if unit.site != nil {
+			id := unit.site.GetID()
+			us.SiteID = &id
+		}
+		snap.Units = append(snap.Units, us)
+	}
+
+	for _, building := range s.playerBuildings {
+		b := building.(baser).base()
+		bs := BuildingSnapshot{
+			ID:              b.ID,
+			Type:            b.Type,
+			Faction:         b.Faction,
+			Rect:            *b.Rect,
+			ProgressCurrent: b.ProgressCurrent,
+			ProgressMax:     b.ProgressMax,
+			HPCur:           b.HPCur,
+		}
+		switch concrete := building.(type) {
+		case *Hive:
+			for _, queued := range concrete.buildQueue.Items() {
+				bs.BuildQueue = append(bs.BuildQueue, queued.Type)
+			}
+			bs.UnitContructing = concrete.UnitContructing
+			if concrete.rally != nil {
+				rally := *concrete.rally
+				bs.Rally = &rally
+			}
+		case *InConstructionBuilding:
+			bs.TargetBuilding = concrete.targetBuilding
+		case *DefensiveMound:
+			bs.AttackCooldown = concrete.AttackCooldown
+		}
+		snap.Buildings = append(snap.Buildings, bs)
+	}
+	return snap
+}
+
+// Restore replaces every unit, building and resource in the sim with the
+// snapshot's. The sim should be fresh from New with the snapshot's map, and
+// have the same controllers added as the one that was saved.
+func (s *T) Restore(snap *Snapshot) error {
+	if snap.Version != SnapshotVersion {
+		return fmt.Errorf("snapshot version %d, expected %d", snap.Version, SnapshotVersion)
+	}
+	if len(snap.Controllers) != len(s.controllers) {
+		return fmt.Errorf("snapshot has %d controllers, sim has %d", len(snap.Controllers), len(s.controllers))
+	}
+	for i, cs := range snap.Controllers {
+		controller := s.controllers[i]
+		if cs.Faction != controller.GetFaction() {
+			return fmt.Errorf("controller %d: snapshot is for faction %d, sim has faction %d", i, cs.Faction, controller.GetFaction())
+		}
+		if ai, ok := controller.(*RoachAI); ok {
+			if cs.RoachAI == nil {
+				return fmt.Errorf("controller %d: snapshot has no RoachAI state", i)
+			}
+			ai.restore(cs.RoachAI)
+		}
+	}
+
+	for _, unit := range s.GetAllUnits() {
+		s.RemoveUnit(unit)
+	}
+	for _, building := range slices.Clone(s.playerBuildings) {
+		s.RemoveBuilding(building)
+	}
+
+	s.stateMu.Lock()
+	s.playerState = snap.PlayerState
+	s.enemyState = snap.EnemyState
+	s.stateMu.Unlock()
+
+	for _, rect := range snap.RemovedCollisionRects {
+		s.removeCollisionRect(rect)
+	}
+
+	for _, node := range s.resourceNodes {
+		node.Remaining = node.Max
+	}
+	for i, ns := range snap.ResourceNodes {
+		node, ok := s.resourceNodeIndex[ns.Tile]
+		if !ok {
+			return fmt.Errorf("resource node %d: no resource at tile %v", i, ns.Tile)
+		}
+		node.Remaining = min(ns.Remaining, node.Max)
+	}
+
+	for i, bs := range snap.Buildings {
+		var building BuildingInterface
+		switch bs.Type {
+		case BuildingTypeHive:
+			building = NewHive()
+		case BuildingTypeRoachHive:
+			building = NewRoachHive()
+		case BuildingTypeInConstruction:
+			if _, ok := BuildingCatalog[bs.TargetBuilding]; !ok {
+				return fmt.Errorf("building %d: can't construct type %d", i, bs.TargetBuilding)
+			}
+			building = NewInConstructionBuilding(bs.Rect.Min.X, bs.Rect.Min.Y, bs.TargetBuilding)
+		default:
+			spec, ok := BuildingCatalog[bs.Type]
+			if !ok {
+				return fmt.Errorf("building %d: unknown type %d", i, bs.Type)
+			}
+			building = spec.New()
+		}
+		b := building.(baser).base()
+		b.Faction = bs.Faction
+		b.SetPosition(bs.Rect.Min.X, bs.Rect.Min.Y, bs.Rect.Dx(), bs.Rect.Dy())
+		b.ProgressCurrent = bs.ProgressCurrent
+		b.HPCur = min(bs.HPCur, b.HPMax)
+		b.ProgressMax = bs.ProgressMax
+		if hive, ok := building.(*Hive); ok {
+			for _, unitType := range bs.BuildQueue {
+				if _, ok := UnitCatalog[unitType]; !ok {
+					return fmt.Errorf("building %d: can't make unit type %d", i, unitType)
+				}
+				hive.buildQueue.Enqueue(newProductionOrder(unitType))
+			}
+			hive.UnitContructing = bs.UnitContructing
+			if bs.Rally != nil {
+				rally := *bs.Rally
+				hive.rally = &rally
+			}
+		}
+		if mound, ok := building.(*DefensiveMound); ok {
+			mound.AttackCooldown = bs.AttackCooldown
+		}
+		s.AddBuilding(building)
+		b.ID = bs.ID // AddBuilding hands out a fresh one
+	}
+
+	for _, us := range snap.Units {
+		unit := NewUnitOfType(us.Type)
+		unit.Faction = us.Faction
+		unit.Rect.Max = unit.Rect.Min.Add(us.Rect.Size())
+		unit.SetPosition(&image.Point{X: us.Rect.Min.X, Y: us.Rect.Min.Y})
+		unit.MovingAngle = us.MovingAngle
+		stats := us.Stats
+		unit.Stats = &stats
+		unit.Action = us.Action
+		unit.Destination = clonePoint(us.Destination)
+		if unit.Destination == nil {
+			unit.Destination = &image.Point{}
+		}
+		unit.DestinationType = us.DestinationType
+		unit.LastResourcePos = clonePoint(us.LastResourcePos)
+		unit.StuckFrames = us.StuckFrames
+		unit.StuckSidestepAttempts = us.StuckSidestepAttempts
+		unit.AttackCooldown = us.AttackCooldown
+		unit.Orders = slices.Clone(us.Orders)
+		unit.pace = us.Pace
+		unit.Path = slices.Clone(us.Path)
+		unit.pathGoal = clonePoint(us.PathGoal)
+		if us.NearestHomeID != nil {
+			home, err := s.GetBuildingByID(us.NearestHomeID.String())
+			if err == nil {
+				unit.NearestHome = home
+			}
+		}
+		if us.SiteID != nil {
+			building, err := s.GetBuildingByID(us.SiteID.String())
+			if err == nil {
+				unit.site, _ = building.(*InConstructionBuilding)
+			}
+		}
+		s.AddUnit(unit)
+		delete(s.unitsByID, unit.ID)
+		unit.ID = us.ID
+		s.unitsByID[unit.ID] = unit
+	}
+	for _, us := range snap.Units {
+		if us.NearestEnemyID == nil {
+			continue
+		}
+		unit, err := s.GetUnitByID(us.ID.String())
+		if err != nil {
+			continue
+		}
+		unit.NearestEnemy, _ = s.GetUnitByID(us.NearestEnemyID.String())
+	}
+
+	for _, t := range s.triggers {
+		t.fired = slices.Contains(snap.FiredTriggers, t.ID)
+	}
+	s.checkTriggers(false) // whoever stands in a trigger was already there
+
+	s.tick = snap.Tick
+	s.seed = snap.Seed
+	s.nextID = snap.NextID
+	s.commands = slices.Clone(snap.Commands)
+	s.checksums = slices.Clone(snap.Checksums)
+	if err := s.rngSource.UnmarshalBinary(snap.RNG); err != nil {
+		return fmt.Errorf("restoring rng: %w", err)
+	}
+	return nil
+}
+
+func clonePoint(p *image.Point) *image.Point {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	return &clone
+}
@@ -0,0 +1,143 @@
+package sim
+
+import "image"
+
+// SpatialCellSize is the width and height of a spatial hash cell in world
+// pixels. Two tiles keeps most units in one to four cells.
+var SpatialCellSize = 256
+
+type spatialEntry[V comparable] struct {
+	value V
+	rect  *image.Rectangle
+	cells image.Rectangle // inclusive range of cells the rect was inserted into
+}
+
+// spatialHash is a uniform grid index over rects so collision and proximity
+// queries only look at things in the cells they touch instead of everything
+// in the sim. Rects are stored by pointer, call update after one moves.
+type spatialHash[V comparable] struct {
+	cellSize int
+	cells    map[image.Point][]*spatialEntry[V]
+	entries  map[V]*spatialEntry[V]
+}
+
+func newSpatialHash[V comparable](cellSize int) *spatialHash[V] {
+	return &spatialHash[V]{
+		cellSize: cellSize,
+		cells:    make(map[image.Point][]*spatialEntry[V]),
+		entries:  make(map[V]*spatialEntry[V]),
+	}
+}
+
+// cellRange returns the inclusive range of cells a rect touches.
+func (h *spatialHash[V]) cellRange(rect image.Rectangle) image.Rectangle {
+	return image.Rectangle{
+		Min: image.Pt(floorDiv(rect.Min.X, h.cellSize), floorDiv(rect.Min.Y, h.cellSize)),
+		Max: image.Pt(floorDiv(rect.Max.X-1, h.cellSize), floorDiv(rect.Max.Y-1, h.cellSize)),
+	}
+}
+
+func (h *spatialHash[V]) Insert(value V, rect *image.Rectangle) {
+	if _, ok := h.entries[value]; ok {
+		h.Update(value)
+		return
+	}
+	entry := &spatialEntry[V]{value: value, rect: rect, cells: h.cellRange(*rect)}
+	h.entries[value] = entry
+	h.link(entry)
+}
+
+func (h *spatialHash[V]) Remove(value V) {
+	entry, ok := h.entries[value]
+	if !ok {
+		return
+	}
+	h.unlink(entry)
+	delete(h.entries, value)
+}
+
+// Update moves the value to the cells its rect covers now. It's cheap when
+// the value stayed inside the same cells, which is most frames.
+func (h *spatialHash[V]) Update(value V) {
+	entry, ok := h.entries[value]
+	if !ok {
+		return
+	}
+	cells := h.cellRange(*entry.rect)
+	if cells == entry.cells {
+		return
+	}
+	h.unlink(entry)
+	entry.cells = cells
+	h.link(entry)
+}
+
+// Query calls fn once for every value whose cells touch area. Callers still
+// need to check the exact rect, this only narrows things down.
+func (h *spatialHash[V]) Query(area image.Rectangle, fn func(value V, rect *image.Rectangle)) {
+	if area.Empty() {
+		return
+	}
+	cells := h.cellRange(area)
+	for cx := cells.Min.X; cx <= cells.Max.X; cx++ {
+		for cy := cells.Min.Y; cy <= cells.Max.Y; cy++ {
+			for _, entry := range h.cells[image.Pt(cx, cy)] {
+				// an entry spanning several cells is only reported from the
+				// first cell it shares with the query, so no seen set is needed
+				if cx != max(entry.cells.Min.X, cells.Min.X) || cy != max(entry.cells.Min.Y, cells.Min.Y) {
+					continue
+				}
+				fn(entry.value, entry.rect)
+			}
+		}
+	}
+}
+
+func (h *spatialHash[V]) Len() int { return len(h.entries) }
+
+func (h *spatialHash[V]) link(entry *spatialEntry[V]) {
+	for cx := entry.cells.Min.X; cx <= entry.cells.Max.X; cx++ {
+		for cy := entry.cells.Min.Y; cy <= entry.cells.Max.Y; cy++ {
+			key := image.Pt(cx, cy)
+			h.cells[key] = append(h.cells[key], entry)
+		}
+	}
+}
+
+func (h *spatialHash[V]) unlink(entry *spatialEntry[V]) {
+	for cx := entry.cells.Min.X; cx <= entry.cells.Max.X; cx++ {
+		for cy := entry.cells.Min.Y; cy <= entry.cells.Max.Y; cy++ {
+			key := image.Pt(cx, cy)
+			cell := h.cells[key]
+			for i, other := range cell {
+				if other == entry {
+					cell[i] = cell[len(cell)-1]
+					cell[len(cell)-1] = nil
+					cell = cell[:len(cell)-1]
+					break
+				}
+			}
+			if len(cell) == 0 {
+				delete(h.cells, key)
+			} else {
+				h.cells[key] = cell
+			}
+		}
+	}
+}
+
+// floorDiv rounds towards negative infinity so rects left of or above the
+// map still land in their own cells.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// radiusRect is the square around point that contains every rect with a
+// point within radius of it.
+func radiusRect(point image.Point, radius int) image.Rectangle {
+	return image.Rect(point.X-radius, point.Y-radius, point.X+radius+1, point.Y+radius+1)
+}
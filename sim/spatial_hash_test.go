@@ -0,0 +1,241 @@
+package sim
+
+import (
+	"fmt"
+	"gamejam/tilemap"
+	"image"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+var benchSizes = []int{100, 1000, 5000}
+
+type testEntry struct{ id int }
+
+// queryAll collects everything Query reports for area, failing on repeats.
+func queryAll(t *testing.T, h *spatialHash[*testEntry], area image.Rectangle) []int {
+	t.Helper()
+	var ids []int
+	h.Query(area, func(e *testEntry, _ *image.Rectangle) {
+		if slices.Contains(ids, e.id) {
+			t.Errorf("query %v reported %d twice", area, e.id)
+		}
+		ids = append(ids, e.id)
+	})
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSpatialHashInsertQuery(t *testing.T) {
+	h := newSpatialHash[*testEntry](100)
+	small := &testEntry{1}
+	big := &testEntry{2} // spans four cells
+	negative := &testEntry{3}
+	h.Insert(small, &image.Rectangle{Min: image.Pt(10, 10), Max: image.Pt(20, 20)})
+	h.Insert(big, &image.Rectangle{Min: image.Pt(50, 50), Max: image.Pt(150, 150)})
+	h.Insert(negative, &image.Rectangle{Min: image.Pt(-30, -30), Max: image.Pt(-10, -10)})
+	if h.Len() != 3 {
+		t.Fatalf("Len is %d, want 3", h.Len())
+	}
+
+	for _, tc := range []struct {
+		area image.Rectangle
+		want []int
+	}{
+		{image.Rect(0, 0, 100, 100), []int{1, 2}},
+		{image.Rect(0, 0, 300, 300), []int{1, 2}},
+		{image.Rect(120, 120, 130, 130), []int{2}},
+		{image.Rect(-50, -50, 0, 0), []int{3}},
+		{image.Rect(-50, -50, 300, 300), []int{1, 2, 3}},
+		{image.Rect(500, 500, 600, 600), nil},
+		{image.Rect(10, 10, 10, 10), nil}, // empty
+	} {
+		if got := queryAll(t, h, tc.area); !slices.Equal(got, tc.want) {
+			t.Errorf("query %v got %v, want %v", tc.area, got, tc.want)
+		}
+	}
+}
+
+func TestSpatialHashUpdateRemove(t *testing.T) {
+	h := newSpatialHash[*testEntry](100)
+	e := &testEntry{1}
+	rect := &image.Rectangle{Min: image.Pt(10, 10), Max: image.Pt(20, 20)}
+	h.Insert(e, rect)
+
+	// rects are held by pointer, Update picks up the move
+	*rect = rect.Add(image.Pt(300, 0))
+	h.Update(e)
+	if got := queryAll(t, h, image.Rect(0, 0, 100, 100)); len(got) != 0 {
+		t.Errorf("still in its old cell: %v", got)
+	}
+	if got := queryAll(t, h, image.Rect(300, 0, 400, 100)); !slices.Equal(got, []int{1}) {
+		t.Errorf("not in its new cell: %v", got)
+	}
+
+	// inserting again is an update, not a second entry
+	*rect = rect.Add(image.Pt(0, 300))
+	h.Insert(e, rect)
+	if h.Len() != 1 {
+		t.Errorf("Len is %d after inserting twice, want 1", h.Len())
+	}
+	if got := queryAll(t, h, image.Rect(0, 0, 1000, 1000)); !slices.Equal(got, []int{1}) {
+		t.Errorf("got %v after inserting twice", got)
+	}
+
+	h.Remove(e)
+	h.Remove(e) // twice is fine
+	h.Update(e) // so is updating something that's gone
+	if h.Len() != 0 || len(h.cells) != 0 {
+		t.Errorf("%d entries and %d cells left after Remove", h.Len(), len(h.cells))
+	}
+	if got := queryAll(t, h, image.Rect(-1000, -1000, 1000, 1000)); len(got) != 0 {
+		t.Errorf("got %v after Remove", got)
+	}
+}
+
+// TestSpatialHashMatchesScan moves random rects around and compares every
+// query with checking them all.
+func TestSpatialHashMatchesScan(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	h := newSpatialHash[*testEntry](64)
+	rects := make([]*image.Rectangle, 200)
+	entries := make([]*testEntry, len(rects))
+	for i := range rects {
+		rects[i] = randomRect(rng, 1000, 80)
+		entries[i] = &testEntry{i}
+		h.Insert(entries[i], rects[i])
+	}
+	for round := range 50 {
+		for i, rect := range rects {
+			if rng.IntN(3) == 0 {
+				*rect = rect.Add(image.Pt(rng.IntN(81)-40, rng.IntN(81)-40))
+				h.Update(entries[i])
+			}
+		}
+		area := *randomRect(rng, 1000, 300)
+		var want []int
+		for i, rect := range rects {
+			if rect.Overlaps(area) {
+				want = append(want, i)
+			}
+		}
+		var got []int
+		for _, id := range queryAll(t, h, area) {
+			if rects[id].Overlaps(area) {
+				got = append(got, id)
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("round %d: query %v got %v, want %v", round, area, got, want)
+		}
+	}
+}
+
+func randomRect(rng *rand.Rand, world, maxSize int) *image.Rectangle {
+	min := image.Pt(rng.IntN(world)-world/10, rng.IntN(world)-world/10)
+	return &image.Rectangle{Min: min, Max: min.Add(image.Pt(1+rng.IntN(maxSize), 1+rng.IntN(maxSize)))}
+}
+
+// unitsHash fills a hash with n unit sized rects spread over a square world
+// with about eight tiles of room each, like a busy map.
+func unitsHash(n int) (*spatialHash[*testEntry], []*testEntry, []*image.Rectangle, int) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	world := int(math.Sqrt(float64(n*8))) * 128
+	h := newSpatialHash[*testEntry](SpatialCellSize)
+	entries := make([]*testEntry, n)
+	rects := make([]*image.Rectangle, n)
+	for i := range entries {
+		min := image.Pt(rng.IntN(world), rng.IntN(world))
+		entries[i] = &testEntry{i}
+		rects[i] = &image.Rectangle{Min: min, Max: min.Add(image.Pt(128, 128))}
+		h.Insert(entries[i], rects[i])
+	}
+	return h, entries, rects, world
+}
+
+// BenchmarkUpdate moves every rect a unit's step and updates it, one op is
+// a whole tick's worth.
+func BenchmarkUpdate(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("units=%d", n), func(b *testing.B) {
+			h, entries, rects, _ := unitsHash(n)
+			step := image.Pt(10, 0)
+			b.ResetTimer()
+			for i := range b.N {
+				if i%50 == 0 {
+					step = step.Mul(-1) // back and forth so they stay on the map
+				}
+				for j, e := range entries {
+					*rects[j] = rects[j].Add(step)
+					h.Update(e)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkQuery looks around every rect once within a unit's sight range,
+// one op is a whole tick's worth.
+func BenchmarkQuery(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("units=%d", n), func(b *testing.B) {
+			h, _, rects, _ := unitsHash(n)
+			found := 0
+			b.ResetTimer()
+			for range b.N {
+				for _, rect := range rects {
+					h.Query(rect.Inset(-300), func(_ *testEntry, _ *image.Rectangle) { found++ })
+				}
+			}
+		})
+	}
+}
+
+// openMap is a w by h tile map of plain walkable ground.
+func openMap(w, h int) *tilemap.Tilemap {
+	tm := &tilemap.Tilemap{Width: w, Height: h, TileSize: 128, Tiles: make([][]*tilemap.Tile, w)}
+	for x := range w {
+		tm.Tiles[x] = make([]*tilemap.Tile, h)
+		for y := range h {
+			rect := image.Rect(x*128, y*128, (x+1)*128, (y+1)*128)
+			tm.Tiles[x][y] = &tilemap.Tile{Type: "grass", Walkable: true, MoveCost: 1, Coordinates: &image.Point{X: x, Y: y}, Rect: &rect}
+		}
+	}
+	return tm
+}
+
+// BenchmarkTick runs the whole sim with n ants wandering around a map with
+// about eight tiles of room each, a third of them roaches they fight when
+// they meet. Idle units are sent somewhere new every tick.
+func BenchmarkTick(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("units=%d", n), func(b *testing.B) {
+			side := int(math.Sqrt(float64(n * 8)))
+			s := NewSeeded(60, openMap(side, side), testSeed)
+			rng := rand.New(rand.NewPCG(1, 2))
+			for i := range n {
+				u := NewDefaultAnt()
+				if i%3 == 0 {
+					u = NewDefaultRoach()
+					u.Faction = uint(EnemyFaction)
+				}
+				u.Stats.HPMax = math.MaxUint32 // nobody dies, so every tick has n units
+				u.Stats.HPCur = u.Stats.HPMax
+				u.SetTilePosition(rng.IntN(side), rng.IntN(side))
+				s.AddUnit(u)
+			}
+			b.ResetTimer()
+			for range b.N {
+				for _, u := range s.GetAllUnits() {
+					if u.Action == IdleAction {
+						target := image.Pt(rng.IntN(side*128), rng.IntN(side*128))
+						s.IssueAction(u.ID.String(), AttackMoveOrder, &target)
+					}
+				}
+				s.Update()
+			}
+		})
+	}
+}
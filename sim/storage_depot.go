@@ -0,0 +1,12 @@
+package sim
+
+// StorageDepot is somewhere to drop off resources closer to where they are,
+// see DropOffBuildings.
+type StorageDepot struct {
+	*Building
+}
+
+func NewStorageDepot() BuildingInterface {
+	building := NewBuilding(0, 0, TileDimensions, TileDimensions, 0, BuildingTypeStorageDepot, 0)
+	return &StorageDepot{Building: building}
+}
@@ -0,0 +1,40 @@
+package sim
+
+import "errors"
+
+// SupplyProvided is how much supply each finished building gives its
+// faction. Units use up UnitSpec.Supply of it from when they're queued.
+var SupplyProvided = map[BuildingType]uint16{
+	BuildingTypeHive:      10,
+	BuildingTypeRoachHive: 20,
+	BuildingTypeNest:      8,
+}
+
+// ErrSupplyCapped is returned by QueueUnit when the faction doesn't have the
+// supply left for another unit.
+var ErrSupplyCapped = errors.New("supply capped")
+
+// Supply returns how much supply the faction's units and queued units use,
+// and how much its buildings provide. Units that aren't in UnitCatalog, like
+// the royals, are free.
+func (s *T) Supply(faction uint) (used, provided uint16) {
+	for _, unit := range s.GetUnitsByFaction(faction) {
+		if spec, ok := UnitCatalog[unit.Type]; ok && !unit.IsDead() {
+			used += spec.Supply
+		}
+	}
+	for _, building := range s.GetBuildingsByFaction(faction) {
+		provided += SupplyProvided[building.GetType()]
+		if hive, ok := building.(*Hive); ok {
+			for _, order := range hive.buildQueue.Items() {
+				used += order.Spec.Supply
+			}
+		}
+	}
+	return used, provided
+}
+
+// GetPlayerSupply is Supply for the player.
+func (s *T) GetPlayerSupply() (used, provided uint16) {
+	return s.Supply(uint(PlayerFaction))
+}
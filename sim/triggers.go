@@ -0,0 +1,149 @@
+package sim
+
+import (
+	"fmt"
+	"gamejam/eventing"
+	"gamejam/tilemap"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// UnitKinds are the unit names trigger spawns and levels use.
+var UnitKinds = map[string]UnitType{
+	"ant":         UnitTypeDefaultAnt,
+	"royal_ant":   UnitTypeRoyalAnt,
+	"roach":       UnitTypeDefaultRoach,
+	"royal_roach": UnitTypeRoyalRoach,
+	"soldier_ant": UnitTypeSoldierAnt,
+}
+
+// UnitKind is the UnitKinds name of t, e.g. for summaries.
+func UnitKind(t UnitType) string {
+	for kind, unitType := range UnitKinds {
+		if unitType == t {
+			return kind
+		}
+	}
+	return ""
+}
+
+// triggerFactions maps tilemap.TriggerFactions to factions, -1 is anyone.
+var triggerFactions = map[string]int{
+	"player":  PlayerFaction,
+	"enemy":   EnemyFaction,
+	"neutral": NeutralFaction,
+	"any":     -1,
+}
+
+// trigger is a map trigger zone and who's standing in it.
+type trigger struct {
+	*tilemap.MapTrigger
+	fired  bool
+	inside map[*Unit]bool
+}
+
+// TriggerSpawn is what a spawn trigger's script asks for.
+type TriggerSpawn struct {
+	Kind  UnitType
+	Count int
+	Tile  image.Point
+}
+
+// ParseTriggerSpawn reads a spawn trigger's script, "<kind> <count> <tile x>
+// <tile y>".
+func ParseTriggerSpawn(script string) (TriggerSpawn, error) {
+	fields := strings.Fields(script)
+	if len(fields) != 4 {
+		return TriggerSpawn{}, fmt.Errorf("spawn %q: expected <kind> <count> <tile x> <tile y>", script)
+	}
+	kind, ok := UnitKinds[fields[0]]
+	if !ok {
+		return TriggerSpawn{}, fmt.Errorf("spawn %q: unknown unit kind %q", script, fields[0])
+	}
+	var numbers [3]int
+	for i, field := range fields[1:] {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return TriggerSpawn{}, fmt.Errorf("spawn %q: %q isn't a whole number", script, field)
+		}
+		numbers[i] = n
+	}
+	return TriggerSpawn{Kind: kind, Count: numbers[0], Tile: image.Pt(numbers[1], numbers[2])}, nil
+}
+
+func newTriggers(tileMap *tilemap.Tilemap) []*trigger {
+	var triggers []*trigger
+	for _, mt := range tileMap.MapTriggers {
+		triggers = append(triggers, &trigger{MapTrigger: mt, inside: make(map[*Unit]bool)})
+	}
+	return triggers
+}
+
+// checkTriggers fires every trigger a unit of the right faction walked into
+// this tick. Units already inside don't fire it again until they leave.
+func (s *T) checkTriggers(fire bool) {
+	for _, t := range s.triggers {
+		faction, ok := triggerFactions[t.Faction]
+		if !ok {
+			continue
+		}
+		inside := make(map[*Unit]bool)
+		var entered *Unit
+		for _, unit := range s.GetAllUnits() {
+			if unit.IsDead() || (faction >= 0 && unit.Faction != uint(faction)) || !unit.Rect.Overlaps(*t.Rect) {
+				continue
+			}
+			inside[unit] = true
+			if !t.inside[unit] && entered == nil {
+				entered = unit
+			}
+		}
+		t.inside = inside
+		if fire && entered != nil && !(t.Once && t.fired) {
+			s.fireTrigger(t, entered)
+		}
+	}
+}
+
+func (s *T) fireTrigger(t *trigger, unit *Unit) {
+	t.fired = true
+	if t.OnEnter == "spawn" {
+		s.spawnFromTrigger(t)
+	}
+	eventing.PublishDeferred(s.EventBus, eventing.TriggerEnteredEvent{
+		TriggerID: t.ID,
+		Name:      t.Name,
+		OnEnter:   t.OnEnter,
+		Script:    t.Script,
+		UnitID:    unit.ID.String(),
+	})
+}
+
+// spawnFromTrigger adds the trigger's enemies in a row from its tile. It's
+// done here rather than by whoever listens so replays spawn them too.
+func (s *T) spawnFromTrigger(t *trigger) {
+	spawn, err := ParseTriggerSpawn(t.Script)
+	if err != nil {
+		return // mapcheck and level validation report it
+	}
+	for i := 0; i < spawn.Count; i++ {
+		unit := NewUnitOfType(spawn.Kind)
+		unit.Faction = uint(EnemyFaction)
+		pos := TileToWorld(spawn.Tile.X, spawn.Tile.Y, s.world.TileMap.TileSize)
+		pos.X += i * (unit.Rect.Dx() + FormationSpacing)
+		unit.SetPosition(&pos)
+		s.AddUnit(unit)
+	}
+}
+
+// GetFiredTriggers returns the triggers that went off at least once.
+func (s *T) GetFiredTriggers() []*tilemap.MapTrigger {
+	var fired []*tilemap.MapTrigger
+	for _, t := range s.triggers {
+		if t.fired {
+			fired = append(fired, t.MapTrigger)
+		}
+	}
+	return fired
+}
@@ -3,7 +3,7 @@ package sim
 import (
 	"image"
 	"math"
-	"math/rand/v2"
+	"slices"
 
 	"github.com/google/uuid"
 )
@@ -11,6 +11,8 @@ import (
 var ArrivalThreshold = 25
 var MaxResourceCollectFrames = 30
 var PlayerFaction = 0
+var EnemyFaction = 1
+var NeutralFaction = 2 // never attacks or gets attacked, e.g. story units
 
 type Action int
 
@@ -22,6 +24,10 @@ const (
 	HoldingPositionAction
 	CollectingAction
 	DeliveringAction
+	DeadAction
+	// BuildingAction walks to a building site and works on it until it's
+	// done, see construction.go.
+	BuildingAction
 )
 
 type DestinationType int
@@ -39,6 +45,7 @@ const (
 	UnitTypeRoyalAnt
 	UnitTypeDefaultRoach
 	UnitTypeRoyalRoach
+	UnitTypeSoldierAnt
 )
 
 type Unit struct {
@@ -58,6 +65,23 @@ type Unit struct {
 	CurrentAnim           string
 	StuckFrames           int
 	StuckSidestepAttempts int
+	AttackCooldown        uint
+	killerID              string
+
+	// hostile building in sight when there's no unit to fight, see
+	// updateTargets
+	nearestBuilding BuildingInterface
+
+	// orders to carry out after the current action, see orders.go
+	Orders []Order
+	site   *InConstructionBuilding // what a builder is working on
+	pace   uint                    // group speed while moving in formation, 0 for our own
+
+	// tile waypoints towards Destination, planned by A*
+	Path     []image.Point
+	pathGoal *image.Point
+
+	index *spatialHash[*Unit] // set while the unit is in a sim
 
 	Faction uint
 }
@@ -67,7 +91,11 @@ type UnitStats struct {
 	HPCur     uint
 	MoveSpeed uint
 	Damage    uint
-	Range     uint
+	Range     uint // edge to edge distance a target can be hit from
+	// frames between attacks
+	AttackSpeed uint
+	// distance enemies are noticed from while attack moving
+	SightRange uint
 
 	MaxCarryCapactiy    uint
 	ResourceCarried     uint
@@ -75,9 +103,28 @@ type UnitStats struct {
 	ResourceCollectTime uint
 }
 
+// NewUnitOfType returns a fresh unit for the given type, e.g. when restoring
+// a snapshot or refilling a build queue.
+func NewUnitOfType(t UnitType) *Unit {
+	switch t {
+	case UnitTypeRoyalAnt:
+		return NewRoyalAnt()
+	case UnitTypeDefaultRoach:
+		return NewDefaultRoach()
+	case UnitTypeRoyalRoach:
+		return NewRoyalRoach()
+	case UnitTypeSoldierAnt:
+		return NewSoldierAnt()
+	default:
+		return NewDefaultAnt()
+	}
+}
+
 func NewRoyalRoach() *Unit {
 	u := NewDefaultAnt()
 	u.Type = UnitTypeRoyalRoach
+	u.Stats.HPMax = 300
+	u.Stats.HPCur = 300
 	size := 192 // match sprite
 	u.Rect.Min = image.Point{0, 0}
 	u.Rect.Max = image.Point{size, size}
@@ -87,12 +134,26 @@ func NewRoyalRoach() *Unit {
 func NewRoyalAnt() *Unit {
 	u := NewDefaultAnt()
 	u.Type = UnitTypeRoyalAnt
+	u.Stats.HPMax = 300
+	u.Stats.HPCur = 300
 	size := 192 // match sprite
 	u.Rect.Min = image.Point{0, 0}
 	u.Rect.Max = image.Point{size, size}
 	return u
 }
 
+// NewSoldierAnt is what barracks train, tougher and hits harder than a
+// worker but slower.
+func NewSoldierAnt() *Unit {
+	u := NewDefaultAnt()
+	u.Type = UnitTypeSoldierAnt
+	u.Stats.HPMax = 160
+	u.Stats.HPCur = 160
+	u.Stats.Damage = 18
+	u.Stats.MoveSpeed = 8
+	return u
+}
+
 func NewDefaultRoach() *Unit {
 	u := NewDefaultAnt()
 	u.Type = UnitTypeDefaultRoach
@@ -104,11 +165,13 @@ func NewDefaultAnt() *Unit {
 		ID:   uuid.New(),
 		Type: UnitTypeDefaultAnt,
 		Stats: &UnitStats{
-			HPMax:     100,
-			HPCur:     100,
-			MoveSpeed: 10,
-			Damage:    10,
-			Range:     15,
+			HPMax:       100,
+			HPCur:       100,
+			MoveSpeed:   10,
+			Damage:      10,
+			Range:       15,
+			AttackSpeed: 30,
+			SightRange:  300,
 			// acceleration / current speed?
 			MaxCarryCapactiy:    5,
 			ResourceCarried:     0,
@@ -126,23 +189,44 @@ func NewDefaultAnt() *Unit {
 }
 
 func (unit *Unit) Update(sim *T) {
+	if unit.Action == IdleAction {
+		unit.nextOrder(sim)
+	}
 	switch unit.Action {
 	case IdleAction:
+		// fight back and go after enemies that come close
+		if unit.InAttackRange(unit.NearestEnemy) {
+			unit.Attack(sim, unit.NearestEnemy)
+		} else if unit.NearestEnemy != nil && unit.EdgeDistanceToRect(unit.NearestEnemy.Rect) <= IdleChaseRange {
+			unit.StepTowards(sim, *unit.NearestEnemy.GetCenteredPosition())
+		} else if unit.InBuildingAttackRange(unit.nearestBuilding) {
+			unit.AttackBuilding(unit.nearestBuilding)
+		}
+	case HoldingPositionAction:
+		// fight back against anything in range, but never give chase
+		if unit.InAttackRange(unit.NearestEnemy) {
+			unit.Attack(sim, unit.NearestEnemy)
+		} else if unit.InBuildingAttackRange(unit.nearestBuilding) {
+			unit.AttackBuilding(unit.nearestBuilding)
+		}
+	case DeadAction:
 		return
 	case MovingAction:
 		unit.MoveToDestination(sim, false)
+	case BuildingAction:
+		unit.build(sim)
 	case AttackMovingAction:
-		if unit.NearestEnemy != nil && unit.TargetInRange(*unit.Position) {
-			unit.NearestEnemy.Stats.HPCur -= unit.Stats.Damage
-			// pew pew animation
+		if unit.InAttackRange(unit.NearestEnemy) {
+			unit.Attack(sim, unit.NearestEnemy)
+		} else if unit.NearestEnemy != nil {
+			unit.StepTowards(sim, *unit.NearestEnemy.GetCenteredPosition())
+		} else if unit.InBuildingAttackRange(unit.nearestBuilding) {
+			unit.AttackBuilding(unit.nearestBuilding)
+		} else if unit.nearestBuilding != nil {
+			unit.StepTowards(sim, *unit.nearestBuilding.GetCenteredPosition())
 		} else {
 			unit.MoveToDestination(sim, false) // destination might be a unit?
 		}
-	case HoldingPositionAction:
-		if unit.NearestEnemy != nil && unit.TargetInRange(*unit.Position) {
-			unit.NearestEnemy.Stats.HPCur -= unit.Stats.Damage
-			// pew pew animation
-		}
 	case CollectingAction:
 		// if we are holding some resources, set home, then set deliveringAction
 		if unit.Stats.ResourceCarried > 0 { // better logic so it doesnt always bring back minimal resource amount
@@ -150,6 +234,9 @@ func (unit *Unit) Update(sim *T) {
 			var nearest BuildingInterface
 			minDist := uint(math.MaxUint32)
 			for _, hive := range sim.GetAllBuildings() {
+				if !slices.Contains(DropOffBuildings, hive.GetType()) {
+					continue // only hives and depots accept resources
+				}
 				if hive.GetFaction() == unit.Faction {
 					dist := unit.DistanceTo(*hive.GetCenteredPosition())
 					if nearest == nil || dist < minDist {
@@ -159,11 +246,20 @@ func (unit *Unit) Update(sim *T) {
 				}
 			}
 			unit.NearestHome = nearest
+			if nearest == nil { // nowhere to bring it
+				unit.Action = IdleAction
+				return
+			}
 			unit.LastResourcePos = unit.Destination
 			unit.Destination = unit.NearestHome.GetClosestPosition(unit.Position.X, unit.Position.Y)
 			unit.Action = DeliveringAction
 		} else {
-			// move to and collect resource
+			// move to and collect resource, or find another if it ran dry
+			node := sim.GetResourceNodeAt(*unit.Destination)
+			if node == nil || node.Depleted() {
+				unit.retargetHarvest(sim, node)
+				return
+			}
 			unit.MoveToDestination(sim, false) // setting this to True causes jank behavior and its better as false?
 			dist := unit.DistanceTo(*unit.Destination)
 			if dist < 230 { // lots of tweaks needed here or fixes TODO
@@ -171,11 +267,8 @@ func (unit *Unit) Update(sim *T) {
 				unit.Stats.ResourceCollectTime += 1
 				if unit.Stats.ResourceCollectTime >= uint(MaxResourceCollectFrames) {
 					unit.Stats.ResourceCollectTime = 0
-					tile := sim.world.TileMap.GetTileByPosition(unit.Destination.X, unit.Destination.Y)
-					if tile != nil && tile.Type != "none" {
-						unit.Stats.ResourceCarried = 5
-						unit.Stats.ResourceTypeCarried = tile.Type
-					}
+					unit.Stats.ResourceCarried = sim.harvest(node, HarvestAmount)
+					unit.Stats.ResourceTypeCarried = node.Type
 				}
 			}
 		}
@@ -185,15 +278,9 @@ func (unit *Unit) Update(sim *T) {
 		unit.MoveToDestination(sim, false) // setting this to True causes jank behavior and its better as false?
 		dist := unit.EdgeDistanceTo(*unit.Destination)
 		if dist < 100 { // lots of tweaks needed here or fixes TODO
-			if unit.Stats.ResourceTypeCarried == "wood" {
-				sim.AddWood(unit.Stats.ResourceCarried)
-				unit.Stats.ResourceCarried = 0
-				unit.Stats.ResourceTypeCarried = ""
-			} else if unit.Stats.ResourceTypeCarried == "sucrose" {
-				sim.AddSucrose(unit.Stats.ResourceCarried)
-				unit.Stats.ResourceCarried = 0
-				unit.Stats.ResourceTypeCarried = ""
-			}
+			sim.AddResource(unit.Faction, unit.Stats.ResourceTypeCarried, unit.Stats.ResourceCarried)
+			unit.Stats.ResourceCarried = 0
+			unit.Stats.ResourceTypeCarried = ""
 			unit.Destination = unit.LastResourcePos
 			unit.Action = CollectingAction
 		}
@@ -202,7 +289,12 @@ func (unit *Unit) Update(sim *T) {
 }
 func (unit *Unit) MoveToDestination(sim *T, harvesting bool) {
 
-	speed := float64(unit.Stats.MoveSpeed)
+	if unit.pathGoal == nil || *unit.pathGoal != *unit.Destination {
+		unit.StuckFrames = 0
+		unit.planPath(sim, false)
+	}
+	waypoint := unit.nextWaypoint()
+
 	oldPos := unit.GetCenteredPosition()
 	oldX := oldPos.X
 	oldY := oldPos.Y
@@ -210,43 +302,13 @@ func (unit *Unit) MoveToDestination(sim *T, harvesting bool) {
 	dx := float64(unit.Destination.X - unit.Position.X)
 	dy := float64(unit.Destination.Y - unit.Position.Y)
 
-	// Movement request
-	moveX := math.Copysign(math.Min(math.Abs(dx), speed), dx) // move by at most `speed` towards target X
-	moveY := math.Copysign(math.Min(math.Abs(dy), speed), dy) // move by at most `speed` towards target Y
-
-	// Attempt X movement
-	if moveX != 0 {
-		newX := unit.Position.X + int(moveX)
-		newY := unit.Position.Y
-		candidate := &image.Rectangle{
-			Min: image.Point{X: newX, Y: newY},
-			Max: image.Point{X: newX + unit.Rect.Dx(), Y: newY + unit.Rect.Dy()},
-		}
-		if !unit.isColliding(candidate, sim) {
-			unit.SetPosition(&image.Point{X: newX, Y: unit.Position.Y})
-		}
-	}
+	// Movement request, bent around units in the way
+	moveX, moveY := unit.steer(sim, waypoint)
+	unit.tryMove(sim, moveX, moveY)
 
-	// Attempt Y movement
-	if moveY != 0 {
-		newY := unit.Position.Y + int(moveY)
-		newX := unit.Position.X
-		candidate := &image.Rectangle{
-			Min: image.Point{X: newX, Y: newY},
-			Max: image.Point{X: newX + unit.Rect.Dx(), Y: newY + unit.Rect.Dy()},
-		}
-		if !unit.isColliding(candidate, sim) {
-			unit.SetPosition(&image.Point{X: unit.Position.X, Y: newY})
-		}
-	}
-
-	// Handle Rotation
 	newCentered := unit.GetCenteredPosition()
 	dxRot := float64(newCentered.X - oldX)
 	dyRot := float64(newCentered.Y - oldY)
-	if dxRot != 0 || dyRot != 0 { // update angle only if moved
-		unit.MovingAngle = math.Atan2(dyRot, dxRot) + math.Pi/2 // adjust for sprite orientation
-	}
 	arrived := math.Abs(dx) <= float64(ArrivalThreshold) && math.Abs(dy) <= float64(ArrivalThreshold)
 	const stuckEpsilon = 1.5
 	moved := math.Abs(dxRot) > stuckEpsilon || math.Abs(dyRot) > stuckEpsilon
@@ -254,10 +316,10 @@ func (unit *Unit) MoveToDestination(sim *T, harvesting bool) {
 	if !moved && !arrived && unit.Stats.ResourceCollectTime == 0 {
 		unit.StuckFrames++
 
-		if unit.StuckFrames%10 == 0 {
-
-			unit.TrySidestep(sim)
-			//unit.StuckSidestepAttempts++
+		// steering turns harder the longer we're stuck, see avoidance
+		if unit.StuckFrames%ReplanStuckFrames == 0 {
+			// something is in the way, find a route around the units blocking us
+			unit.planPath(sim, true)
 		}
 
 		if unit.StuckFrames > 200 { //|| unit.StuckSidestepAttempts > 3
@@ -297,98 +359,70 @@ func (unit *Unit) MoveToDestination(sim *T, harvesting bool) {
 	// }
 }
 
-func (unit *Unit) edgeDist(pos image.Point, goal image.Point) float64 {
-	cx := pos.X + unit.Rect.Dx()/2
-	cy := pos.Y + unit.Rect.Dy()/2
-	dx := float64(goal.X - cx)
-	dy := float64(goal.Y - cy)
-	return math.Sqrt(dx*dx + dy*dy)
+// StepTowards moves the unit's center one step towards point without touching
+// its Destination, e.g. to chase an enemy.
+func (unit *Unit) StepTowards(sim *T, point image.Point) {
+	speed := float64(unit.Stats.MoveSpeed)
+	center := unit.GetCenteredPosition()
+	dx := float64(point.X - center.X)
+	dy := float64(point.Y - center.Y)
+	moveX := math.Copysign(math.Min(math.Abs(dx), speed), dx)
+	moveY := math.Copysign(math.Min(math.Abs(dy), speed), dy)
+	unit.tryMove(sim, int(moveX), int(moveY))
 }
 
-func (unit *Unit) isColliding(rect *image.Rectangle, sim *T) bool {
-	colliders := sim.GetAllCollidersOverlapping(rect)
-	for _, collider := range colliders {
-		if collider.OwnerID == unit.ID.String() {
-			continue // skip self
-		}
-		if collider.Rect.Overlaps(*rect) {
-			return true
+// tryMove attempts the X and Y parts of a move separately so units can slide
+// along walls, then updates the facing angle.
+func (unit *Unit) tryMove(sim *T, moveX, moveY int) {
+	oldPos := unit.GetCenteredPosition()
+
+	// Attempt X movement
+	if moveX != 0 {
+		newX := unit.Position.X + moveX
+		newY := unit.Position.Y
+		candidate := &image.Rectangle{
+			Min: image.Point{X: newX, Y: newY},
+			Max: image.Point{X: newX + unit.Rect.Dx(), Y: newY + unit.Rect.Dy()},
 		}
-	}
-	for _, mo := range sim.world.MapObjects {
-		if mo.Rect.Overlaps(*rect) {
-			return true
+		if !unit.isColliding(candidate, sim) {
+			unit.SetPosition(&image.Point{X: newX, Y: unit.Position.Y})
 		}
 	}
-	return false
-}
-
-// func (unit *Unit) TrySidestep(sim *T) {
-// 	speed := float64(unit.Stats.MoveSpeed)
-// 	offsets := []image.Point{
-// 		{X: 0, Y: -int(speed)}, // up
-// 		{X: 0, Y: int(speed)},  // down
-// 		{X: -int(speed), Y: 0}, // left
-// 		{X: int(speed), Y: 0},  // right
-// 	}
-
-// 	for _, off := range offsets {
-// 		newX := unit.Position.X + off.X
-// 		newY := unit.Position.Y + off.Y
-// 		candidate := &image.Rectangle{
-// 			Min: image.Point{X: newX, Y: newY},
-// 			Max: image.Point{X: newX + unit.Rect.Dx(), Y: newY + unit.Rect.Dy()},
-// 		}
-// 		if !unit.isColliding(candidate, sim) {
-// 			unit.SetPosition(&image.Point{X: newX, Y: newY})
-// 			break
-// 		}
-// 	}
-// }
-
-func (unit *Unit) TrySidestep(sim *T) {
-	dest := unit.Destination
-	bestOffset := image.Point{}
-	shortestDist := unit.DistanceTo(*dest)
-
-	// Try 8 directions (N, NE, E, SE, S, SW, W, NW)
-	offsets := []image.Point{
-		{X: -1, Y: 0}, {X: 1, Y: 0},
-		{X: 0, Y: -1}, {X: 0, Y: 1},
-		{X: -1, Y: -1}, {X: 1, Y: -1},
-		{X: -1, Y: 1}, {X: 1, Y: 1},
-	}
 
-	// Shuffle offsets to avoid always biasing same direction
-	rand.Shuffle(len(offsets), func(i, j int) {
-		offsets[i], offsets[j] = offsets[j], offsets[i]
-	})
-
-	for _, off := range offsets {
-		newX := unit.Position.X + off.X*int(unit.Stats.MoveSpeed)
-		newY := unit.Position.Y + off.Y*int(unit.Stats.MoveSpeed)
+	// Attempt Y movement
+	if moveY != 0 {
+		newY := unit.Position.Y + moveY
+		newX := unit.Position.X
 		candidate := &image.Rectangle{
 			Min: image.Point{X: newX, Y: newY},
 			Max: image.Point{X: newX + unit.Rect.Dx(), Y: newY + unit.Rect.Dy()},
 		}
 		if !unit.isColliding(candidate, sim) {
-			// Check if this move gets us closer to the destination
-			newDist := unit.edgeDist(image.Point{X: newX, Y: newY}, *dest)
-			if newDist < float64(shortestDist) {
-				bestOffset = off
-				shortestDist = uint(newDist)
-			}
+			unit.SetPosition(&image.Point{X: unit.Position.X, Y: newY})
 		}
 	}
 
-	// Apply best offset if found
-	if bestOffset != (image.Point{}) {
-		newX := unit.Position.X + bestOffset.X*int(unit.Stats.MoveSpeed)
-		newY := unit.Position.Y + bestOffset.Y*int(unit.Stats.MoveSpeed)
-		unit.SetPosition(&image.Point{X: newX, Y: newY})
+	// Handle Rotation
+	newCentered := unit.GetCenteredPosition()
+	dxRot := float64(newCentered.X - oldPos.X)
+	dyRot := float64(newCentered.Y - oldPos.Y)
+	if dxRot != 0 || dyRot != 0 { // update angle only if moved
+		unit.MovingAngle = math.Atan2(dyRot, dxRot) + math.Pi/2 // adjust for sprite orientation
 	}
 }
 
+func (unit *Unit) edgeDist(pos image.Point, goal image.Point) float64 {
+	cx := pos.X + unit.Rect.Dx()/2
+	cy := pos.Y + unit.Rect.Dy()/2
+	dx := float64(goal.X - cx)
+	dy := float64(goal.Y - cy)
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+func (unit *Unit) isColliding(rect *image.Rectangle, sim *T) bool {
+	return sim.overlapsCollider(rect, unit) || sim.overlapsMapObject(rect) || sim.overlapsImpassableTile(rect)
+}
+
 func (unit *Unit) SetNearestEnemy(target *Unit) {
 	unit.NearestEnemy = target
 }
@@ -415,10 +449,6 @@ func (unit *Unit) EdgeDistanceTo(point image.Point) uint {
 	return uint(math.Sqrt(dx*dx + dy*dy))
 }
 
-func (unit *Unit) TargetInRange(point image.Point) bool {
-	return unit.DistanceTo(point) <= unit.Stats.Range
-}
-
 func (unit *Unit) SetPosition(pos *image.Point) {
 	sizeX := unit.Rect.Dx()
 	sizeY := unit.Rect.Dy()
@@ -428,6 +458,9 @@ func (unit *Unit) SetPosition(pos *image.Point) {
 		X: pos.X + sizeX,
 		Y: pos.Y + sizeY,
 	}
+	if unit.index != nil {
+		unit.index.Update(unit)
+	}
 }
 
 func (unit *Unit) SetTilePosition(x, y int) {
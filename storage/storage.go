@@ -0,0 +1,39 @@
+//go:build !js
+
+// Package storage keeps small files like save games somewhere that survives
+// restarts: the user config dir on desktop, localStorage in the browser.
+package storage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+var AppName = "antony-and-cleopatroach"
+
+func dir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, AppName), nil
+}
+
+func Write(name string, data []byte) error {
+	d, err := dir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(d, name), data, 0o644)
+}
+
+func Read(name string) ([]byte, error) {
+	d, err := dir()
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(d, name))
+}
@@ -0,0 +1,40 @@
+//go:build js
+
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall/js"
+)
+
+var AppName = "antony-and-cleopatroach"
+
+func localStorage() (js.Value, error) {
+	ls := js.Global().Get("localStorage")
+	if ls.IsUndefined() || ls.IsNull() {
+		return js.Value{}, fmt.Errorf("localStorage is not available")
+	}
+	return ls, nil
+}
+
+func Write(name string, data []byte) error {
+	ls, err := localStorage()
+	if err != nil {
+		return err
+	}
+	ls.Call("setItem", AppName+"/"+name, string(data))
+	return nil
+}
+
+func Read(name string) ([]byte, error) {
+	ls, err := localStorage()
+	if err != nil {
+		return nil, err
+	}
+	item := ls.Call("getItem", AppName+"/"+name)
+	if item.IsNull() {
+		return nil, fmt.Errorf("%v: %w", name, fs.ErrNotExist)
+	}
+	return []byte(item.String()), nil
+}
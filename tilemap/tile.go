@@ -1,16 +1,102 @@
 package tilemap
 
-import "image"
+import (
+	"fmt"
+	"image"
+	"slices"
+
+	"github.com/lafriks/go-tiled"
+)
 
 var (
 	TileTypePlain   = "plain"
-	TileTypeSucrose = "sucrose"
-	TileTypeWood    = "wood"
+	ResourceSucrose = "sucrose"
+	ResourceWood    = "wood"
 )
 
+// Tile properties understood in tiles.tsx, anything else gets a warning:
+//
+//	type            string, what the terrain is, e.g. "water"
+//	passable        bool, false blocks units, true when left out
+//	resource        string, "sucrose" or "wood"
+//	resource_amount int, how much the resource holds, the sim picks when left out
+//	move_cost       float, how much pathfinding avoids the tile, 1 is normal ground
+//	                and nothing is cheaper
+var TileProperties = []string{"type", "passable", "resource", "resource_amount", "move_cost"}
+
+// DepletedTileIDs is what a resource tile looks like once it's harvested dry,
+// bare grass for sucrose and a stump for wood.
+var DepletedTileIDs = map[string]int{
+	ResourceSucrose: 1,
+	ResourceWood:    9,
+}
+
 type Tile struct {
-	Type        string
-	Coordinates *image.Point
-	Rect        *image.Rectangle
-	TileID      int
+	Type           string
+	Walkable       bool
+	Resource       string // "" when there's nothing to harvest
+	ResourceAmount uint   // 0 leaves it up to the sim
+	MoveCost       float64
+	Coordinates    *image.Point
+	Rect           *image.Rectangle
+	TileID         int // of the top tile, for layered maps
+}
+
+// plainTile is what a spot with no tiles, or only tiles without properties,
+// counts as.
+func plainTile() Tile {
+	return Tile{Type: TileTypePlain, Walkable: true, MoveCost: 1}
+}
+
+// tileKind reads the properties of a tileset tile. Problems come back as
+// warnings, the tile is still usable.
+func tileKind(tile *tiled.TilesetTile) (Tile, []string) {
+	kind := Tile{Walkable: true}
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf("tile %d: ", tile.ID)+fmt.Sprintf(format, args...))
+	}
+	for _, property := range tile.Properties {
+		if !slices.Contains(TileProperties, property.Name) {
+			warn("unknown property %q", property.Name)
+		}
+	}
+	props := tile.Properties
+	kind.Type = props.GetString("type")
+	if len(props.Get("passable")) > 0 {
+		kind.Walkable = props.GetBool("passable")
+	}
+	kind.Resource = props.GetString("resource")
+	if kind.Resource != "" && kind.Resource != ResourceSucrose && kind.Resource != ResourceWood {
+		warn("unknown resource %q", kind.Resource)
+		kind.Resource = ""
+	}
+	if amount := props.GetInt("resource_amount"); amount > 0 {
+		kind.ResourceAmount = uint(amount)
+		if kind.Resource == "" {
+			warn("resource_amount without a resource")
+		}
+	}
+	if len(props.Get("move_cost")) > 0 {
+		kind.MoveCost = props.GetFloat("move_cost")
+		if kind.MoveCost <= 0 {
+			warn("move_cost %v should be above 0", kind.MoveCost)
+			kind.MoveCost = 0
+		}
+	}
+	return kind, warnings
+}
+
+// stack puts kind on top of the tile: the top-most type and resource win,
+// any blocking layer blocks and the highest move cost counts.
+func (t *Tile) stack(kind Tile) {
+	if kind.Type != "" {
+		t.Type = kind.Type
+	}
+	t.Walkable = t.Walkable && kind.Walkable
+	if kind.Resource != "" {
+		t.Resource = kind.Resource
+		t.ResourceAmount = kind.ResourceAmount
+	}
+	t.MoveCost = max(t.MoveCost, kind.MoveCost)
 }
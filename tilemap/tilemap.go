@@ -1,11 +1,14 @@
 package tilemap
 
 import (
+	"fmt"
 	"gamejam/assets"
 	"image"
+	_ "image/png"
 	"log"
+	"path/filepath"
+	"slices"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/lafriks/go-tiled"
 	"github.com/lafriks/go-tiled/render"
 )
@@ -17,11 +20,13 @@ type Tilemap struct {
 	TileSize int
 
 	tileMap              *tiled.Map
-	StaticBg             *ebiten.Image
+	StaticBg             image.Image // visible tile layers rendered, nil when loaded headless
 	MapObjects           []*MapObject
 	MapCompletionObjects []*MapCompletionObject
+	MapTriggers          []*MapTrigger
 	TileSet              map[int]*tiled.TilesetTile
 	Tiles                [][]*Tile
+	Warnings             []string // problems found in the tileset and triggers
 }
 
 type MapObject struct {
@@ -33,14 +38,26 @@ type MapCompletionObject struct {
 }
 
 func NewTilemap(mapPath string) *Tilemap {
+	tmap, err := LoadTilemap(mapPath)
+	if err != nil {
+		log.Fatalf("unable to load tmx: %v", err.Error())
+	}
+	tmap.StaticBg = tmap.RenderBg(nil)
+	return tmap
+}
+
+// LoadTilemap parses the map without rendering anything, so it can be used
+// where there is no display, e.g. cmd/simrun.
+func LoadTilemap(mapPath string) (*Tilemap, error) {
 	tm, err := tiled.LoadFile(mapPath, tiled.WithFileSystem(assets.Files)) // this wont work in wasm! need to embed files but it breaks
 	//tm, err := tiled.LoadFile(mapPath)                                     // this wont work in wasm! need to embed files but it breaks
 
 	if err != nil {
-		log.Fatalf("unable to load tmx: %v", err.Error())
+		return nil, err
+	}
+	if len(tm.Tilesets) == 0 || len(tm.Layers) == 0 {
+		return nil, fmt.Errorf("%v has no tileset or tile layer", mapPath)
 	}
-
-	staticBg := generateLayer0Image(tm)
 
 	tilesIdMap := make(map[int]*tiled.TilesetTile)
 
@@ -50,6 +67,8 @@ func NewTilemap(mapPath string) *Tilemap {
 
 	var mapCollisionObjects []*MapObject
 	var mapCompletionObjects []*MapCompletionObject
+	var mapTriggers []*MapTrigger
+	var warnings []string
 	for _, objectGroup := range tm.ObjectGroups {
 		if objectGroup.Name == "collision" {
 			for _, object := range objectGroup.Objects {
@@ -78,15 +97,22 @@ func NewTilemap(mapPath string) *Tilemap {
 				mapCompletionObjects = append(mapCompletionObjects, mo)
 			}
 		}
+		if objectGroup.Name == "triggers" {
+			for _, object := range objectGroup.Objects {
+				trigger, problems := newMapTrigger(object)
+				mapTriggers = append(mapTriggers, trigger)
+				warnings = append(warnings, problems...)
+			}
+		}
 	}
 
 	tmap := &Tilemap{
 		tileMap:              tm,
-		StaticBg:             staticBg,
 		TileSet:              tilesIdMap,
 		Tiles:                make([][]*Tile, tm.Width),
 		MapObjects:           mapCollisionObjects,
 		MapCompletionObjects: mapCompletionObjects,
+		MapTriggers:          mapTriggers,
 		Width:                tm.Width,
 		Height:               tm.Height,
 		TileSize:             tm.TileWidth,
@@ -95,52 +121,102 @@ func NewTilemap(mapPath string) *Tilemap {
 		tmap.Tiles[i] = make([]*Tile, tm.Height)
 	}
 	tmap.ToWorld()
-	return tmap
+	tmap.Warnings = append(tmap.Warnings, warnings...)
+	for _, warning := range tmap.Warnings {
+		log.Printf("%v: %v", mapPath, warning)
+	}
+	return tmap, nil
 }
 
-func generateLayer0Image(tm *tiled.Map) *ebiten.Image {
-	r, err := render.NewRendererWithFileSystem(tm, assets.Files)
+// RenderBg draws the visible tile layers, with the named layers flipped,
+// e.g. hidden ones a trigger revealed.
+func (tm *Tilemap) RenderBg(flipped []string) image.Image {
+	r, err := render.NewRendererWithFileSystem(tm.tileMap, assets.Files)
 	if err != nil {
 		log.Fatal("unable to load tmx renderer")
 	}
 
-	r.RenderLayer(0)
+	for i, layer := range tm.tileMap.Layers {
+		if layer.Visible == slices.Contains(flipped, layer.Name) {
+			continue
+		}
+		if err := r.RenderLayer(i); err != nil {
+			log.Fatalf("layer unsupported for rendering: %v", err.Error())
+		}
+	}
+	return r.Result
+}
+
+// TileImage loads the tileset image of a single tile, e.g. to swap one tile
+// on the rendered background.
+func (tm *Tilemap) TileImage(id int) (image.Image, error) {
+	tileset := tm.tileMap.Tilesets[0]
+	source, whole := "", false
+	if tile, ok := tm.TileSet[id]; ok && tile.Image != nil {
+		source = tile.Image.Source
+	} else if tileset.Image != nil {
+		source, whole = tileset.Image.Source, true
+	} else {
+		return nil, fmt.Errorf("tile %d has no image", id)
+	}
+	f, err := assets.Files.Open(filepath.ToSlash(tileset.GetFileFullPath(source)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
 	if err != nil {
-		log.Fatalf("layer unsupported for rendering: %v", err.Error())
+		return nil, err
 	}
-	staticBg := ebiten.NewImageFromImage(r.Result)
-	r.Clear()
-	return staticBg
+	if whole { // one image for the whole tileset, cut ours out
+		return img.(interface {
+			SubImage(image.Rectangle) image.Image
+		}).SubImage(tileset.GetTileRect(uint32(id))), nil
+	}
+	return img, nil
 }
 
 func (tm *Tilemap) GetMap() *tiled.Map {
 	return tm.tileMap
 }
 
+// ToWorld works out what every spot on the map is from the tileset
+// properties of the tiles stacked on it, see TileProperties. Problems with
+// the tileset end up in Warnings.
 func (tm *Tilemap) ToWorld() {
+	kinds := make(map[*tiled.Tileset]map[uint32]Tile)
+	tm.Warnings = nil
+	for _, tileset := range tm.tileMap.Tilesets {
+		kinds[tileset] = make(map[uint32]Tile)
+		for _, tile := range tileset.Tiles {
+			kind, warnings := tileKind(tile)
+			kinds[tileset][tile.ID] = kind
+			for _, warning := range warnings {
+				tm.Warnings = append(tm.Warnings, fmt.Sprintf("%v %v", tileset.Name, warning))
+			}
+		}
+	}
+
 	mapWidth := tm.Width
 	for y := 0; y < tm.Height; y++ {
 		for x := 0; x < tm.Width; x++ {
-			t := tm.tileMap.Layers[0].Tiles[y*mapWidth+x]
-			var tileType string
-			switch t.ID {
-			case 15:
-				tileType = "sucrose"
-			case 6:
-				tileType = "wood"
-			default:
-				tileType = "none"
+			newTile := plainTile()
+			newTile.Coordinates = &image.Point{X: x, Y: y}
+			newTile.Rect = &image.Rectangle{
+				Min: image.Point{X: x * tm.TileSize, Y: y * tm.TileSize},
+				Max: image.Point{X: (x * tm.TileSize) + tm.TileSize, Y: (y * tm.TileSize) + tm.TileSize},
 			}
-			newTile := &Tile{
-				Type:        tileType,
-				TileID:      int(t.ID),
-				Coordinates: &image.Point{X: x, Y: y},
-				Rect: &image.Rectangle{
-					Min: image.Point{X: x * tm.TileSize, Y: y * tm.TileSize},
-					Max: image.Point{X: (x * tm.TileSize) + tm.TileSize, Y: (y * tm.TileSize) + tm.TileSize},
-				},
+			for _, layer := range tm.tileMap.Layers {
+				t := layer.Tiles[y*mapWidth+x]
+				if t == nil || t.IsNil() {
+					continue
+				}
+				if kind, ok := kinds[t.Tileset][t.ID]; ok {
+					newTile.stack(kind)
+				}
+				newTile.TileID = int(t.ID)
 			}
-			tm.Tiles[x][y] = newTile
+			tm.Tiles[x][y] = &newTile
 		}
 	}
 }
@@ -0,0 +1,87 @@
+package tilemap
+
+import (
+	"fmt"
+	"image"
+	"slices"
+
+	"github.com/lafriks/go-tiled"
+)
+
+// Trigger zones come from the "triggers" object group. Each object is an
+// area with these properties:
+//
+//	on_enter string, what happens when a unit walks in, one of TriggerActions
+//	faction  string, whose units set it off, one of TriggerFactions, player when left out
+//	once     bool, only ever fire the first time, true when left out
+//	script   string, what on_enter does:
+//	           cutscene  the cutscene file, e.g. cutscenes/ambush.txt
+//	           spawn     "<kind> <count> <tile x> <tile y>", spawned for the enemy
+//	           tutorial  the tutorial image, e.g. tutorials/tutorial-3.png
+//	           reveal    the tile layer to show if it's hidden, or hide if it's shown
+var TriggerProperties = []string{"on_enter", "faction", "once", "script"}
+
+var TriggerActions = []string{"cutscene", "spawn", "tutorial", "reveal"}
+var TriggerFactions = []string{"player", "enemy", "neutral", "any"}
+
+type MapTrigger struct {
+	ID      int
+	Name    string
+	Rect    *image.Rectangle
+	OnEnter string
+	Faction string
+	Once    bool
+	Script  string
+}
+
+// newMapTrigger reads a trigger object. Problems come back as warnings, a
+// trigger with an unknown on_enter never does anything.
+func newMapTrigger(object *tiled.Object) (*MapTrigger, []string) {
+	props := object.Properties
+	trigger := &MapTrigger{
+		ID:   int(object.ID),
+		Name: object.Name,
+		Rect: &image.Rectangle{
+			Min: image.Point{X: int(object.X), Y: int(object.Y)},
+			Max: image.Point{X: int(object.X + object.Width), Y: int(object.Y + object.Height)},
+		},
+		OnEnter: props.GetString("on_enter"),
+		Faction: props.GetString("faction"),
+		Once:    true,
+		Script:  props.GetString("script"),
+	}
+	if len(props.Get("once")) > 0 {
+		trigger.Once = props.GetBool("once")
+	}
+	if trigger.Faction == "" {
+		trigger.Faction = "player"
+	}
+
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf("trigger %d: ", object.ID)+fmt.Sprintf(format, args...))
+	}
+	for _, property := range props {
+		if !slices.Contains(TriggerProperties, property.Name) {
+			warn("unknown property %q", property.Name)
+		}
+	}
+	if !slices.Contains(TriggerActions, trigger.OnEnter) {
+		warn("on_enter %q, expected one of %v", trigger.OnEnter, TriggerActions)
+	}
+	if !slices.Contains(TriggerFactions, trigger.Faction) {
+		warn("faction %q, expected one of %v", trigger.Faction, TriggerFactions)
+	}
+	if trigger.Script == "" {
+		warn("%v trigger has no script", trigger.OnEnter)
+	}
+	if trigger.Rect.Empty() {
+		warn("area is empty, nothing can walk into it")
+	}
+	return trigger, warnings
+}
+
+// HasLayer reports whether the map has a tile layer with the given name.
+func (tm *Tilemap) HasLayer(name string) bool {
+	return slices.ContainsFunc(tm.tileMap.Layers, func(layer *tiled.Layer) bool { return layer.Name == name })
+}
@@ -157,6 +157,10 @@ func (btn *Button) Update() {
 	}
 }
 
+func (btn *Button) SetText(txt string) {
+	btn.text = txt
+}
+
 func (btn *Button) MouseCollides() bool {
 	mx, my := ebiten.CursorPosition()
 	collides := mx > int(btn.rect.Min.X) &&
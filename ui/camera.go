@@ -199,6 +199,15 @@ func (c *Camera) SetPosition(x, y int) {
 	c.ViewPortX = -x
 	c.ViewPortY = -y
 }
+
+// CenterOn moves the viewport so map point x, y is in the middle of the
+// screen, as far as the map edges allow.
+func (c *Camera) CenterOn(x, y int) {
+	c.ViewPortX = 400 - int(float64(x)*c.ViewPortZoom)
+	c.ViewPortY = 300 - int(float64(y)*c.ViewPortZoom)
+	c.PanX(0) // Will enforce constraints
+	c.PanY(0)
+}
 func (c *Camera) SetZoom(amount float64) {
 	c.ViewPortZoom = amount
 }
@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"fmt"
 	"gamejam/eventing"
 	"gamejam/sim"
 	"gamejam/tilemap"
@@ -14,9 +13,21 @@ import (
 
 var GridSize = 128.0
 
+// BuildingArt is what each building looks like while it's being placed.
+var BuildingArt = map[sim.BuildingType]string{
+	sim.BuildingTypeBridge:         "tilemap/bridge.png",
+	sim.BuildingTypeHive:           "units/ant-hill.png",
+	sim.BuildingTypeStorageDepot:   "units/ant-hill.png",
+	sim.BuildingTypeBarracks:       "units/ant-hill.png",
+	sim.BuildingTypeDefensiveMound: "units/ant-hill.png",
+	sim.BuildingTypeNest:           "units/ant-hill.png",
+}
+
 type ConstructionMouse struct {
 	Enabled            bool
+	Building           sim.BuildingType
 	constructingSprite *ebiten.Image
+	size               image.Point // footprint in map pixels
 	placementRect      *image.Rectangle
 
 	placementValid bool
@@ -27,33 +38,16 @@ func (cm *ConstructionMouse) Update(tm *tilemap.Tilemap, sim *sim.T) {
 		return
 	}
 
-	for _, mo := range tm.MapObjects {
-		if mo.IsBuildable && cm.placementRect != nil {
-			if cm.placementRect.Overlaps(*mo.Rect) {
-				cm.placementValid = true
-				break
-			} else {
-				cm.placementValid = false // GROSS - needs fixing later
-			}
-		}
-	}
+	// same rules the sim checks when the building is started
+	cm.placementValid = cm.placementRect != nil && sim.CanPlaceBuilding(cm.Building, *cm.placementRect) == nil
 
 	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
-		for _, mo := range tm.MapObjects {
-			if !mo.IsBuildable {
-				continue
-			}
-			rect := mo.Rect
-			if rect.Min == cm.placementRect.Min && rect.Max == cm.placementRect.Max {
-				matchingRect := rect
-				fmt.Printf("Found matching buildable rect: %v\n", matchingRect)
-				sim.EventBus.Publish(eventing.Event{
-					Type: "BuildClickedEvent",
-					Data: eventing.BuildClickedEvent{
-						TargetRect: matchingRect,
-					},
-				})
-			}
+		if cm.placementValid {
+			rect := *cm.placementRect
+			eventing.Publish(sim.EventBus, eventing.BuildClickedEvent{
+				TargetRect: &rect,
+				Building:   int(cm.Building),
+			})
 		}
 		cm.Enabled = false
 	}
@@ -77,8 +71,8 @@ func (cm *ConstructionMouse) Draw(screen *ebiten.Image, camera *Camera) {
 	rect := image.Rect(
 		int(snappedX),
 		int(snappedY),
-		int(snappedX)+int(GridSize),
-		int(snappedY)+int(GridSize),
+		int(snappedX)+cm.size.X,
+		int(snappedY)+cm.size.Y,
 	)
 	cm.placementRect = &rect
 
@@ -99,6 +93,9 @@ func (cm *ConstructionMouse) Draw(screen *ebiten.Image, camera *Camera) {
 	screen.DrawImage(cm.constructingSprite, opts)
 }
 
-func (cm *ConstructionMouse) SetSprite(sprite string) {
-	cm.constructingSprite = util.LoadImage(sprite)
+// SetBuilding picks what gets placed next, sized to its footprint.
+func (cm *ConstructionMouse) SetBuilding(bt sim.BuildingType) {
+	cm.Building = bt
+	cm.size = sim.BuildingCatalog[bt].Size.Mul(int(GridSize))
+	cm.constructingSprite = util.ScaleImage(util.LoadImage(BuildingArt[bt]), float32(cm.size.X), float32(cm.size.Y))
 }
@@ -6,38 +6,93 @@ import (
 	"gamejam/sim"
 	"gamejam/util"
 	"image"
+	"image/color"
 	"log/slog"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
+// Hotkeys for the unit command buttons. WASD pans the camera and Z builds,
+// so they sit next to it on the bottom row.
+var (
+	AttackMoveKey = ebiten.KeyX
+	MoveKey       = ebiten.KeyC
+	StopKey       = ebiten.KeyV
+	HoldKey       = ebiten.KeyB
+	// CancelKey takes down a selected building site, it's stop for buildings.
+	CancelKey = ebiten.KeyV
+)
+
+// BuildKeys are the build menu hotkeys, on the left of the keyboard so one
+// hand can pan and build.
+var BuildKeys = map[sim.BuildingType]ebiten.Key{
+	sim.BuildingTypeBridge:         ebiten.KeyZ,
+	sim.BuildingTypeHive:           ebiten.KeyQ,
+	sim.BuildingTypeStorageDepot:   ebiten.KeyE,
+	sim.BuildingTypeBarracks:       ebiten.KeyR,
+	sim.BuildingTypeDefensiveMound: ebiten.KeyT,
+	sim.BuildingTypeNest:           ebiten.KeyF,
+}
+
+// UnitKeys make the units a selected hive or barracks can, in the order of
+// sim.Produces.
+var UnitKeys = []ebiten.Key{ebiten.KeyZ, ebiten.KeyX, ebiten.KeyC}
+
+// unitButtonText labels unit buttons that don't have their own art.
+var unitButtonText = map[sim.UnitType]string{
+	sim.UnitTypeSoldierAnt: "S",
+}
+
+// buildButtonText labels build menu buttons that don't have their own art.
+var buildButtonText = map[sim.BuildingType]string{
+	sim.BuildingTypeHive:           "H",
+	sim.BuildingTypeStorageDepot:   "D",
+	sim.BuildingTypeBarracks:       "B",
+	sim.BuildingTypeDefensiveMound: "M",
+	sim.BuildingTypeNest:           "N",
+}
+
 type RightSideHUDState int
 
 const (
 	HiddenState RightSideHUDState = iota
 	HiveSelectedState
 	UnitSelectedState
+	SiteSelectedState
 )
 
 type HUD struct {
 	leftSideBg   *ebiten.Image
 	leftSideRect image.Rectangle
 
-	rightSideBg            *ebiten.Image
-	rightSideRect          image.Rectangle
-	RightSideState         RightSideHUDState
-	rightSideMakeAntBtn    *Button
-	rightSideMakeBridgeBtn *Button
-	rightSideZImg          *ebiten.Image
+	rightSideBg        *ebiten.Image
+	rightSideRect      image.Rectangle
+	RightSideState     RightSideHUDState
+	rightSideCancelBtn *Button
+
+	// make unit buttons for each kind of building, see sim.Produces
+	unitBtns map[sim.BuildingType][]*Button
+
+	// build queue of the selected hive, one button per slot to cancel it
+	SelectedHiveID string
+	queueBtns      []*Button
+	queueIcons     map[sim.UnitType]*ebiten.Image
+
+	// build menu on the right side, shown while a unit is selected
+	buildBtns []*Button
+	buildKeys []ebiten.Key
+
+	// unit commands on the left side, shown while units are selected
+	CommandsVisible bool
+	commandBtns     []*Button
+	commandKeys     []ebiten.Key
+	font            text.Face
 
 	resourceDisplay *ResourceDisplay
-	//attackBtn       *Button
-	//attackLabel *ebiten.Image
-	// moveBtn *Button
-	// stopBtn *Button
-	log *slog.Logger
-	sim *sim.T
+	log             *slog.Logger
+	sim             *sim.T
 }
 
 func NewHUD(font text.Face, sim *sim.T) *HUD {
@@ -50,98 +105,204 @@ func NewHUD(font text.Face, sim *sim.T) *HUD {
 		rightSideRect:  rightSideRect,
 		rightSideBg:    util.ScaleImage(util.LoadImage("ui/btn/controls-bg-right.png"), float32(leftSideRect.Dx()), float32(leftSideRect.Dy())),
 		RightSideState: HiddenState,
-		rightSideZImg:  util.ScaleImage(util.LoadImage("ui/keys/z.png"), float32(40), float32(40)),
 
+		font:            font,
 		resourceDisplay: NewResourceDisplay(font),
 		log:             log.NewLogger().With("for", "HUD"),
 		sim:             sim,
 	}
 
-	c.rightSideMakeAntBtn = NewButton(font,
+	c.rightSideCancelBtn = NewButton(font,
 		WithRect(image.Rectangle{
 			Min: image.Pt(c.rightSideRect.Min.X+20, c.rightSideRect.Min.Y+15),
-			Max: image.Pt(c.rightSideRect.Min.X+70, c.rightSideRect.Min.Y+65)}),
+			Max: image.Pt(c.rightSideRect.Min.X+60, c.rightSideRect.Min.Y+55)}),
 		WithClickFunc(func() {
-			c.log.Info("MakeAntButtonClickedEvent")
-			sim.EventBus.Publish(eventing.Event{
-				Type: "MakeAntButtonClickedEvent",
-			})
+			c.log.Info("CancelConstructionButtonClickedEvent")
+			eventing.Publish(sim.EventBus, eventing.CancelConstructionButtonClickedEvent{})
 		}),
-		WithImage(util.LoadImage("ui/btn/make-ant-btn.png"), util.LoadImage("ui/btn/make-ant-btn-pressed.png")),
-		WithKeyActivation(ebiten.KeyZ),
+		WithImage(util.LoadImage("ui/btn/stop-btn.png"), util.LoadImage("ui/btn/stop-btn-pressed.png")),
+		WithKeyActivation(CancelKey),
 	)
 
-	c.rightSideMakeBridgeBtn = NewButton(font,
-		WithRect(image.Rectangle{
-			Min: image.Pt(c.rightSideRect.Min.X+20, c.rightSideRect.Min.Y+15),
-			Max: image.Pt(c.rightSideRect.Min.X+70, c.rightSideRect.Min.Y+65)}),
-		WithClickFunc(func() {
-			c.log.Info("MakeBridgeButtonClickedEvent")
-			sim.EventBus.Publish(eventing.Event{
-				Type: "MakeBridgeButtonClickedEvent",
-			})
-		}),
-		WithImage(util.LoadImage("ui/btn/make-bridge-btn.png"), util.LoadImage("ui/btn/make-bridge-btn-pressed.png")),
-		WithKeyActivation(ebiten.KeyZ),
-	)
+	c.setupBuildMenu()
+	c.setupUnitButtons()
+	c.setupBuildQueue()
 
-	// c.attackBtn = NewButton(font,
-	// 	WithRect(image.Rectangle{Min: image.Pt(c.rect.Min.X+20, c.rect.Min.Y+20), Max: image.Pt(c.rect.Min.X+70, c.rect.Min.Y+70)}),
-	// 	WithClickFunc(func() {
-	// 		c.log.Info("atkbtnclicked")
-	// 	}),
-	// 	WithImage(util.LoadImage("ui/btn/atk-btn.png"), util.LoadImage("ui/btn/atk-btn-pressed.png")),
-	// 	WithKeyActivation(ebiten.KeyZ),
-	// )
-	// c.moveBtn = NewButton(font,
-	// 	WithRect(image.Rectangle{Min: image.Pt(c.rect.Min.X+80, c.rect.Min.Y+20), Max: image.Pt(c.rect.Min.X+130, c.rect.Min.Y+70)}),
-	// 	WithImage(util.LoadImage("ui/btn/move-btn.png"), util.LoadImage("ui/btn/move-btn-pressed.png")),
-	// 	WithClickFunc(func() {
-	// 		c.log.Info("movebtnclicked")
-	// 	}),
-	// 	WithKeyActivation(ebiten.KeyX),
-	// )
-	// c.stopBtn = NewButton(font,
-	// 	WithRect(image.Rectangle{Min: image.Pt(c.rect.Min.X+140, c.rect.Min.Y+20), Max: image.Pt(c.rect.Min.X+190, c.rect.Min.Y+70)}),
-	// 	WithImage(util.LoadImage("ui/btn/stop-btn.png"), util.LoadImage("ui/btn/stop-btn-pressed.png")),
-	// 	WithClickFunc(func() {
-	// 		c.log.Info("stopbtnclicked")
-	// 	}),
-	// 	WithKeyActivation(ebiten.KeyC),
-	// )
+	commands := []struct {
+		name    string
+		key     ebiten.Key
+		img     string
+		pressed string
+		text    string
+	}{
+		{"attack", AttackMoveKey, "ui/btn/atk-btn.png", "ui/btn/atk-btn-pressed.png", ""},
+		{"move", MoveKey, "ui/btn/move-btn.png", "ui/btn/move-btn-pressed.png", ""},
+		{"stop", StopKey, "ui/btn/stop-btn.png", "ui/btn/stop-btn-pressed.png", ""},
+		{"hold", HoldKey, "ui/btn/btn-bg.png", "ui/btn/btn-bg.png", HoldKey.String()}, // no art yet, show its key
+	}
+	for i, cmd := range commands {
+		minX := c.leftSideRect.Min.X + 14 + i*46
+		minY := c.leftSideRect.Min.Y + 15
+		btn := NewButton(font,
+			WithRect(image.Rectangle{Min: image.Pt(minX, minY), Max: image.Pt(minX+40, minY+40)}),
+			WithClickFunc(func() {
+				c.log.Info("CommandButtonClickedEvent", "command", cmd.name)
+				eventing.Publish(sim.EventBus, eventing.CommandButtonClickedEvent{Command: cmd.name})
+			}),
+			WithImage(util.LoadImage(cmd.img), util.LoadImage(cmd.pressed)),
+			WithKeyActivation(cmd.key),
+			WithText(cmd.text),
+		)
+		c.commandBtns = append(c.commandBtns, btn)
+		c.commandKeys = append(c.commandKeys, cmd.key)
+	}
 	return c
 }
 
+// setupBuildMenu makes a button for everything in sim.BuildMenu.
+func (c *HUD) setupBuildMenu() {
+	step := min(37, (c.rightSideRect.Dx()-16)/len(sim.BuildMenu)) // squeeze them in as the menu grows
+	size := step - 5
+	for i, bt := range sim.BuildMenu {
+		minX := c.rightSideRect.Min.X + 10 + i*step
+		minY := c.rightSideRect.Min.Y + 15
+		img, pressed := "ui/btn/btn-bg.png", "ui/btn/btn-bg.png"
+		if bt == sim.BuildingTypeBridge {
+			img, pressed = "ui/btn/make-bridge-btn.png", "ui/btn/make-bridge-btn-pressed.png"
+		}
+		btn := NewButton(c.font,
+			WithRect(image.Rectangle{Min: image.Pt(minX, minY), Max: image.Pt(minX+size, minY+size)}),
+			WithClickFunc(func() {
+				c.log.Info("MakeBuildingButtonClickedEvent", "building", sim.BuildingCatalog[bt].Name)
+				eventing.Publish(c.sim.EventBus, eventing.MakeBuildingButtonClickedEvent{Building: int(bt)})
+			}),
+			WithImage(util.LoadImage(img), util.LoadImage(pressed)),
+			WithKeyActivation(BuildKeys[bt]),
+			WithText(buildButtonText[bt]),
+		)
+		c.buildBtns = append(c.buildBtns, btn)
+		c.buildKeys = append(c.buildKeys, BuildKeys[bt])
+	}
+}
+
+// setupUnitButtons makes a button for every unit each building in
+// sim.Produces makes, side by side on the left of the build queue.
+func (c *HUD) setupUnitButtons() {
+	c.unitBtns = make(map[sim.BuildingType][]*Button)
+	for bt, units := range sim.Produces {
+		for i, ut := range units[:min(len(units), len(UnitKeys))] {
+			minX := c.rightSideRect.Min.X + 10 + i*45
+			minY := c.rightSideRect.Min.Y + 15
+			img, pressed := "ui/btn/btn-bg.png", "ui/btn/btn-bg.png"
+			if _, ok := unitButtonText[ut]; !ok {
+				img, pressed = "ui/btn/make-ant-btn.png", "ui/btn/make-ant-btn-pressed.png"
+			}
+			btn := NewButton(c.font,
+				WithRect(image.Rectangle{Min: image.Pt(minX, minY), Max: image.Pt(minX+40, minY+40)}),
+				WithClickFunc(func() {
+					c.log.Info("MakeUnitButtonClickedEvent", "unit", sim.UnitCatalog[ut].Name)
+					eventing.Publish(c.sim.EventBus, eventing.MakeUnitButtonClickedEvent{UnitType: int(ut)})
+				}),
+				WithImage(util.LoadImage(img), util.LoadImage(pressed)),
+				WithKeyActivation(UnitKeys[i]),
+				WithText(unitButtonText[ut]),
+			)
+			c.unitBtns[bt] = append(c.unitBtns[bt], btn)
+		}
+	}
+}
+
+// selectedUnitBtns are the make unit buttons for the selected hive.
+func (c *HUD) selectedUnitBtns() []*Button {
+	hive, err := c.sim.GetBuildingByID(c.SelectedHiveID)
+	if err != nil {
+		return nil
+	}
+	return c.unitBtns[hive.GetType()]
+}
+
+// queueIconPaths are the pictures for units in a build queue, tinted like
+// their sprites by queueIconTints.
+var queueIconPaths = map[sim.UnitType]string{
+	sim.UnitTypeDefaultAnt:   "units/ants/ant.png",
+	sim.UnitTypeSoldierAnt:   "units/ants/ant.png",
+	sim.UnitTypeDefaultRoach: "units/roaches/roach.png",
+}
+
+var queueIconTints = map[sim.UnitType]ebiten.ColorScale{
+	sim.UnitTypeSoldierAnt: BarracksTint,
+}
+
+// setupBuildQueue makes a button for each build queue slot, four to a row
+// next to the make unit buttons. Clicking one cancels what's in it.
+func (c *HUD) setupBuildQueue() {
+	for slot := 0; slot < sim.MaxBuildQueue; slot++ {
+		minX := c.rightSideRect.Min.X + 102 + slot%4*23
+		minY := c.rightSideRect.Min.Y + 15 + slot/4*32
+		btn := NewButton(c.font,
+			WithRect(image.Rectangle{Min: image.Pt(minX, minY), Max: image.Pt(minX+22, minY+22)}),
+			WithClickFunc(func() {
+				c.log.Info("CancelQueuedUnitButtonClickedEvent", "slot", slot)
+				eventing.Publish(c.sim.EventBus, eventing.CancelQueuedUnitButtonClickedEvent{Slot: slot})
+			}),
+			WithImage(util.LoadImage("ui/btn/btn-bg.png"), util.LoadImage("ui/btn/btn-bg.png")),
+		)
+		c.queueBtns = append(c.queueBtns, btn)
+	}
+	c.queueIcons = make(map[sim.UnitType]*ebiten.Image)
+	for ut, path := range queueIconPaths {
+		c.queueIcons[ut] = util.ScaleImage(util.LoadImage(path), 20, 20)
+	}
+}
+
 func (c *HUD) Update() {
 	switch c.RightSideState {
 	case HiddenState:
 		// do nothing
 	case HiveSelectedState:
-		c.rightSideMakeAntBtn.Update()
+		for _, btn := range c.selectedUnitBtns() {
+			btn.Update()
+		}
+		queued := len(c.sim.GetBuildQueue(c.SelectedHiveID))
+		for _, btn := range c.queueBtns[:min(queued, len(c.queueBtns))] {
+			btn.Update()
+		}
 	case UnitSelectedState:
-		c.rightSideMakeBridgeBtn.Update()
+		for _, btn := range c.buildBtns {
+			btn.Update()
+		}
+	case SiteSelectedState:
+		c.rightSideCancelBtn.Update()
 	}
 
-	//c.attackBtn.Update()
-	//c.stopBtn.Update()
-	//c.moveBtn.Update()
-
+	if c.CommandsVisible {
+		for _, btn := range c.commandBtns {
+			btn.Update()
+		}
+	}
 }
 
 func (c *HUD) Draw(screen *ebiten.Image) {
-	// draw left side BG
-	// opts := &ebiten.DrawImageOptions{}
-	// opts.GeoM.Translate(float64(c.leftSideRect.Min.X), float64(c.leftSideRect.Min.Y))
-	// screen.DrawImage(c.leftSideBg, opts)
-	//c.attackBtn.Draw(screen)
-	//c.stopBtn.Draw(screen)
-	//c.moveBtn.Draw(screen)
-
+	if c.CommandsVisible {
+		c.DrawLeftSide(screen)
+	}
 	c.DrawRightSide(screen)
 	// draw resource display
 	c.resourceDisplay.Draw(screen, c.sim)
 }
 
+func (c *HUD) DrawLeftSide(screen *ebiten.Image) {
+	opts := &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(float64(c.leftSideRect.Min.X), float64(c.leftSideRect.Min.Y))
+	screen.DrawImage(c.leftSideBg, opts)
+	for i, btn := range c.commandBtns {
+		btn.Draw(screen)
+		// key hint under the button
+		x, _ := btn.GetCenter()
+		util.DrawCenteredText(screen, c.font, c.commandKeys[i].String(), x, btn.rect.Max.Y+14, color.RGBA{R: 0, G: 0, B: 0, A: 255})
+	}
+}
+
 func (c *HUD) DrawRightSide(screen *ebiten.Image) {
 	// setup right side BG options
 	opts := &ebiten.DrawImageOptions{}
@@ -152,18 +313,51 @@ func (c *HUD) DrawRightSide(screen *ebiten.Image) {
 		// draw nothing
 	case HiveSelectedState:
 		screen.DrawImage(c.rightSideBg, opts)
-		c.rightSideMakeAntBtn.Draw(screen)
-		c.DrawRightSideZImg(screen)
+		for i, btn := range c.selectedUnitBtns() {
+			btn.Draw(screen)
+			x, _ := btn.GetCenter()
+			util.DrawCenteredText(screen, c.font, UnitKeys[i].String(), x, btn.rect.Max.Y+14, color.RGBA{R: 0, G: 0, B: 0, A: 255})
+		}
+		c.drawBuildQueue(screen)
 	case UnitSelectedState:
 		screen.DrawImage(c.rightSideBg, opts)
-		c.rightSideMakeBridgeBtn.Draw(screen)
-		c.DrawRightSideZImg(screen)
-
+		for i, btn := range c.buildBtns {
+			btn.Draw(screen)
+			x, _ := btn.GetCenter()
+			util.DrawCenteredText(screen, c.font, c.buildKeys[i].String(), x, btn.rect.Max.Y+14, color.RGBA{R: 0, G: 0, B: 0, A: 255})
+		}
+	case SiteSelectedState:
+		screen.DrawImage(c.rightSideBg, opts)
+		c.rightSideCancelBtn.Draw(screen)
+		x, _ := c.rightSideCancelBtn.GetCenter()
+		util.DrawCenteredText(screen, c.font, CancelKey.String(), x, c.rightSideCancelBtn.rect.Max.Y+14, color.RGBA{R: 0, G: 0, B: 0, A: 255})
 	}
 
 }
-func (c *HUD) DrawRightSideZImg(screen *ebiten.Image) {
-	opts := &ebiten.DrawImageOptions{}
-	opts.GeoM.Translate(float64(c.rightSideRect.Min.X+25), float64(c.rightSideRect.Min.Y+64))
-	screen.DrawImage(c.rightSideZImg, opts)
+
+// drawBuildQueue draws an icon per queued unit, with a bar under the first
+// one for how far along it is.
+func (c *HUD) drawBuildQueue(screen *ebiten.Image) {
+	queue := c.sim.GetBuildQueue(c.SelectedHiveID)
+	for i, order := range queue[:min(len(queue), len(c.queueBtns))] {
+		btn := c.queueBtns[i]
+		btn.Draw(screen)
+		if icon, ok := c.queueIcons[order.Type]; ok {
+			opts := &ebiten.DrawImageOptions{}
+			opts.GeoM.Translate(float64(btn.rect.Min.X+1), float64(btn.rect.Min.Y+1))
+			opts.ColorScale = queueIconTints[order.Type]
+			screen.DrawImage(icon, opts)
+		}
+		if i > 0 {
+			continue
+		}
+		hive, err := c.sim.GetBuildingByID(c.SelectedHiveID)
+		if err != nil {
+			continue
+		}
+		x, y := float32(btn.rect.Min.X), float32(btn.rect.Max.Y+3)
+		width := float32(btn.rect.Dx())
+		vector.DrawFilledRect(screen, x, y, width, 4, color.RGBA{64, 64, 64, 255}, false)
+		vector.DrawFilledRect(screen, x, y, width*float32(hive.GetProgress()), 4, color.RGBA{127, 255, 0, 255}, false)
+	}
 }
@@ -1,10 +1,12 @@
 package ui
 
 import (
+	"fmt"
 	"gamejam/audio"
 	"gamejam/fonts"
 	"gamejam/util"
 	"image"
+	"image/color"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
@@ -19,11 +21,21 @@ type Pause struct {
 	MSXSlider *Slider
 	closeBtn  *Button
 
+	// save slots, the scene fills in what saving and loading does
+	SaveSlots int
+	slot      int
+	slotBtn   *Button
+	saveBtn   *Button
+	loadBtn   *Button
+	status    string
+	OnSave    func(slot int) error
+	OnLoad    func(slot int) error
+
 	Hidden bool
 }
 
 func NewPause(sound *audio.SoundManager, font fonts.All) *Pause {
-	rect := &image.Rectangle{Min: image.Point{X: 200, Y: 175}, Max: image.Point{X: 600, Y: 575}}
+	rect := &image.Rectangle{Min: image.Point{X: 200, Y: 125}, Max: image.Point{X: 600, Y: 625}}
 	scaled := util.ScaleImage(util.LoadImage("ui/metalPanel.png"), float32(rect.Dx()), float32(rect.Dy()))
 	p := &Pause{
 		sound:     sound,
@@ -32,17 +44,36 @@ func NewPause(sound *audio.SoundManager, font fonts.All) *Pause {
 		bg:        scaled,
 		SFXSlider: NewSlider("SFX", rect.Min.X+50, rect.Min.Y+75, font, sound.GlobalSFXVolume),
 		MSXSlider: NewSlider("Music", rect.Min.X+50, rect.Min.Y+174, font, sound.GlobalMSXVolume),
+		SaveSlots: 3,
 		Hidden:    true,
 	}
+	slotRowRect := func(col int) image.Rectangle {
+		x := rect.Min.X + 25 + col*125 // three 110 wide buttons
+		return image.Rectangle{
+			Min: image.Point{X: x, Y: rect.Min.Y + 260},
+			Max: image.Point{X: x + 110, Y: rect.Min.Y + 310},
+		}
+	}
+	p.slotBtn = NewButton(font.Small, WithText("Slot 1"), WithRect(slotRowRect(0)), WithClickFunc(func() {
+		p.slot = (p.slot + 1) % max(p.SaveSlots, 1)
+		p.slotBtn.SetText(fmt.Sprintf("Slot %d", p.slot+1))
+		p.status = ""
+	}))
+	p.saveBtn = NewButton(font.Small, WithText("Save"), WithRect(slotRowRect(1)), WithClickFunc(func() {
+		p.runSlotAction("Saved", p.OnSave)
+	}))
+	p.loadBtn = NewButton(font.Small, WithText("Load"), WithRect(slotRowRect(2)), WithClickFunc(func() {
+		p.runSlotAction("Loaded", p.OnLoad)
+	}))
 	p.closeBtn = NewButton(font.Med, WithText("Close"), WithRect(
 		image.Rectangle{
 			Min: image.Point{
 				X: rect.Min.X + 100, // 400 wide
-				Y: rect.Min.Y + 300, // 400 tall
+				Y: rect.Min.Y + 400, // 500 tall
 			},
 			Max: image.Point{
 				X: rect.Min.X + 300,
-				Y: rect.Min.Y + 350,
+				Y: rect.Min.Y + 450,
 			},
 		}), WithClickFunc(func() {
 		p.Hidden = true
@@ -50,10 +81,25 @@ func NewPause(sound *audio.SoundManager, font fonts.All) *Pause {
 
 	return p
 }
+
+func (p *Pause) runSlotAction(done string, action func(slot int) error) {
+	if action == nil {
+		return
+	}
+	if err := action(p.slot); err != nil {
+		p.status = fmt.Sprintf("Slot %d: %v", p.slot+1, err)
+		return
+	}
+	p.status = fmt.Sprintf("%v slot %d", done, p.slot+1)
+}
+
 func (p *Pause) Update() {
 	if !p.Hidden {
 		p.SFXSlider.Update()
 		p.MSXSlider.Update()
+		p.slotBtn.Update()
+		p.saveBtn.Update()
+		p.loadBtn.Update()
 		p.closeBtn.Update()
 
 		if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
@@ -73,6 +119,12 @@ func (p *Pause) Draw(screen *ebiten.Image) {
 
 		p.SFXSlider.Draw(screen)
 		p.MSXSlider.Draw(screen)
+		p.slotBtn.Draw(screen)
+		p.saveBtn.Draw(screen)
+		p.loadBtn.Draw(screen)
+		if p.status != "" {
+			util.DrawCenteredText(screen, p.font.Small, p.status, p.rect.Min.X+p.rect.Dx()/2, p.rect.Min.Y+350, color.RGBA{R: 0, G: 0, B: 0, A: 255})
+		}
 		p.closeBtn.Draw(screen)
 	}
 }
@@ -28,6 +28,14 @@ func NewProgressBar(x, y, width, height int) *ProgressBar {
 	}
 }
 
+// NewHPBar is a ProgressBar in health colors.
+func NewHPBar(x, y, width, height int) *ProgressBar {
+	pb := NewProgressBar(x, y, width, height)
+	pb.BgColor = color.RGBA{120, 20, 20, 255}
+	pb.FgColor = color.RGBA{60, 200, 60, 255}
+	return pb
+}
+
 func (pb *ProgressBar) SetProgress(p float64) {
 
 	if p < 0 {
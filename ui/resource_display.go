@@ -5,15 +5,17 @@ import (
 	"gamejam/sim"
 	"gamejam/util"
 	"image"
+	"image/color"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 )
 
 type ResourceDisplay struct {
-	bg   *ebiten.Image
-	font text.Face
-	rect *image.Rectangle
+	bg         *ebiten.Image
+	supplyIcon *ebiten.Image
+	font       text.Face
+	rect       *image.Rectangle
 }
 
 func NewResourceDisplay(font text.Face) *ResourceDisplay {
@@ -21,9 +23,10 @@ func NewResourceDisplay(font text.Face) *ResourceDisplay {
 	rect := image.Rectangle{Min: image.Point{X: 650, Y: 0}, Max: image.Point{X: 800, Y: 80}}
 	scaled := util.ScaleImage(img, float32(rect.Dx()), float32(rect.Dy()))
 	return &ResourceDisplay{
-		bg:   scaled,
-		font: font,
-		rect: &rect,
+		bg:         scaled,
+		supplyIcon: util.ScaleImage(util.LoadImage("units/ants/ant.png"), 24, 24),
+		font:       font,
+		rect:       &rect,
 	}
 }
 
@@ -38,4 +41,16 @@ func (rd *ResourceDisplay) Draw(screen *ebiten.Image, sim *sim.T) {
 
 	wood := sim.GetWoodAmount()
 	util.DrawCenteredText(screen, rd.font, fmt.Sprintf("%v", wood), rd.rect.Min.X+82, rd.rect.Min.Y+55, nil)
+
+	// used/available supply goes under the panel, red once there's no room
+	// for another ant
+	opts = &ebiten.DrawImageOptions{}
+	opts.GeoM.Translate(float64(rd.rect.Min.X+10), float64(rd.rect.Max.Y))
+	screen.DrawImage(rd.supplyIcon, opts)
+	used, provided := sim.GetPlayerSupply()
+	supplyColor := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	if used >= provided {
+		supplyColor = color.RGBA{R: 220, G: 30, B: 30, A: 255}
+	}
+	util.DrawCenteredText(screen, rd.font, fmt.Sprintf("%v/%v", used, provided), rd.rect.Min.X+82, rd.rect.Max.Y+12, supplyColor)
 }
@@ -46,7 +46,11 @@ type Sprite struct {
 	CarryingSucrose bool
 	CarryingWood    bool
 
+	// multiplied in when drawn, for things sharing art like soldier ants
+	Tint ebiten.ColorScale
+
 	ProgressBar *ProgressBar
+	HPBar       *ProgressBar // along the top, only while damaged
 }
 
 // Units
@@ -87,6 +91,11 @@ func NewDefaultAntSprite(uuid uuid.UUID) *Sprite {
 
 	return spr
 }
+func NewSoldierAntSprite(uuid uuid.UUID) *Sprite {
+	spr := NewDefaultAntSprite(uuid)
+	spr.Tint = BarracksTint
+	return spr
+}
 func NewDefaultRoachSprite(uuid uuid.UUID) *Sprite {
 	spr := NewSprite(uuid, image.Rect(0, 0, TileDimensions, TileDimensions), "units/roaches/roach.png", SpriteTypeUnit)
 	spr.carryingSucroseSS = util.LoadImage("units/roaches/roach-carrying-sucrose.png")
@@ -107,12 +116,48 @@ func NewRoachHiveSprite(uuid uuid.UUID) *Sprite {
 	return NewSprite(uuid, image.Rect(0, 0, TileDimensions*2, TileDimensions*2), "units/roach-hill.png", SpriteTypeHive)
 }
 
+// The other buildings reuse the ant hill until they get their own art, tinted
+// so they can be told apart.
+func NewStorageDepotSprite(uuid uuid.UUID) *Sprite {
+	spr := NewSprite(uuid, image.Rect(0, 0, TileDimensions, TileDimensions), "units/ant-hill.png", SpriteTypeHive)
+	spr.Tint = StorageDepotTint
+	return spr
+}
+func NewBarracksSprite(uuid uuid.UUID) *Sprite {
+	spr := NewSprite(uuid, image.Rect(0, 0, TileDimensions*2, TileDimensions*2), "units/ant-hill.png", SpriteTypeHive)
+	spr.Tint = BarracksTint
+	return spr
+}
+func NewDefensiveMoundSprite(uuid uuid.UUID) *Sprite {
+	spr := NewSprite(uuid, image.Rect(0, 0, TileDimensions, TileDimensions), "units/ant-hill.png", SpriteTypeHive)
+	spr.Tint = DefensiveMoundTint
+	return spr
+}
+func NewNestSprite(uuid uuid.UUID) *Sprite {
+	spr := NewSprite(uuid, image.Rect(0, 0, TileDimensions, TileDimensions), "units/ant-hill.png", SpriteTypeHive)
+	spr.Tint = NestTint
+	return spr
+}
+
+var (
+	StorageDepotTint   = tint(1, 0.85, 0.5)
+	BarracksTint       = tint(1, 0.55, 0.55)
+	DefensiveMoundTint = tint(0.6, 0.6, 0.7)
+	NestTint           = tint(0.7, 1, 0.7)
+)
+
+func tint(r, g, b float32) ebiten.ColorScale {
+	var c ebiten.ColorScale
+	c.Scale(r, g, b, 1)
+	return c
+}
+
 // Static Sprites
 func NewBridgeSprite(uuid uuid.UUID) *Sprite {
 	return NewSprite(uuid, image.Rect(0, 0, TileDimensions, TileDimensions), "tilemap/bridge.png", SpriteTypeStatic)
 }
-func NewInConstructionSprite(uuid uuid.UUID) *Sprite {
-	return NewSprite(uuid, image.Rect(0, 0, TileDimensions, TileDimensions), "tilemap/in-construction.png", SpriteTypeInConstruction)
+func NewInConstructionSprite(uuid uuid.UUID, size image.Point) *Sprite {
+	return NewSprite(uuid, image.Rectangle{Max: size}, "tilemap/in-construction.png", SpriteTypeInConstruction)
 }
 func NewHeartSprite(uuid uuid.UUID) *Sprite {
 	return NewSprite(uuid, image.Rect(0, 0, TileDimensions/2, TileDimensions/2), "ui/heart.png", SpriteTypeStatic)
@@ -127,6 +172,7 @@ func NewSprite(uuid uuid.UUID, Rect image.Rectangle, imgPath string, spriteType
 		img:         scaled,
 		Selected:    false,
 		ProgressBar: NewProgressBar(Rect.Min.X, Rect.Min.Y, Rect.Dx(), 6),
+		HPBar:       NewHPBar(Rect.Min.X, Rect.Min.Y, Rect.Dx(), 6),
 	}
 }
 func (spr *Sprite) SetPosition(pos *image.Point) {
@@ -163,6 +209,23 @@ func (spr *Sprite) SetProgressBarPosition(x, y int) {
 		spr.ProgressBar.X = barX
 		spr.ProgressBar.Y = barY
 	}
+	if spr.HPBar != nil && spr.Rect != nil {
+		spr.HPBar.X = spr.Rect.Min.X
+		spr.HPBar.Y = spr.Rect.Min.Y
+	}
+}
+
+// SetHP fills the HP bar, it's hidden at full HP and for things that can't
+// be hurt.
+func (spr *Sprite) SetHP(hp, hpMax uint) {
+	if spr.HPBar == nil {
+		return
+	}
+	if hpMax == 0 || hp >= hpMax {
+		spr.HPBar.SetProgress(0)
+		return
+	}
+	spr.HPBar.SetProgress(max(float64(hp)/float64(hpMax), 0.01)) // 0 would hide it
 }
 
 func (spr *Sprite) SetAngle(angle float64) {
@@ -195,6 +258,7 @@ func (spr *Sprite) Draw(screen *ebiten.Image, camera *Camera) {
 	sprX, sprY := camera.MapPosToScreenPos(spr.Rect.Min.X, spr.Rect.Min.Y)
 	opts.GeoM.Translate(float64(sprX), float64(sprY))
 
+	opts.ColorScale = spr.Tint
 	if spr.Animation != nil {
 		screen.DrawImage(spr.Animation.CurrentFrameImage(), opts)
 	} else {
@@ -204,6 +268,9 @@ func (spr *Sprite) Draw(screen *ebiten.Image, camera *Camera) {
 	if spr.Selected && spr.Type != SpriteTypeStatic {
 		spr.drawSelectedBox(screen, camera)
 	}
+	if spr.HPBar != nil {
+		spr.HPBar.Draw(screen, camera)
+	}
 	if spr.ProgressBar != nil {
 		spr.ProgressBar.Draw(screen, camera)
 	}
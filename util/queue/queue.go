@@ -1,4 +1,4 @@
-package util
+package queue
 
 import "errors"
 
@@ -8,7 +8,7 @@ type Queue[T any] struct {
 }
 
 // New returns a new, empty queue.
-func NewQueue[T any]() *Queue[T] {
+func New[T any]() *Queue[T] {
 	return &Queue[T]{items: []T{}}
 }
 
@@ -48,3 +48,20 @@ func (q *Queue[T]) Len() int {
 func (q *Queue[T]) IsEmpty() bool {
 	return len(q.items) == 0
 }
+
+// Items returns a copy of the queued items, front first.
+func (q *Queue[T]) Items() []T {
+	return append([]T(nil), q.items...)
+}
+
+// RemoveAt removes and returns the item at index i, 0 being the front.
+// Returns an error if there's no such item.
+func (q *Queue[T]) RemoveAt(i int) (T, error) {
+	var zero T
+	if i < 0 || i >= len(q.items) {
+		return zero, errors.New("index out of range")
+	}
+	item := q.items[i]
+	q.items = append(q.items[:i:i], q.items[i+1:]...)
+	return item, nil
+}